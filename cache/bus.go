@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// InvalidationMessage is an invalidation published on an InvalidationBus by the
+// node identified by Origin.
+type InvalidationMessage struct {
+	Invalidation
+	Origin string `json:"origin"`
+}
+
+// InvalidationHandler receives the messages of an InvalidationBus.
+type InvalidationHandler func(ctx context.Context, msg InvalidationMessage)
+
+// InvalidationBus carries invalidations between the processes caching the same
+// data, each with its own in-process cache. Cached repositories publish the
+// invalidations of their writes; SubscribeInvalidations applies the messages of
+// other nodes to the local cache service. Implementations must be safe for
+// concurrent use.
+type InvalidationBus interface {
+	// Publish sends msg to every subscriber, including the publishing node's.
+	Publish(ctx context.Context, msg InvalidationMessage) error
+	// Subscribe calls handler with every message published until ctx is done.
+	Subscribe(ctx context.Context, handler InvalidationHandler) error
+}
+
+// ErrPublishFailed wraps the errors of InvalidationBus.Publish reported by
+// cached repositories.
+var ErrPublishFailed = errors.New("cache: invalidation not published")
+
+// NewNodeID returns a random identifier for the current process, to tell its
+// messages apart on an InvalidationBus.
+func NewNodeID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// SubscriberConfig configures SubscribeInvalidations.
+type SubscriberConfig struct {
+	// Node identifies the local process; its own messages are skipped since
+	// its writes already invalidated the local cache.
+	Node string
+	// Queue receives the parts of remote invalidations the cache service fails
+	// to apply, for example a RetryQueue. Nil passes them to OnError.
+	Queue InvalidationQueue
+	// OnError is called with messages that could not be applied nor queued.
+	OnError func(ctx context.Context, msg InvalidationMessage, err error)
+}
+
+// SubscribeInvalidations applies the invalidations other nodes publish on bus
+// to service until ctx is done.
+func SubscribeInvalidations(ctx context.Context, bus InvalidationBus, service CacheService, cfg SubscriberConfig) error {
+	if bus == nil || service == nil {
+		return errors.New("cache: subscribing to invalidations requires a bus and a cache service")
+	}
+	return bus.Subscribe(ctx, func(ctx context.Context, msg InvalidationMessage) {
+		if msg.Origin != "" && msg.Origin == cfg.Node {
+			return
+		}
+		remaining, err := ApplyInvalidation(ctx, service, msg.Invalidation)
+		if err == nil {
+			return
+		}
+		if cfg.Queue != nil {
+			queueErr := cfg.Queue.Enqueue(ctx, remaining)
+			if queueErr == nil {
+				return
+			}
+			err = errors.Join(err, queueErr)
+		}
+		if cfg.OnError != nil {
+			cfg.OnError(ctx, msg, err)
+		}
+	})
+}
+
+// MemoryBus is an in-process InvalidationBus delivering every message to its
+// subscribers synchronously, for tests and single-binary setups.
+type MemoryBus struct {
+	subscribers
+}
+
+var _ InvalidationBus = (*MemoryBus)(nil)
+
+// NewMemoryBus creates an empty MemoryBus.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+// Publish implements InvalidationBus.Publish.
+func (b *MemoryBus) Publish(ctx context.Context, msg InvalidationMessage) error {
+	b.deliver(ctx, msg)
+	return nil
+}
+
+// subscribers keeps the handlers of a bus.
+type subscribers struct {
+	mu       sync.RWMutex
+	handlers map[int]subscription
+	next     int
+}
+
+type subscription struct {
+	ctx     context.Context
+	handler InvalidationHandler
+}
+
+// Subscribe implements InvalidationBus.Subscribe.
+func (s *subscribers) Subscribe(ctx context.Context, handler InvalidationHandler) error {
+	if handler == nil {
+		return errors.New("cache: nil invalidation handler")
+	}
+	s.mu.Lock()
+	if s.handlers == nil {
+		s.handlers = make(map[int]subscription)
+	}
+	id := s.next
+	s.next++
+	s.handlers[id] = subscription{ctx: ctx, handler: handler}
+	s.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.handlers, id)
+	})
+	return nil
+}
+
+// deliver calls the handler of every active subscription with msg.
+func (s *subscribers) deliver(ctx context.Context, msg InvalidationMessage) {
+	s.mu.RLock()
+	subs := make([]subscription, 0, len(s.handlers))
+	for _, sub := range s.handlers {
+		subs = append(subs, sub)
+	}
+	s.mu.RUnlock()
+
+	for _, sub := range subs {
+		if sub.ctx.Err() == nil {
+			sub.handler(ctx, msg)
+		}
+	}
+}
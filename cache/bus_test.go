@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMemoryBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := NewMemoryBus()
+	local, remote := newFlakyCacheService(false), newFlakyCacheService(false)
+	if err := SubscribeInvalidations(ctx, bus, local, SubscriberConfig{Node: "node-a"}); err != nil {
+		t.Fatalf("SubscribeInvalidations failed: %v", err)
+	}
+	if err := SubscribeInvalidations(ctx, bus, remote, SubscriberConfig{Node: "node-b"}); err != nil {
+		t.Fatalf("SubscribeInvalidations failed: %v", err)
+	}
+
+	msg := InvalidationMessage{
+		Invalidation: Invalidation{Namespace: "users", Tags: []string{"users::list"}, Keys: []string{"users::get"}},
+		Origin:       "node-a",
+	}
+	if err := bus.Publish(ctx, msg); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(local.tags) != 0 || len(local.keys) != 0 {
+		t.Fatalf("expected the origin to skip its own message, got tags=%v keys=%v", local.tags, local.keys)
+	}
+	if len(remote.tags) != 1 || len(remote.keys) != 1 {
+		t.Fatalf("expected the other node to apply the message, got tags=%v keys=%v", remote.tags, remote.keys)
+	}
+
+	cancel()
+	remote.tags = nil
+	bus.Publish(context.Background(), msg)
+	if len(remote.tags) != 0 {
+		t.Fatal("expected no delivery after the subscription context is done")
+	}
+}
+
+func TestSubscribeInvalidations_Failures(t *testing.T) {
+	ctx := context.Background()
+	bus := NewMemoryBus()
+	service := newFlakyCacheService(true)
+	queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{Capacity: 1})
+	defer queue.Drain(canceledContext())
+
+	var failed []InvalidationMessage
+	SubscribeInvalidations(ctx, bus, service, SubscriberConfig{
+		Node:    "node-b",
+		Queue:   queue,
+		OnError: func(ctx context.Context, msg InvalidationMessage, err error) { failed = append(failed, msg) },
+	})
+
+	msg := InvalidationMessage{Invalidation: Invalidation{Namespace: "users", Keys: []string{"users::get"}}, Origin: "node-a"}
+	bus.Publish(ctx, msg)
+	if queue.Len() != 1 || len(failed) != 0 {
+		t.Fatalf("expected the failed invalidation to be queued, got %d queued and %d errors", queue.Len(), len(failed))
+	}
+	bus.Publish(ctx, msg)
+	if len(failed) != 1 {
+		t.Fatalf("expected the overflowing invalidation to be reported, got %d", len(failed))
+	}
+}
+
+func TestHTTPBus(t *testing.T) {
+	ctx := context.Background()
+	receiver := NewHTTPBus(HTTPBusConfig{
+		Authorize: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer secret" },
+	})
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	service := newFlakyCacheService(false)
+	if err := SubscribeInvalidations(ctx, receiver, service, SubscriberConfig{Node: "node-b"}); err != nil {
+		t.Fatalf("SubscribeInvalidations failed: %v", err)
+	}
+	msg := InvalidationMessage{
+		Invalidation: Invalidation{Namespace: "users", Tags: []string{"users::id::1"}, Prefixes: []string{"users::get_by_id::"}},
+		Origin:       "node-a",
+	}
+
+	t.Run("Publish", func(t *testing.T) {
+		sender := NewHTTPBus(HTTPBusConfig{
+			Peers:  []string{server.URL},
+			Header: http.Header{"Authorization": []string{"Bearer secret"}},
+		})
+		if err := sender.Publish(ctx, msg); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		service.mu.Lock()
+		defer service.mu.Unlock()
+		if len(service.tags) != 1 || len(service.prefixes) != 1 {
+			t.Fatalf("expected the peer to apply the message, got tags=%v prefixes=%v", service.tags, service.prefixes)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		sender := NewHTTPBus(HTTPBusConfig{Peers: []string{server.URL}})
+		if err := sender.Publish(ctx, msg); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Fatalf("expected the peer to reject the message, got %v", err)
+		}
+	})
+
+	t.Run("UnreachablePeer", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		sender := NewHTTPBus(HTTPBusConfig{
+			Peers:  []string{server.URL, down.URL},
+			Header: http.Header{"Authorization": []string{"Bearer secret"}},
+		})
+		err := sender.Publish(ctx, msg)
+		if err == nil || !strings.Contains(err.Error(), down.URL) {
+			t.Fatalf("expected the unreachable peer to be reported, got %v", err)
+		}
+	})
+
+	t.Run("BadRequests", func(t *testing.T) {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Fatalf("expected 405, got %d", resp.StatusCode)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("{"))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	if err := SubscribeInvalidations(ctx, nil, service, SubscriberConfig{}); err == nil {
+		t.Fatal("expected an error without a bus")
+	}
+	if NewNodeID() == NewNodeID() {
+		t.Fatal("expected distinct node IDs")
+	}
+}
@@ -11,10 +11,14 @@ type Config struct {
 	Capacity             int
 	NumShards            int
 	TTL                  time.Duration
+	MaxTTL               time.Duration
+	MaxStale             time.Duration
+	FetchTimeout         time.Duration
 	EvictionPercentage   int
 	EarlyRefresh         *EarlyRefreshConfig
 	MissingRecordStorage bool
 	EvictionInterval     time.Duration
+	Metrics              Metrics
 }
 
 // EarlyRefreshConfig mirrors the underlying sturdyc early refresh options.
@@ -59,10 +63,14 @@ func (c Config) toInternal() cacheinfra.Config {
 		Capacity:             c.Capacity,
 		NumShards:            c.NumShards,
 		TTL:                  c.TTL,
+		MaxTTL:               c.MaxTTL,
+		MaxStale:             c.MaxStale,
+		FetchTimeout:         c.FetchTimeout,
 		EvictionPercentage:   c.EvictionPercentage,
 		EarlyRefresh:         early,
 		MissingRecordStorage: c.MissingRecordStorage,
 		EvictionInterval:     c.EvictionInterval,
+		Metrics:              c.Metrics,
 	}
 }
 
@@ -81,9 +89,13 @@ func convertFromInternal(cfg cacheinfra.Config) Config {
 		Capacity:             cfg.Capacity,
 		NumShards:            cfg.NumShards,
 		TTL:                  cfg.TTL,
+		MaxTTL:               cfg.MaxTTL,
+		MaxStale:             cfg.MaxStale,
+		FetchTimeout:         cfg.FetchTimeout,
 		EvictionPercentage:   cfg.EvictionPercentage,
 		EarlyRefresh:         early,
 		MissingRecordStorage: cfg.MissingRecordStorage,
 		EvictionInterval:     cfg.EvictionInterval,
+		Metrics:              cfg.Metrics,
 	}
 }
@@ -24,6 +24,57 @@
 //		return repository.GetByID(ctx, "user-123")
 //	})
 //
+// Services may also implement the optional TagRegistry and OptionsFetcher
+// capabilities. GetOrFetchWithOptions registers EntryOptions.Tags before fetching
+// and drops fills that race with InvalidateTags on those tags; it falls back to
+// GetOrFetch for services without the capability. BatchFetcher backs
+// GetOrFetchBatch, which caches many IDs under their own keys and fetches only
+// the missing ones, and EntryWriter stores a value directly under its tags.
+// TagWatcher tells whether a namespace was invalidated while a value stored
+// that way was being read.
+//
+// EntryOptions.TTL gives an entry its own lifetime. The default service keeps
+// every entry for at most Config.MaxTTL (Config.TTL when unset) and expires
+// entries with shorter TTLs on read; services that cannot honor the TTL keep the
+// default. EntryOptions.NotFound marks fetch errors meaning the record does not
+// exist; with missing record storage enabled the default service caches them for
+// EntryOptions.NotFoundTTL and returns the same error on hits. With
+// Config.MaxStale set, expired entries are kept for a while and
+// EntryOptions.StaleIfError serves them, inside a StaleError, when their refresh
+// fails with an error EntryOptions.Transient accepts.
+//
+// Concurrent misses for a key share one fetch, which the default service runs on
+// a context detached from the caller's cancellation but carrying its values,
+// bounded by Config.FetchTimeout or, without one, by the caller's deadline. Every
+// caller returns when its own context is done without failing the others. Hits
+// are served without detaching.
+//
+// Metrics receives counters and latency histograms keyed by namespace and method.
+// MemoryMetrics keeps them in memory and returns them from Snapshot; cached
+// repositories report their reads and the default service reports evictions and
+// invalidated keys through Config.Metrics.
+//
+// RetryQueue retries failed invalidations from a background worker with
+// exponential backoff. The queue is bounded, can flush the namespace of an
+// invalidation that overflows it, persists its content through a RetryStore and
+// empties itself on Drain. ApplyInvalidation and FlushNamespace apply an
+// Invalidation or clear a namespace directly.
+//
+// InvalidationBus carries invalidations between processes that each keep their
+// own cache. SubscribeInvalidations applies the messages other nodes publish to
+// a local CacheService. MemoryBus delivers messages in-process and HTTPBus posts
+// them to a list of peers as JSON.
+//
+// # Aliased Types
+//
+// Config and EarlyRefreshConfig are mirrored from the default service's internal
+// configuration and converted on the way in. The types the default service
+// exchanges on every call (EntryOptions, StaleError, and Metrics with its
+// counters and snapshots) are aliases of the internal types instead, on purpose:
+// the service implements OptionsFetcher, EntryWriter, BatchFetcher and reports
+// through Metrics directly, which only works when both packages share one type.
+// The aliases are part of this package's API and follow its compatibility rules.
+//
 // # Key Serialization Strategy
 //
 // The default key serializer uses reflection to handle various Go types:
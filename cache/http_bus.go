@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHTTPBusTimeout bounds each request of an HTTPBus whose config sets no
+// client.
+const DefaultHTTPBusTimeout = 5 * time.Second
+
+// maxHTTPBusMessage bounds the size of the messages an HTTPBus accepts.
+const maxHTTPBusMessage = 1 << 20
+
+// HTTPBusConfig configures an HTTPBus.
+type HTTPBusConfig struct {
+	// Peers lists the URLs the HTTPBus handlers of the other nodes are served
+	// at, such as "http://10.0.0.2:8080/internal/cache/invalidate".
+	Peers []string
+	// Client sends the messages. Nil uses a client with DefaultHTTPBusTimeout.
+	Client *http.Client
+	// Header is added to every request, for example to authenticate with
+	// peers. Use Authorize to check it on the receiving side.
+	Header http.Header
+	// Authorize, when set, rejects requests it returns false for with 401.
+	Authorize func(r *http.Request) bool
+}
+
+// HTTPBus is an InvalidationBus over plain HTTP: Publish POSTs each message as
+// JSON to every peer, and the bus is the http.Handler receiving the messages of
+// the peers. Mount it on an internal address of every node. Delivery is best
+// effort; a peer that is down misses the messages published meanwhile.
+type HTTPBus struct {
+	subscribers
+	cfg    HTTPBusConfig
+	client *http.Client
+}
+
+var (
+	_ InvalidationBus = (*HTTPBus)(nil)
+	_ http.Handler    = (*HTTPBus)(nil)
+)
+
+// NewHTTPBus creates an HTTPBus publishing to cfg.Peers.
+func NewHTTPBus(cfg HTTPBusConfig) *HTTPBus {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultHTTPBusTimeout}
+	}
+	return &HTTPBus{cfg: cfg, client: client}
+}
+
+// Publish implements InvalidationBus.Publish. It delivers msg to the local
+// subscribers and POSTs it to every peer concurrently, returning the errors of
+// the peers that did not accept it.
+func (b *HTTPBus) Publish(ctx context.Context, msg InvalidationMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("cache: encode invalidation: %w", err)
+	}
+	b.deliver(ctx, msg)
+
+	errs := make([]error, len(b.cfg.Peers))
+	var wg sync.WaitGroup
+	for i, peer := range b.cfg.Peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.send(ctx, peer, body); err != nil {
+				errs[i] = fmt.Errorf("cache: publish invalidation to %s: %w", peer, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (b *HTTPBus) send(ctx context.Context, peer string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range b.cfg.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// ServeHTTP receives a message POSTed by a peer and hands it to the
+// subscribers before answering 204.
+func (b *HTTPBus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if b.cfg.Authorize != nil && !b.cfg.Authorize(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var msg InvalidationMessage
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxHTTPBusMessage)).Decode(&msg); err != nil {
+		http.Error(w, "invalid invalidation message", http.StatusBadRequest)
+		return
+	}
+	b.deliver(r.Context(), msg)
+	w.WriteHeader(http.StatusNoContent)
+}
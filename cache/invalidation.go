@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"errors"
+)
+
+// Invalidation lists cache entries of one namespace to invalidate: Tags through
+// the cache service's TagRegistry, Keys one by one and every key starting with
+// one of Prefixes.
+type Invalidation struct {
+	Namespace string   `json:"namespace"`
+	Tags      []string `json:"tags,omitempty"`
+	Keys      []string `json:"keys,omitempty"`
+	Prefixes  []string `json:"prefixes,omitempty"`
+}
+
+// Empty reports whether the invalidation lists no entries.
+func (inv Invalidation) Empty() bool {
+	return len(inv.Tags) == 0 && len(inv.Keys) == 0 && len(inv.Prefixes) == 0
+}
+
+// InvalidationQueue accepts invalidations to apply later, such as the ones a
+// write failed to apply. RetryQueue implements it.
+type InvalidationQueue interface {
+	Enqueue(ctx context.Context, inv Invalidation) error
+}
+
+// ErrEmptyNamespace is returned by FlushNamespace when no namespace is given.
+var ErrEmptyNamespace = errors.New("cache: empty namespace")
+
+// ApplyInvalidation invalidates the entries listed by inv on service and returns
+// the part of inv that failed, with the joined errors. Tags are flushed with the
+// whole namespace when service has no TagRegistry.
+func ApplyInvalidation(ctx context.Context, service CacheService, inv Invalidation) (Invalidation, error) {
+	remaining := Invalidation{Namespace: inv.Namespace}
+	var errs []error
+	if len(inv.Tags) > 0 {
+		var err error
+		if tagRegistry, ok := service.(TagRegistry); ok {
+			err = tagRegistry.InvalidateTags(ctx, inv.Tags)
+		} else {
+			err = FlushNamespace(ctx, service, inv.Namespace)
+		}
+		if err != nil {
+			remaining.Tags = inv.Tags
+			errs = append(errs, err)
+		}
+	}
+	for _, key := range inv.Keys {
+		if err := service.Delete(ctx, key); err != nil {
+			remaining.Keys = append(remaining.Keys, key)
+			errs = append(errs, err)
+		}
+	}
+	for _, prefix := range inv.Prefixes {
+		if err := service.DeleteByPrefix(ctx, prefix); err != nil {
+			remaining.Prefixes = append(remaining.Prefixes, prefix)
+			errs = append(errs, err)
+		}
+	}
+	return remaining, errors.Join(errs...)
+}
+
+// FlushNamespace deletes every entry cached under namespace, the last resort
+// when the precise entries to invalidate are unknown.
+func FlushNamespace(ctx context.Context, service CacheService, namespace string) error {
+	if namespace == "" {
+		return ErrEmptyNamespace
+	}
+	return service.DeleteByPrefix(ctx, namespace+KeySeparator)
+}
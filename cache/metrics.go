@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/goliatone/go-repository-cache/internal/cacheinfra"
+)
+
+// Metrics receives cache measurements keyed by namespace and method: counters
+// such as hits, misses and evictions, and latency histograms. Either key may be
+// empty when a measurement is not specific to one. Cached repositories report
+// their reads and invalidations under their namespace and ReadMethod, and the
+// default cache service reports evictions through Config.Metrics.
+// Implementations must be safe for concurrent use.
+type Metrics = cacheinfra.Metrics
+
+// Counter names a cache counter.
+type Counter = cacheinfra.Counter
+
+// Histogram names a cache latency histogram.
+type Histogram = cacheinfra.Histogram
+
+const (
+	// CounterHit counts reads served from the cache.
+	CounterHit = cacheinfra.CounterHit
+	// CounterMiss counts reads that fetched from the source of truth.
+	CounterMiss = cacheinfra.CounterMiss
+	// CounterFill counts fetched values handed to the cache.
+	CounterFill = cacheinfra.CounterFill
+	// CounterError counts reads that returned an error.
+	CounterError = cacheinfra.CounterError
+	// CounterStale counts reads served a stale value after a failed refresh.
+	CounterStale = cacheinfra.CounterStale
+	// CounterInvalidation counts writes that invalidated cached entries.
+	CounterInvalidation = cacheinfra.CounterInvalidation
+	// CounterEviction counts entries the cache removed on expiry or to make room.
+	CounterEviction = cacheinfra.CounterEviction
+	// CounterForcedEviction counts the times a full cache evicted entries to
+	// make room.
+	CounterForcedEviction = cacheinfra.CounterForcedEviction
+	// CounterInvalidatedKey counts keys deleted by invalidations, whether or not
+	// they held an entry.
+	CounterInvalidatedKey = cacheinfra.CounterInvalidatedKey
+	// CounterRetryEnqueued counts failed invalidations queued for retry.
+	CounterRetryEnqueued = cacheinfra.CounterRetryEnqueued
+	// CounterRetrySucceeded counts queued invalidations a retry completed.
+	CounterRetrySucceeded = cacheinfra.CounterRetrySucceeded
+	// CounterRetryFailed counts retries of queued invalidations that failed.
+	CounterRetryFailed = cacheinfra.CounterRetryFailed
+	// CounterRetryDropped counts queued invalidations given up on.
+	CounterRetryDropped = cacheinfra.CounterRetryDropped
+	// CounterRetryOverflow counts invalidations that found the retry queue full.
+	CounterRetryOverflow = cacheinfra.CounterRetryOverflow
+
+	// HistogramRead measures whole reads, served from the cache or not.
+	HistogramRead = cacheinfra.HistogramRead
+	// HistogramFetch measures fetches from the source of truth.
+	HistogramFetch = cacheinfra.HistogramFetch
+)
+
+// MemoryMetrics is the in-memory Metrics implementation. Snapshot returns a copy
+// of its series to expose however suits the application.
+type MemoryMetrics = cacheinfra.MemoryMetrics
+
+// MetricsSnapshot is a point-in-time copy of a MemoryMetrics.
+type MetricsSnapshot = cacheinfra.MetricsSnapshot
+
+// SeriesSnapshot holds the measurements of one namespace and method.
+type SeriesSnapshot = cacheinfra.SeriesSnapshot
+
+// HistogramSnapshot summarizes the durations recorded in a histogram.
+type HistogramSnapshot = cacheinfra.HistogramSnapshot
+
+// BucketSnapshot counts the durations up to UpperBound.
+type BucketSnapshot = cacheinfra.BucketSnapshot
+
+// DefaultLatencyBuckets are the histogram bucket upper bounds used by
+// NewMemoryMetrics when none are given.
+var DefaultLatencyBuckets = cacheinfra.DefaultLatencyBuckets
+
+// NewMemoryMetrics creates an empty MemoryMetrics whose histograms use the given
+// bucket upper bounds, or DefaultLatencyBuckets when none are given.
+func NewMemoryMetrics(buckets ...time.Duration) *MemoryMetrics {
+	return cacheinfra.NewMemoryMetrics(buckets...)
+}
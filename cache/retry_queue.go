@@ -0,0 +1,405 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults used by NewRetryQueue for unset RetryQueueConfig fields.
+const (
+	DefaultRetryQueueCapacity = 1024
+	DefaultRetryBackoff       = 100 * time.Millisecond
+	DefaultRetryMaxBackoff    = 30 * time.Second
+	DefaultRetryTimeout       = 5 * time.Second
+)
+
+var (
+	// ErrRetryQueueFull is returned by RetryQueue.Enqueue when the queue holds
+	// Capacity invalidations and the overflow was not flushed.
+	ErrRetryQueueFull = errors.New("cache: invalidation retry queue is full")
+	// ErrRetryQueueClosed is returned by RetryQueue.Enqueue once Drain was called.
+	ErrRetryQueueClosed = errors.New("cache: invalidation retry queue is closed")
+)
+
+// QueuedInvalidation is an invalidation waiting in a RetryQueue.
+type QueuedInvalidation struct {
+	ID           string       `json:"id"`
+	Invalidation Invalidation `json:"invalidation"`
+	// Attempts counts the retries made so far.
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	// LastError is the error of the last failed attempt.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// RetryStore persists the invalidations of a RetryQueue so they survive a
+// restart. Put is called when an invalidation is queued and after each failed
+// retry, Delete once it succeeded or was dropped, and Load when the queue is
+// created. Implementations must be safe for concurrent use.
+type RetryStore interface {
+	Put(ctx context.Context, item QueuedInvalidation) error
+	Delete(ctx context.Context, id string) error
+	Load(ctx context.Context) ([]QueuedInvalidation, error)
+}
+
+// RetryQueueConfig configures a RetryQueue. Zero values use the defaults.
+type RetryQueueConfig struct {
+	// Capacity bounds the number of queued invalidations.
+	Capacity int
+	// Backoff is the delay before the first retry, doubled after each failed
+	// retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds each retry.
+	Timeout time.Duration
+	// MaxAttempts drops an invalidation after that many failed retries. Zero
+	// retries until it succeeds.
+	MaxAttempts int
+	// FlushOnOverflow flushes the whole namespace of an invalidation that finds
+	// the queue full instead of rejecting it.
+	FlushOnOverflow bool
+	// Store persists queued invalidations. Nil keeps them in memory only.
+	Store RetryStore
+	// Metrics receives the queue counters under the namespace of each
+	// invalidation.
+	Metrics Metrics
+	// OnDrop is called with the invalidations dropped after MaxAttempts.
+	OnDrop func(item QueuedInvalidation, err error)
+}
+
+// RetryQueueStats reports the activity of a RetryQueue.
+type RetryQueueStats struct {
+	// Pending is the number of invalidations waiting to be retried.
+	Pending   int    `json:"pending"`
+	Enqueued  uint64 `json:"enqueued"`
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+	Dropped   uint64 `json:"dropped"`
+	Overflows uint64 `json:"overflows"`
+	// Flushes counts the namespaces flushed on overflow.
+	Flushes uint64 `json:"flushes"`
+}
+
+// RetryQueue retries failed invalidations against a cache service from a
+// background worker, with exponential backoff per invalidation. The queue is
+// bounded; see RetryQueueConfig.FlushOnOverflow. Call Drain on shutdown.
+type RetryQueue struct {
+	service CacheService
+	cfg     RetryQueueConfig
+
+	mu       sync.Mutex
+	items    []*QueuedInvalidation
+	inflight int
+	reserved int
+	closed   bool
+
+	seq    atomic.Uint64
+	prefix string
+	wake   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+
+	enqueued, succeeded, failed, dropped, overflows, flushes atomic.Uint64
+}
+
+var _ InvalidationQueue = (*RetryQueue)(nil)
+
+// NewRetryQueue creates a RetryQueue applying invalidations to service, loads the
+// invalidations persisted in cfg.Store and starts its worker. Loaded
+// invalidations are kept even when they exceed the capacity.
+func NewRetryQueue(ctx context.Context, service CacheService, cfg RetryQueueConfig) (*RetryQueue, error) {
+	if service == nil {
+		return nil, errors.New("cache: retry queue requires a cache service")
+	}
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = DefaultRetryQueueCapacity
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultRetryBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultRetryTimeout
+	}
+
+	q := &RetryQueue{
+		service: service,
+		cfg:     cfg,
+		prefix:  strconv.FormatInt(time.Now().UnixNano(), 36) + "-",
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if cfg.Store != nil {
+		items, err := cfg.Store.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cache: load retry queue: %w", err)
+		}
+		for i := range items {
+			item := items[i]
+			q.items = append(q.items, &item)
+		}
+	}
+	go q.run()
+	return q, nil
+}
+
+// Enqueue queues inv for retry. It returns ErrRetryQueueClosed after Drain, and
+// ErrRetryQueueFull when the queue is full, unless FlushOnOverflow flushed the
+// namespace instead. Invalidations are persisted before Enqueue returns.
+func (q *RetryQueue) Enqueue(ctx context.Context, inv Invalidation) error {
+	if inv.Empty() {
+		return nil
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrRetryQueueClosed
+	}
+	if len(q.items)+q.inflight+q.reserved >= q.cfg.Capacity {
+		q.mu.Unlock()
+		return q.overflow(ctx, inv)
+	}
+	q.reserved++
+	q.mu.Unlock()
+
+	item := &QueuedInvalidation{
+		ID:           q.prefix + strconv.FormatUint(q.seq.Add(1), 10),
+		Invalidation: inv,
+		NextAttempt:  time.Now().Add(q.cfg.Backoff),
+	}
+	var err error
+	if q.cfg.Store != nil {
+		err = q.cfg.Store.Put(ctx, *item)
+	}
+
+	q.mu.Lock()
+	q.reserved--
+	if err == nil {
+		q.items = append(q.items, item)
+	}
+	q.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("cache: persist invalidation: %w", err)
+	}
+
+	q.enqueued.Add(1)
+	q.count(inv.Namespace, CounterRetryEnqueued)
+	q.notify()
+	return nil
+}
+
+// overflow handles an invalidation that found the queue full.
+func (q *RetryQueue) overflow(ctx context.Context, inv Invalidation) error {
+	q.overflows.Add(1)
+	q.count(inv.Namespace, CounterRetryOverflow)
+	if !q.cfg.FlushOnOverflow {
+		return ErrRetryQueueFull
+	}
+	if err := FlushNamespace(ctx, q.service, inv.Namespace); err != nil {
+		return errors.Join(ErrRetryQueueFull, err)
+	}
+	q.flushes.Add(1)
+	return nil
+}
+
+// Len returns the number of invalidations waiting to be retried.
+func (q *RetryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items) + q.inflight + q.reserved
+}
+
+// Stats returns the counters of the queue.
+func (q *RetryQueue) Stats() RetryQueueStats {
+	return RetryQueueStats{
+		Pending:   q.Len(),
+		Enqueued:  q.enqueued.Load(),
+		Succeeded: q.succeeded.Load(),
+		Failed:    q.failed.Load(),
+		Dropped:   q.dropped.Load(),
+		Overflows: q.overflows.Load(),
+		Flushes:   q.flushes.Load(),
+	}
+}
+
+// Drain stops accepting invalidations, stops the worker and retries every queued
+// invalidation right away, backing off between rounds, until the queue is empty
+// or ctx is done. Invalidations left when ctx is done stay in the store. Drain
+// is safe to call more than once.
+func (q *RetryQueue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.stop)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		return q.drainError(ctx)
+	}
+
+	backoff := q.cfg.Backoff
+	for {
+		q.retry(func(*QueuedInvalidation) bool { return true })
+		if q.Len() == 0 {
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return q.drainError(ctx)
+		}
+		backoff = min(backoff*2, q.cfg.MaxBackoff)
+	}
+}
+
+func (q *RetryQueue) drainError(ctx context.Context) error {
+	return fmt.Errorf("cache: %d invalidations left in retry queue: %w", q.Len(), ctx.Err())
+}
+
+// run is the worker: it retries invalidations as they become due.
+func (q *RetryQueue) run() {
+	defer close(q.done)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		now := time.Now()
+		q.retry(func(item *QueuedInvalidation) bool { return !item.NextAttempt.After(now) })
+
+		var wait <-chan time.Time
+		if next, ok := q.nextAttempt(); ok {
+			timer.Reset(time.Until(next))
+			wait = timer.C
+		}
+		select {
+		case <-wait:
+		case <-q.wake:
+			timer.Stop()
+		case <-q.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// nextAttempt returns the earliest retry time of the queued invalidations.
+func (q *RetryQueue) nextAttempt() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var next time.Time
+	for _, item := range q.items {
+		if next.IsZero() || item.NextAttempt.Before(next) {
+			next = item.NextAttempt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// retry applies the queued invalidations selected by due and requeues the
+// parts that still fail.
+func (q *RetryQueue) retry(due func(item *QueuedInvalidation) bool) {
+	q.mu.Lock()
+	var batch []*QueuedInvalidation
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if due(item) {
+			batch = append(batch, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	clear(q.items[len(kept):])
+	q.items = kept
+	q.inflight += len(batch)
+	q.mu.Unlock()
+
+	for _, item := range batch {
+		requeue := q.attempt(item)
+		q.mu.Lock()
+		q.inflight--
+		if requeue {
+			q.items = append(q.items, item)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// attempt applies item once and reports whether it must be retried again.
+func (q *RetryQueue) attempt(item *QueuedInvalidation) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), q.cfg.Timeout)
+	defer cancel()
+
+	namespace := item.Invalidation.Namespace
+	remaining, err := ApplyInvalidation(ctx, q.service, item.Invalidation)
+	if err == nil {
+		q.succeeded.Add(1)
+		q.count(namespace, CounterRetrySucceeded)
+		q.forget(ctx, item)
+		return false
+	}
+
+	q.failed.Add(1)
+	q.count(namespace, CounterRetryFailed)
+	item.Invalidation = remaining
+	item.Attempts++
+	item.LastError = err.Error()
+	if q.cfg.MaxAttempts > 0 && item.Attempts >= q.cfg.MaxAttempts {
+		q.dropped.Add(1)
+		q.count(namespace, CounterRetryDropped)
+		q.forget(ctx, item)
+		if q.cfg.OnDrop != nil {
+			q.cfg.OnDrop(*item, err)
+		}
+		return false
+	}
+	item.NextAttempt = time.Now().Add(q.backoff(item.Attempts))
+	if q.cfg.Store != nil {
+		// A failed update leaves the previous state, which is retried anyway.
+		_ = q.cfg.Store.Put(ctx, *item)
+	}
+	return true
+}
+
+// backoff returns the delay after the given number of failed retries.
+func (q *RetryQueue) backoff(attempts int) time.Duration {
+	backoff := q.cfg.Backoff
+	for i := 1; i < attempts && backoff < q.cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, q.cfg.MaxBackoff)
+}
+
+func (q *RetryQueue) forget(ctx context.Context, item *QueuedInvalidation) {
+	if q.cfg.Store != nil {
+		// An invalidation left in the store is only applied again on restart.
+		_ = q.cfg.Store.Delete(ctx, item.ID)
+	}
+}
+
+func (q *RetryQueue) count(namespace string, counter Counter) {
+	if q.cfg.Metrics != nil {
+		q.cfg.Metrics.Add(namespace, "", counter, 1)
+	}
+}
+
+func (q *RetryQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("cache backend unavailable")
+
+// flakyCacheService records invalidations and fails them while failing is set.
+type flakyCacheService struct {
+	mu          sync.Mutex
+	failing     bool
+	tags        []string
+	keys        []string
+	prefixes    []string
+	invalidated chan struct{}
+}
+
+func newFlakyCacheService(failing bool) *flakyCacheService {
+	return &flakyCacheService{failing: failing, invalidated: make(chan struct{}, 16)}
+}
+
+func (f *flakyCacheService) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *flakyCacheService) record(list *[]string, values ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		return errUnavailable
+	}
+	*list = append(*list, values...)
+	select {
+	case f.invalidated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (f *flakyCacheService) GetOrFetch(ctx context.Context, key string, fetchFn any) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *flakyCacheService) Delete(ctx context.Context, key string) error {
+	return f.record(&f.keys, key)
+}
+
+func (f *flakyCacheService) DeleteByPrefix(ctx context.Context, prefix string) error {
+	return f.record(&f.prefixes, prefix)
+}
+
+func (f *flakyCacheService) InvalidateKeys(ctx context.Context, keys []string) error {
+	return f.record(&f.keys, keys...)
+}
+
+func (f *flakyCacheService) AddTags(ctx context.Context, key string, tags []string) error {
+	return nil
+}
+
+func (f *flakyCacheService) InvalidateTags(ctx context.Context, tags []string) error {
+	return f.record(&f.tags, tags...)
+}
+
+// memoryRetryStore is a RetryStore keeping items in a map.
+type memoryRetryStore struct {
+	mu    sync.Mutex
+	items map[string]QueuedInvalidation
+}
+
+func newMemoryRetryStore() *memoryRetryStore {
+	return &memoryRetryStore{items: make(map[string]QueuedInvalidation)}
+}
+
+func (s *memoryRetryStore) Put(ctx context.Context, item QueuedInvalidation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.ID] = item
+	return nil
+}
+
+func (s *memoryRetryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+func (s *memoryRetryStore) Load(ctx context.Context) ([]QueuedInvalidation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]QueuedInvalidation, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *memoryRetryStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func TestApplyInvalidation(t *testing.T) {
+	ctx := context.Background()
+	service := newFlakyCacheService(true)
+	inv := Invalidation{Namespace: "users", Tags: []string{"users::list"}, Keys: []string{"users::get"}}
+
+	remaining, err := ApplyInvalidation(ctx, service, inv)
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("expected the service error, got %v", err)
+	}
+	if len(remaining.Tags) != 1 || len(remaining.Keys) != 1 {
+		t.Fatalf("expected everything to remain, got %+v", remaining)
+	}
+
+	service.setFailing(false)
+	if remaining, err = ApplyInvalidation(ctx, service, remaining); err != nil || !remaining.Empty() {
+		t.Fatalf("expected the invalidation to apply, got %+v, %v", remaining, err)
+	}
+	if err := FlushNamespace(ctx, service, ""); !errors.Is(err, ErrEmptyNamespace) {
+		t.Fatalf("expected ErrEmptyNamespace, got %v", err)
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	ctx := context.Background()
+	inv := Invalidation{Namespace: "users", Tags: []string{"users::list"}}
+
+	t.Run("RetriesUntilApplied", func(t *testing.T) {
+		service := newFlakyCacheService(true)
+		metrics := NewMemoryMetrics()
+		queue, err := NewRetryQueue(ctx, service, RetryQueueConfig{Backoff: time.Millisecond, Metrics: metrics})
+		if err != nil {
+			t.Fatalf("NewRetryQueue failed: %v", err)
+		}
+		defer queue.Drain(ctx)
+
+		if err := queue.Enqueue(ctx, inv); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		service.setFailing(false)
+
+		select {
+		case <-service.invalidated:
+		case <-time.After(time.Second):
+			t.Fatal("expected the worker to apply the invalidation")
+		}
+		waitFor(t, func() bool { return queue.Len() == 0 })
+
+		stats := queue.Stats()
+		if stats.Enqueued != 1 || stats.Succeeded != 1 || stats.Failed == 0 {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+		snapshot := metrics.Snapshot()
+		if snapshot.Counter("users", "", CounterRetrySucceeded) != 1 || snapshot.Counter("users", "", CounterRetryFailed) != stats.Failed {
+			t.Fatalf("expected the counters to match the stats, got %+v", snapshot)
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		service := newFlakyCacheService(true)
+		queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{Capacity: 1, Backoff: time.Hour})
+		defer queue.Drain(canceledContext())
+
+		queue.Enqueue(ctx, inv)
+		if err := queue.Enqueue(ctx, inv); !errors.Is(err, ErrRetryQueueFull) {
+			t.Fatalf("expected ErrRetryQueueFull, got %v", err)
+		}
+		if stats := queue.Stats(); stats.Overflows != 1 || stats.Pending != 1 {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("FlushOnOverflow", func(t *testing.T) {
+		service := newFlakyCacheService(false)
+		queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{Capacity: 1, Backoff: time.Hour, FlushOnOverflow: true})
+		defer queue.Drain(canceledContext())
+
+		queue.Enqueue(ctx, inv)
+		if err := queue.Enqueue(ctx, Invalidation{Namespace: "orders", Keys: []string{"orders::get"}}); err != nil {
+			t.Fatalf("expected the namespace to be flushed, got %v", err)
+		}
+		service.mu.Lock()
+		defer service.mu.Unlock()
+		if len(service.prefixes) != 1 || service.prefixes[0] != "orders"+KeySeparator {
+			t.Fatalf("expected the orders namespace to be flushed, got %v", service.prefixes)
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		service := newFlakyCacheService(true)
+		dropped := make(chan QueuedInvalidation, 1)
+		queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{
+			Backoff:     time.Millisecond,
+			MaxAttempts: 2,
+			OnDrop:      func(item QueuedInvalidation, err error) { dropped <- item },
+		})
+		defer queue.Drain(ctx)
+
+		queue.Enqueue(ctx, inv)
+		select {
+		case item := <-dropped:
+			if item.Attempts != 2 || item.LastError == "" {
+				t.Fatalf("unexpected dropped item %+v", item)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the invalidation to be dropped")
+		}
+		if stats := queue.Stats(); stats.Dropped != 1 || stats.Pending != 0 {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("Drain", func(t *testing.T) {
+		service := newFlakyCacheService(false)
+		queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{Backoff: time.Hour})
+		queue.Enqueue(ctx, inv)
+
+		if err := queue.Drain(ctx); err != nil {
+			t.Fatalf("Drain failed: %v", err)
+		}
+		if queue.Len() != 0 {
+			t.Fatalf("expected an empty queue, got %d", queue.Len())
+		}
+		if err := queue.Enqueue(ctx, inv); !errors.Is(err, ErrRetryQueueClosed) {
+			t.Fatalf("expected ErrRetryQueueClosed, got %v", err)
+		}
+	})
+
+	t.Run("DrainTimeout", func(t *testing.T) {
+		service := newFlakyCacheService(true)
+		queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{Backoff: time.Millisecond})
+		queue.Enqueue(ctx, inv)
+
+		drainCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if err := queue.Drain(drainCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the drain to time out, got %v", err)
+		}
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		store := newMemoryRetryStore()
+		service := newFlakyCacheService(true)
+		queue, _ := NewRetryQueue(ctx, service, RetryQueueConfig{Backoff: time.Hour, Store: store})
+		queue.Enqueue(ctx, inv)
+		queue.Drain(canceledContext())
+		if store.len() != 1 {
+			t.Fatalf("expected the pending invalidation to be persisted, got %d", store.len())
+		}
+
+		service.setFailing(false)
+		restarted, err := NewRetryQueue(ctx, service, RetryQueueConfig{Backoff: time.Hour, Store: store})
+		if err != nil {
+			t.Fatalf("NewRetryQueue failed: %v", err)
+		}
+		if restarted.Len() != 1 {
+			t.Fatalf("expected the persisted invalidation to be loaded, got %d", restarted.Len())
+		}
+		if err := restarted.Drain(ctx); err != nil {
+			t.Fatalf("Drain failed: %v", err)
+		}
+		if store.len() != 0 {
+			t.Fatalf("expected the applied invalidation to be removed, got %d", store.len())
+		}
+	})
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/internal/cacheinfra"
 )
 
 // KeySerializer builds a cache key from a method name + arbitrary args.
@@ -31,6 +34,79 @@ type TagRegistry interface {
 	InvalidateTags(ctx context.Context, tags []string) error
 }
 
+// EntryOptions carries per-entry hints for cache services that implement OptionsFetcher.
+// Tags lists the invalidation tags the entry depends on; the service registers the
+// key under them before fetching and discards a fetched value when any of them is
+// invalidated while the fetch is in flight. TTL sets how long the entry lives; zero
+// uses the default TTL. The default cache service caps it at Config.MaxTTL, and
+// services without per-entry TTL support ignore it.
+//
+// EntryOptions aliases the default service's own type on purpose; see the
+// package documentation.
+type EntryOptions = cacheinfra.EntryOptions
+
+// OptionsFetcher is an optional cache capability for read-through fetches that carry EntryOptions.
+// It is intended to be used via type assertion when available.
+type OptionsFetcher interface {
+	GetOrFetchWithOptions(ctx context.Context, key string, opts EntryOptions, fetchFn any) (any, error)
+}
+
+// EntryWriter is an optional cache capability for storing a value directly, without
+// a fetch. The key is registered under opts.Tags before the value is stored.
+// It is intended to be used via type assertion when available.
+type EntryWriter interface {
+	Set(ctx context.Context, key string, value any, opts EntryOptions) error
+}
+
+// TTLReporter is an optional cache capability reporting how long entries live.
+// It is intended to be used via type assertion when available.
+type TTLReporter interface {
+	// DefaultTTL returns the TTL of entries stored without EntryOptions.TTL.
+	DefaultTTL() time.Duration
+	// MaxTTL returns the longest TTL honored through EntryOptions.TTL.
+	MaxTTL() time.Duration
+}
+
+// TagWatcher is an optional cache capability for callers that store values read
+// outside of a fetch, for example through EntryWriter: it tells whether entries
+// were invalidated while the values were being read, in which case they may be
+// outdated. It is intended to be used via type assertion when available.
+type TagWatcher interface {
+	// WatchTags returns a function reporting whether any of tags has been
+	// invalidated since WatchTags was called.
+	WatchTags(tags []string) func() bool
+	// WatchNamespace returns a function reporting whether any tag or key led
+	// by namespace has been invalidated since WatchNamespace was called.
+	WatchNamespace(namespace string) func() bool
+}
+
+// BatchFetchFn fetches the values of ids from the source of truth. IDs missing from
+// the returned map have no record.
+type BatchFetchFn[T any] func(ctx context.Context, ids []string) (map[string]T, error)
+
+// BatchFetcher is an optional cache capability for read-through lookups of many IDs.
+// Each ID is cached under keyFn(id) with the tags and TTL of optsFn(id); only the
+// IDs missing from the cache are passed to fetchFn, in a single call.
+// It is intended to be used via type assertion when available.
+type BatchFetcher interface {
+	GetOrFetchBatch(
+		ctx context.Context,
+		ids []string,
+		keyFn func(id string) string,
+		optsFn func(id string) EntryOptions,
+		fetchFn func(ctx context.Context, ids []string) (map[string]any, error),
+	) (map[string]any, error)
+}
+
+// StaleError is returned by OptionsFetcher services with an expired value served
+// because its refresh failed with an error EntryOptions.Transient accepts. Err is
+// the refresh error.
+type StaleError = cacheinfra.StaleError
+
+// ErrMissingRecord is returned by the default cache service for keys a batch fetch
+// marked as having no record, when missing record storage is enabled.
+var ErrMissingRecord = cacheinfra.ErrMissingRecord
+
 // ErrInvalidResultType indicates that the underlying cache implementation returned a value
 // that cannot be asserted to the requested generic type.
 var ErrInvalidResultType = errors.New("cache: invalid result type")
@@ -38,6 +114,32 @@ var ErrInvalidResultType = errors.New("cache: invalid result type")
 // GetOrFetch is a type-safe wrapper function that provides generic support for CacheService.
 func GetOrFetch[T any](ctx context.Context, service CacheService, key string, fetchFn FetchFn[T]) (T, error) {
 	result, err := service.GetOrFetch(ctx, key, fetchFn)
+	return typedResult[T](result, err)
+}
+
+// GetOrFetchWithOptions is the type-safe counterpart of OptionsFetcher.GetOrFetchWithOptions.
+// Services that do not implement OptionsFetcher fall back to a plain GetOrFetch and
+// ignore opts.
+//
+// A stale value is returned together with its *StaleError.
+func GetOrFetchWithOptions[T any](ctx context.Context, service CacheService, key string, opts EntryOptions, fetchFn FetchFn[T]) (T, error) {
+	fetcher, ok := service.(OptionsFetcher)
+	if !ok {
+		return GetOrFetch(ctx, service, key, fetchFn)
+	}
+	result, err := fetcher.GetOrFetchWithOptions(ctx, key, opts, fetchFn)
+	var stale *StaleError
+	if errors.As(err, &stale) {
+		value, typeErr := typedResult[T](stale.Value, nil)
+		if typeErr != nil {
+			return value, typeErr
+		}
+		return value, err
+	}
+	return typedResult[T](result, err)
+}
+
+func typedResult[T any](result any, err error) (T, error) {
 	if err != nil {
 		var zero T
 		return zero, err
@@ -59,3 +161,76 @@ func GetOrFetch[T any](ctx context.Context, service CacheService, key string, fe
 	var zero T
 	return zero, fmt.Errorf("%w (got %T)", ErrInvalidResultType, result)
 }
+
+// GetOrFetchBatch is the type-safe counterpart of BatchFetcher.GetOrFetchBatch.
+// IDs without a record are absent from the result. optsFn may be nil.
+//
+// Services that do not implement BatchFetcher fall back to one GetOrFetchWithOptions
+// per ID, each fetching a single ID.
+func GetOrFetchBatch[T any](ctx context.Context, service CacheService, ids []string, keyFn func(id string) string, optsFn func(id string) EntryOptions, fetchFn BatchFetchFn[T]) (map[string]T, error) {
+	if optsFn == nil {
+		optsFn = func(string) EntryOptions { return EntryOptions{} }
+	}
+
+	fetcher, ok := service.(BatchFetcher)
+	if !ok {
+		return getOrFetchEach(ctx, service, ids, keyFn, optsFn, fetchFn)
+	}
+
+	values, err := fetcher.GetOrFetchBatch(ctx, ids, keyFn, optsFn, func(ctx context.Context, ids []string) (map[string]any, error) {
+		records, err := fetchFn(ctx, ids)
+		values := make(map[string]any, len(records))
+		for id, record := range records {
+			values[id] = record
+		}
+		return values, err
+	})
+
+	result := make(map[string]T, len(values))
+	for id, value := range values {
+		typed, typeErr := typedResult[T](value, nil)
+		if typeErr != nil {
+			return nil, typeErr
+		}
+		result[id] = typed
+	}
+	return result, err
+}
+
+// errNoBatchRecord marks an ID the batch fetch did not return so the per-ID
+// fallback neither caches nor reports it.
+var errNoBatchRecord = errors.New("cache: no record for id")
+
+func getOrFetchEach[T any](ctx context.Context, service CacheService, ids []string, keyFn func(id string) string, optsFn func(id string) EntryOptions, fetchFn BatchFetchFn[T]) (map[string]T, error) {
+	result := make(map[string]T, len(ids))
+	for _, id := range ids {
+		key := keyFn(id)
+		opts := optsFn(id)
+		value, err := GetOrFetchWithOptions(ctx, service, key, opts, func(ctx context.Context) (T, error) {
+			records, err := fetchFn(ctx, []string{id})
+			if err != nil {
+				var zero T
+				return zero, err
+			}
+			record, ok := records[id]
+			if !ok {
+				var zero T
+				return zero, errNoBatchRecord
+			}
+			return record, nil
+		})
+		if errors.Is(err, errNoBatchRecord) || errors.Is(err, ErrMissingRecord) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if _, ok := service.(OptionsFetcher); !ok && len(opts.Tags) > 0 {
+			if registry, ok := service.(TagRegistry); ok {
+				_ = registry.AddTags(ctx, key, opts.Tags)
+			}
+		}
+		result[id] = value
+	}
+	return result, nil
+}
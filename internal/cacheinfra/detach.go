@@ -0,0 +1,94 @@
+package cacheinfra
+
+import "context"
+
+// await runs call on a context detached from the cancellation of ctx but keeping
+// its values, so a fetch shared by deduplicated callers survives the caller that
+// started it. The caller still stops waiting when ctx is done; call then runs to
+// completion for the remaining waiters. When hit reports that call is served
+// from the cache, or ctx cannot be cancelled, call runs on the caller's goroutine.
+func await[R any](ctx context.Context, detach func(context.Context) (context.Context, context.CancelFunc), hit bool, call func(ctx context.Context) (R, error)) (R, error) {
+	var zero R
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	detached, cancel := detach(ctx)
+	if hit || ctx.Done() == nil {
+		defer cancel()
+		return call(detached)
+	}
+
+	type result struct {
+		value R
+		err   error
+		panic any
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{panic: r}
+			}
+		}()
+		value, err := call(detached)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.panic != nil {
+			// Re-raised on the caller's goroutine, where the fetch would
+			// have panicked without detaching.
+			panic(res.panic)
+		}
+		return res.value, res.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// detach returns the context a shared fetch runs on: ctx without its
+// cancellation. Without Config.FetchTimeout the deadline of ctx still applies.
+func (s *sturdycService) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok && s.fetchTimeout <= 0 {
+		return context.WithDeadline(detached, deadline)
+	}
+	return detached, func() {}
+}
+
+// cached reports whether every key holds a fresh entry, so reading them starts
+// no fetch.
+func (s *sturdycService) cached(keys ...string) bool {
+	for _, key := range keys {
+		stored, ok := s.client.Get(key)
+		if !ok {
+			return false
+		}
+		if _, fresh, _ := s.readEntry(stored); !fresh {
+			return false
+		}
+	}
+	return len(keys) > 0
+}
+
+// fetchContext bounds a fetch by Config.FetchTimeout.
+func (s *sturdycService) fetchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.fetchTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.fetchTimeout)
+}
+
+// withFetchTimeout bounds every call of fetchFn by Config.FetchTimeout.
+func (s *sturdycService) withFetchTimeout(fetchFn func(ctx context.Context, ids []string) (map[string]any, error)) func(ctx context.Context, ids []string) (map[string]any, error) {
+	if s.fetchTimeout <= 0 {
+		return fetchFn
+	}
+	return func(ctx context.Context, ids []string) (map[string]any, error) {
+		ctx, cancel := s.fetchContext(ctx)
+		defer cancel()
+		return fetchFn(ctx, ids)
+	}
+}
@@ -0,0 +1,158 @@
+package cacheinfra
+
+import (
+	"sync"
+	"time"
+)
+
+// expirySweepInterval is the shortest time between two sweeps of expired
+// entries.
+const expirySweepInterval = time.Second
+
+// expirySweep schedules the removal of entries past their own TTL, which
+// sturdyc keeps for the client TTL.
+type expirySweep struct {
+	mu sync.Mutex
+	// next is the earliest deadline of the entries stored since the last
+	// sweep. Zero means none.
+	next time.Time
+	last time.Time
+}
+
+// expiringEntry holds a value stored with a TTL shorter than the client TTL.
+// sturdyc only knows the client TTL, so the adapter checks the expiry on read.
+type expiringEntry struct {
+	value     any
+	expiresAt time.Time
+}
+
+// notFoundEntry holds a fetch error meaning the record does not exist, so hits
+// return the error the base repository reported.
+type notFoundEntry struct {
+	err       error
+	expiresAt time.Time
+}
+
+// entryTTL resolves the TTL of an entry: zero uses the default TTL and TTLs
+// above MaxTTL are capped.
+func (s *sturdycService) entryTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return s.ttl
+	}
+	if ttl > s.maxTTL {
+		return s.maxTTL
+	}
+	return ttl
+}
+
+// wrapEntry prepares value for storage with ttl. Values living as long as the
+// client TTL are stored as is.
+func (s *sturdycService) wrapEntry(value any, ttl time.Duration) any {
+	s.sweepExpired()
+	ttl = s.entryTTL(ttl)
+	if ttl >= s.maxTTL+s.maxStale {
+		return value
+	}
+	entry := &expiringEntry{value: value, expiresAt: s.now().Add(ttl)}
+	s.scheduleSweep(entry.expiresAt.Add(s.maxStale))
+	return entry
+}
+
+// notFoundEntry prepares a not found error for storage with ttl.
+func (s *sturdycService) notFoundEntry(err error, ttl time.Duration) *notFoundEntry {
+	s.sweepExpired()
+	entry := &notFoundEntry{err: err, expiresAt: s.now().Add(s.entryTTL(ttl))}
+	s.scheduleSweep(entry.expiresAt)
+	return entry
+}
+
+// entryDeadline returns when a stored entry can no longer be served, even
+// stale, and false for entries sturdyc expires itself.
+func (s *sturdycService) entryDeadline(stored any) (time.Time, bool) {
+	switch entry := stored.(type) {
+	case *expiringEntry:
+		return entry.expiresAt.Add(s.maxStale), true
+	case *notFoundEntry:
+		return entry.expiresAt, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// scheduleSweep records that an entry stored now is dead at deadline.
+func (s *sturdycService) scheduleSweep(deadline time.Time) {
+	s.sweep.mu.Lock()
+	defer s.sweep.mu.Unlock()
+	if s.sweep.next.IsZero() || deadline.Before(s.sweep.next) {
+		s.sweep.next = deadline
+	}
+}
+
+// sweepExpired deletes the entries past their deadline, so they stop taking
+// room sturdyc would otherwise make by evicting live entries. It runs before
+// entries are stored, at most once per expirySweepInterval and only when a
+// stored entry is due.
+func (s *sturdycService) sweepExpired() {
+	now := s.now()
+	s.sweep.mu.Lock()
+	if s.sweep.next.IsZero() || now.Before(s.sweep.next) || now.Sub(s.sweep.last) < expirySweepInterval {
+		s.sweep.mu.Unlock()
+		return
+	}
+	s.sweep.next = time.Time{}
+	s.sweep.last = now
+	s.sweep.mu.Unlock()
+
+	var next time.Time
+	var swept uint64
+	for _, key := range s.client.ScanKeys() {
+		stored, ok := s.client.Get(key)
+		if !ok {
+			continue
+		}
+		deadline, ok := s.entryDeadline(stored)
+		switch {
+		case !ok:
+		case !now.Before(deadline):
+			s.client.Delete(key)
+			swept++
+		case next.IsZero() || deadline.Before(next):
+			next = deadline
+		}
+	}
+	if !next.IsZero() {
+		s.scheduleSweep(next)
+	}
+	if swept > 0 && s.metrics != nil {
+		s.metrics.Add("", "", CounterEviction, swept)
+	}
+}
+
+// isNotFound reports whether err is a not found error to cache under opts.
+func (s *sturdycService) isNotFound(opts EntryOptions, err error) bool {
+	return s.storeMissing && opts.NotFound != nil && opts.NotFound(err)
+}
+
+// readEntry returns the value or not found error held by a stored entry and
+// whether the entry is still fresh.
+func (s *sturdycService) readEntry(stored any) (value any, fresh bool, err error) {
+	switch entry := stored.(type) {
+	case *expiringEntry:
+		return entry.value, s.now().Before(entry.expiresAt), nil
+	case *notFoundEntry:
+		return nil, s.now().Before(entry.expiresAt), entry.err
+	default:
+		return stored, true, nil
+	}
+}
+
+// servesStale reports whether the expired entry stored can be served after its
+// refresh failed with err.
+func (s *sturdycService) servesStale(opts EntryOptions, stored any, err error) bool {
+	entry, ok := stored.(*expiringEntry)
+	if !ok || opts.StaleIfError <= 0 || opts.Transient == nil || !opts.Transient(err) {
+		return false
+	}
+	grace := min(opts.StaleIfError, s.maxStale)
+	return s.now().Before(entry.expiresAt.Add(grace))
+}
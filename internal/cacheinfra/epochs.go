@@ -0,0 +1,81 @@
+package cacheinfra
+
+import "sync/atomic"
+
+// epochStripes is the number of counters tags are hashed onto. Two tags sharing a
+// stripe only cause spurious fill discards, never stale data.
+const epochStripes = 1024
+
+// tagEpochs tracks an invalidation generation per tag stripe. InvalidateTags bumps
+// the stripes of its tags and fills compare a snapshot taken before fetching.
+type tagEpochs struct {
+	stripes [epochStripes]atomic.Uint64
+}
+
+func (e *tagEpochs) stripe(tag string) *atomic.Uint64 {
+	return &e.stripes[tagHash(tag)%epochStripes]
+}
+
+func (e *tagEpochs) snapshot(tags []string) []uint64 {
+	snapshot := make([]uint64, len(tags))
+	for i, tag := range tags {
+		snapshot[i] = e.stripe(tag).Load()
+	}
+	return snapshot
+}
+
+// bump invalidates tags and the namespaces leading them.
+func (e *tagEpochs) bump(tags []string) {
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		e.stripe(tag).Add(1)
+		e.bumpNamespace(keyNamespace(tag))
+	}
+}
+
+// bumpNamespace records that something under namespace was invalidated.
+func (e *tagEpochs) bumpNamespace(namespace string) {
+	e.stripe(namespaceMarker(namespace)).Add(1)
+}
+
+// namespaceMarker is the epoch counted for every invalidation under namespace.
+// The leading NUL keeps it apart from tags.
+func namespaceMarker(namespace string) string {
+	return "\x00namespace" + keySeparator + namespace
+}
+
+// bumpFlush records that DeleteByPrefix deleted the entries under prefix. A
+// prefix without a namespace counts for every namespace.
+func (e *tagEpochs) bumpFlush(prefix string) {
+	e.stripe(flushMarker(keyNamespace(prefix))).Add(1)
+}
+
+// flushMarker is the epoch counted for every DeleteByPrefix under namespace, or
+// of a prefix without one when namespace is empty.
+func flushMarker(namespace string) string {
+	return "\x00flush" + keySeparator + namespace
+}
+
+// flushMarkers returns the epochs a fill of key watches for DeleteByPrefix.
+func flushMarkers(key string) []string {
+	return []string{flushMarker(keyNamespace(key)), flushMarker("")}
+}
+
+// changed reports whether any tag was invalidated since snapshot was taken.
+func (e *tagEpochs) changed(tags []string, snapshot []uint64) bool {
+	for i, tag := range tags {
+		if e.stripe(tag).Load() != snapshot[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// watch returns a function reporting whether any of tags was invalidated since
+// watch was called.
+func (e *tagEpochs) watch(tags []string) func() bool {
+	snapshot := e.snapshot(tags)
+	return func() bool { return e.changed(tags, snapshot) }
+}
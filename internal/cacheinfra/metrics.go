@@ -0,0 +1,318 @@
+package cacheinfra
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/viccon/sturdyc"
+)
+
+// Counter names a cache counter.
+type Counter string
+
+const (
+	// CounterHit counts reads served from the cache.
+	CounterHit Counter = "hits"
+	// CounterMiss counts reads that fetched from the source of truth.
+	CounterMiss Counter = "misses"
+	// CounterFill counts fetched values handed to the cache.
+	CounterFill Counter = "fills"
+	// CounterError counts reads that returned an error.
+	CounterError Counter = "errors"
+	// CounterStale counts reads served a stale value after a failed refresh.
+	CounterStale Counter = "stale"
+	// CounterInvalidation counts writes that invalidated cached entries.
+	CounterInvalidation Counter = "invalidations"
+	// CounterEviction counts entries the cache removed on expiry or to make room.
+	CounterEviction Counter = "evictions"
+	// CounterForcedEviction counts the times a full cache evicted entries to
+	// make room.
+	CounterForcedEviction Counter = "forced_evictions"
+	// CounterInvalidatedKey counts keys deleted by invalidations, whether or not
+	// they held an entry.
+	CounterInvalidatedKey Counter = "invalidated_keys"
+	// CounterRetryEnqueued counts failed invalidations queued for retry.
+	CounterRetryEnqueued Counter = "retry_enqueued"
+	// CounterRetrySucceeded counts queued invalidations a retry completed.
+	CounterRetrySucceeded Counter = "retry_succeeded"
+	// CounterRetryFailed counts retries of queued invalidations that failed.
+	CounterRetryFailed Counter = "retry_failed"
+	// CounterRetryDropped counts queued invalidations given up on.
+	CounterRetryDropped Counter = "retry_dropped"
+	// CounterRetryOverflow counts invalidations that found the retry queue full.
+	CounterRetryOverflow Counter = "retry_overflow"
+)
+
+// Histogram names a cache latency histogram.
+type Histogram string
+
+const (
+	// HistogramRead measures whole reads, served from the cache or not.
+	HistogramRead Histogram = "read_latency"
+	// HistogramFetch measures fetches from the source of truth.
+	HistogramFetch Histogram = "fetch_latency"
+)
+
+// Metrics receives cache measurements keyed by namespace and method. Either may
+// be empty when the measurement is not specific to one. Implementations must be
+// safe for concurrent use.
+type Metrics interface {
+	// Add increases counter by delta.
+	Add(namespace, method string, counter Counter, delta uint64)
+	// Observe records d in histogram.
+	Observe(namespace, method string, histogram Histogram, d time.Duration)
+}
+
+// DefaultLatencyBuckets are the histogram bucket upper bounds used by
+// NewMemoryMetrics when none are given.
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// MemoryMetrics is a Metrics implementation keeping every series in memory.
+type MemoryMetrics struct {
+	buckets []time.Duration
+
+	mu     sync.Mutex
+	series map[metricKey]*memorySeries
+}
+
+type metricKey struct {
+	namespace string
+	method    string
+}
+
+type memorySeries struct {
+	counters   map[Counter]uint64
+	histograms map[Histogram]*memoryHistogram
+}
+
+type memoryHistogram struct {
+	count   uint64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets []uint64
+}
+
+// NewMemoryMetrics creates an empty MemoryMetrics whose histograms use the given
+// bucket upper bounds, or DefaultLatencyBuckets when none are given.
+func NewMemoryMetrics(buckets ...time.Duration) *MemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	bounds := append([]time.Duration(nil), buckets...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &MemoryMetrics{
+		buckets: bounds,
+		series:  make(map[metricKey]*memorySeries),
+	}
+}
+
+// Add implements Metrics.Add.
+func (m *MemoryMetrics) Add(namespace, method string, counter Counter, delta uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesFor(namespace, method).counters[counter] += delta
+}
+
+// Observe implements Metrics.Observe.
+func (m *MemoryMetrics) Observe(namespace, method string, histogram Histogram, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series := m.seriesFor(namespace, method)
+	h, ok := series.histograms[histogram]
+	if !ok {
+		h = &memoryHistogram{buckets: make([]uint64, len(m.buckets))}
+		series.histograms[histogram] = h
+	}
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+	if i := sort.Search(len(m.buckets), func(i int) bool { return d <= m.buckets[i] }); i < len(m.buckets) {
+		h.buckets[i]++
+	}
+}
+
+func (m *MemoryMetrics) seriesFor(namespace, method string) *memorySeries {
+	key := metricKey{namespace: namespace, method: method}
+	series, ok := m.series[key]
+	if !ok {
+		series = &memorySeries{
+			counters:   make(map[Counter]uint64),
+			histograms: make(map[Histogram]*memoryHistogram),
+		}
+		m.series[key] = series
+	}
+	return series
+}
+
+// Reset discards every recorded measurement.
+func (m *MemoryMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = make(map[metricKey]*memorySeries)
+}
+
+// Snapshot returns a copy of every series, sorted by namespace and method.
+func (m *MemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{Series: make([]SeriesSnapshot, 0, len(m.series))}
+	for key, series := range m.series {
+		s := SeriesSnapshot{
+			Namespace:  key.namespace,
+			Method:     key.method,
+			Counters:   make(map[Counter]uint64, len(series.counters)),
+			Histograms: make(map[Histogram]HistogramSnapshot, len(series.histograms)),
+		}
+		for counter, value := range series.counters {
+			s.Counters[counter] = value
+		}
+		for name, h := range series.histograms {
+			s.Histograms[name] = m.histogramSnapshot(h)
+		}
+		snapshot.Series = append(snapshot.Series, s)
+	}
+	sort.Slice(snapshot.Series, func(i, j int) bool {
+		a, b := snapshot.Series[i], snapshot.Series[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Method < b.Method
+	})
+	return snapshot
+}
+
+func (m *MemoryMetrics) histogramSnapshot(h *memoryHistogram) HistogramSnapshot {
+	snapshot := HistogramSnapshot{
+		Count:   h.count,
+		Sum:     h.sum,
+		Min:     h.min,
+		Max:     h.max,
+		Buckets: make([]BucketSnapshot, len(m.buckets)),
+	}
+	var cumulative uint64
+	for i, bound := range m.buckets {
+		cumulative += h.buckets[i]
+		snapshot.Buckets[i] = BucketSnapshot{UpperBound: bound, Count: cumulative}
+	}
+	return snapshot
+}
+
+// MetricsSnapshot is a point-in-time copy of a MemoryMetrics.
+type MetricsSnapshot struct {
+	Series []SeriesSnapshot `json:"series"`
+}
+
+// Find returns the series of namespace and method.
+func (s MetricsSnapshot) Find(namespace, method string) (SeriesSnapshot, bool) {
+	for _, series := range s.Series {
+		if series.Namespace == namespace && series.Method == method {
+			return series, true
+		}
+	}
+	return SeriesSnapshot{}, false
+}
+
+// Counter returns the value of counter in the series of namespace and method.
+func (s MetricsSnapshot) Counter(namespace, method string, counter Counter) uint64 {
+	series, _ := s.Find(namespace, method)
+	return series.Counters[counter]
+}
+
+// SeriesSnapshot holds the measurements of one namespace and method.
+type SeriesSnapshot struct {
+	Namespace  string                          `json:"namespace"`
+	Method     string                          `json:"method"`
+	Counters   map[Counter]uint64              `json:"counters"`
+	Histograms map[Histogram]HistogramSnapshot `json:"histograms"`
+}
+
+// HistogramSnapshot summarizes the durations recorded in a histogram. Bucket
+// counts are cumulative; durations above the last bound only count towards
+// Count, Sum and Max.
+type HistogramSnapshot struct {
+	Count   uint64           `json:"count"`
+	Sum     time.Duration    `json:"sum"`
+	Min     time.Duration    `json:"min"`
+	Max     time.Duration    `json:"max"`
+	Buckets []BucketSnapshot `json:"buckets"`
+}
+
+// Mean returns the average recorded duration.
+func (h HistogramSnapshot) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// BucketSnapshot counts the durations up to UpperBound.
+type BucketSnapshot struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      uint64        `json:"count"`
+}
+
+// keySeparator matches cache.KeySeparator, which leads every key with its
+// namespace.
+const keySeparator = "::"
+
+// keyNamespace returns the namespace segment of key.
+func keyNamespace(key string) string {
+	namespace, _, found := strings.Cut(key, keySeparator)
+	if !found {
+		return ""
+	}
+	return namespace
+}
+
+var _ sturdyc.MetricsRecorder = evictionRecorder{}
+
+// evictionRecorder reports the entries sturdyc evicts, on expiry or to make room,
+// as evictions without a namespace, and the times it made room as forced
+// evictions. It implements sturdyc.MetricsRecorder.
+type evictionRecorder struct {
+	metrics Metrics
+}
+
+func (r evictionRecorder) CacheHit()                   {}
+func (r evictionRecorder) CacheMiss()                  {}
+func (r evictionRecorder) AsynchronousRefresh()        {}
+func (r evictionRecorder) SynchronousRefresh()         {}
+func (r evictionRecorder) MissingRecord()              {}
+func (r evictionRecorder) ShardIndex(int)              {}
+func (r evictionRecorder) CacheBatchRefreshSize(int)   {}
+func (r evictionRecorder) ObserveCacheSize(func() int) {}
+func (r evictionRecorder) ForcedEviction() {
+	r.metrics.Add("", "", CounterForcedEviction, 1)
+}
+
+func (r evictionRecorder) EntriesEvicted(evicted int) {
+	if evicted > 0 {
+		r.metrics.Add("", "", CounterEviction, uint64(evicted))
+	}
+}
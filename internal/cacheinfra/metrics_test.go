@@ -0,0 +1,58 @@
+package cacheinfra
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryMetrics(t *testing.T) {
+	metrics := NewMemoryMetrics(10*time.Millisecond, time.Millisecond)
+
+	metrics.Add("users", "GetByID", CounterHit, 2)
+	metrics.Add("users", "GetByID", CounterHit, 1)
+	metrics.Add("users", "GetByID", CounterMiss, 1)
+	metrics.Add("", "", CounterEviction, 5)
+	metrics.Observe("users", "GetByID", HistogramRead, 500*time.Microsecond)
+	metrics.Observe("users", "GetByID", HistogramRead, 5*time.Millisecond)
+	metrics.Observe("users", "GetByID", HistogramRead, time.Second)
+
+	snapshot := metrics.Snapshot()
+	if len(snapshot.Series) != 2 || snapshot.Series[0].Namespace != "" || snapshot.Series[1].Namespace != "users" {
+		t.Fatalf("expected series sorted by namespace, got %+v", snapshot.Series)
+	}
+	if hits := snapshot.Counter("users", "GetByID", CounterHit); hits != 3 {
+		t.Fatalf("expected 3 hits, got %d", hits)
+	}
+	if evictions := snapshot.Counter("", "", CounterEviction); evictions != 5 {
+		t.Fatalf("expected 5 evictions, got %d", evictions)
+	}
+	if missing := snapshot.Counter("orders", "List", CounterHit); missing != 0 {
+		t.Fatalf("expected zero for an unknown series, got %d", missing)
+	}
+
+	series, ok := snapshot.Find("users", "GetByID")
+	if !ok {
+		t.Fatal("expected the users series")
+	}
+	read := series.Histograms[HistogramRead]
+	if read.Count != 3 || read.Min != 500*time.Microsecond || read.Max != time.Second {
+		t.Fatalf("unexpected histogram summary: %+v", read)
+	}
+	if read.Mean() != (500*time.Microsecond+5*time.Millisecond+time.Second)/3 {
+		t.Fatalf("unexpected mean: %v", read.Mean())
+	}
+	want := []BucketSnapshot{{UpperBound: time.Millisecond, Count: 1}, {UpperBound: 10 * time.Millisecond, Count: 2}}
+	if len(read.Buckets) != len(want) || read.Buckets[0] != want[0] || read.Buckets[1] != want[1] {
+		t.Fatalf("expected cumulative sorted buckets %v, got %v", want, read.Buckets)
+	}
+
+	metrics.Observe("users", "GetByID", HistogramRead, time.Millisecond)
+	if got := snapshot.Series[1].Histograms[HistogramRead].Count; got != 3 {
+		t.Fatalf("expected the snapshot to be a copy, got count %d", got)
+	}
+
+	metrics.Reset()
+	if series := metrics.Snapshot().Series; len(series) != 0 {
+		t.Fatalf("expected Reset to discard every series, got %+v", series)
+	}
+}
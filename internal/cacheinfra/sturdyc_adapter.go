@@ -2,9 +2,9 @@ package cacheinfra
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/viccon/sturdyc"
@@ -27,6 +27,28 @@ type Config struct {
 	// Must be greater than 0.
 	TTL time.Duration
 
+	// MaxTTL is the longest per-entry TTL honored through EntryOptions.TTL.
+	// Longer TTLs are capped at it. Zero means TTL, so entries can only
+	// expire earlier than TTL. Missing record markers live for MaxTTL.
+	// Must be zero or at least TTL.
+	//
+	// Entries with a shorter TTL are held for MaxTTL plus MaxStale and
+	// expired by the adapter, which deletes them when they are read and
+	// sweeps them, at most once a second, before storing new entries. Until
+	// then they count against Capacity.
+	MaxTTL time.Duration
+
+	// FetchTimeout bounds every fetch from the source of truth. Fetches run
+	// on a context detached from the cancellation of the caller that started
+	// them. Zero keeps the deadline of that caller's context instead.
+	// Must be non-negative.
+	FetchTimeout time.Duration
+
+	// MaxStale is how long expired entries are kept so they can be served when
+	// their refresh fails, as requested by EntryOptions.StaleIfError. Zero
+	// disables serving stale values. Must be non-negative.
+	MaxStale time.Duration
+
 	// EvictionPercentage specifies what percentage of entries to evict
 	// when the cache reaches its capacity. Must be between 1-100.
 	// Default: 10 (evict 10% of entries)
@@ -44,6 +66,11 @@ type Config struct {
 	// EvictionInterval sets how often the cache checks for expired entries.
 	// Zero value uses the default interval.
 	EvictionInterval time.Duration
+
+	// Metrics receives the entries evicted from the cache on expiry or to
+	// make room, without a namespace, and the keys deleted by invalidation
+	// under the namespace leading them. Nil records nothing.
+	Metrics Metrics
 }
 
 // EarlyRefreshConfig configures early refresh behavior.
@@ -108,6 +135,11 @@ func (c Config) ToSturdycOptions() []sturdyc.Option {
 		options = append(options, sturdyc.WithEvictionInterval(c.EvictionInterval))
 	}
 
+	// Report evictions if a metrics sink is configured
+	if c.Metrics != nil {
+		options = append(options, sturdyc.WithMetrics(evictionRecorder{metrics: c.Metrics}))
+	}
+
 	return options
 }
 
@@ -126,6 +158,18 @@ func (c Config) Validate() error {
 		return &ConfigError{Field: "TTL", Message: "must be greater than 0"}
 	}
 
+	if c.MaxTTL < 0 || (c.MaxTTL > 0 && c.MaxTTL < c.TTL) {
+		return &ConfigError{Field: "MaxTTL", Message: "must be zero or at least TTL"}
+	}
+
+	if c.FetchTimeout < 0 {
+		return &ConfigError{Field: "FetchTimeout", Message: "must be non-negative"}
+	}
+
+	if c.MaxStale < 0 {
+		return &ConfigError{Field: "MaxStale", Message: "must be non-negative"}
+	}
+
 	if c.EvictionPercentage < 1 || c.EvictionPercentage > 100 {
 		return &ConfigError{Field: "EvictionPercentage", Message: "must be between 1 and 100"}
 	}
@@ -161,15 +205,65 @@ func (e *ConfigError) Error() string {
 
 // sturdycService wraps a sturdyc client providing caching behaviour.
 type sturdycService struct {
-	client *sturdyc.Client[any]
-	tagMu  sync.Mutex
+	client   *sturdyc.Client[any]
+	tags     *tagIndex
+	epochs   tagEpochs
+	ttl      time.Duration
+	maxTTL   time.Duration
+	maxStale time.Duration
+	now      func() time.Time
+	sweep    expirySweep
+
+	fetchTimeout time.Duration
+	metrics      Metrics
+
+	storeMissing bool
+}
+
+// EntryOptions carries per-entry hints for GetOrFetchWithOptions.
+type EntryOptions struct {
+	// Tags lists the invalidation tags the entry depends on.
+	Tags []string
+	// TTL is how long the entry lives. Zero uses the default TTL.
+	TTL time.Duration
+	// NotFound reports whether a fetch error means the record does not exist.
+	// Such errors are cached for NotFoundTTL and returned again on hits when
+	// missing record storage is enabled. Nil caches no errors.
+	NotFound func(err error) bool
+	// NotFoundTTL is how long not found errors are cached. Zero uses the
+	// default TTL.
+	NotFoundTTL time.Duration
+	// StaleIfError is how long after expiring the entry can still be served
+	// when its refresh fails with an error Transient accepts. The stale value
+	// is returned inside a StaleError. It is capped at Config.MaxStale; zero
+	// never serves stale values.
+	StaleIfError time.Duration
+	// Transient reports whether a refresh error allows serving a stale value.
+	Transient func(err error) bool
+}
+
+// StaleError is returned with an expired value served because its refresh
+// failed. Err is the refresh error.
+type StaleError struct {
+	Value any
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *StaleError) Error() string {
+	return "cacheinfra: serving stale value: " + e.Err.Error()
+}
+
+// Unwrap returns the refresh error.
+func (e *StaleError) Unwrap() error {
+	return e.Err
 }
 
 // NewSturdycService creates a new sturdyc cache service adapter.
 // It validates the configuration and initializes a sturdyc client with the provided settings.
 //
 // The constructor translates Config parameters to sturdyc initialization:
-// - Capacity, NumShards, TTL, EvictionPercentage are passed to sturdyc.New()
+// - Capacity, NumShards, the longer of TTL and MaxTTL plus MaxStale, and EvictionPercentage are passed to sturdyc.New()
 // - Other options are applied via ToSturdycOptions()
 //
 // Version compatibility note: This implementation assumes sturdyc v1.x API.
@@ -179,16 +273,36 @@ func NewSturdycService(cfg Config) (*sturdycService, error) {
 		return nil, err
 	}
 
+	// sturdyc expires every entry after the client TTL, so the client keeps
+	// entries for the longest TTL plus the stale window, and shorter ones are
+	// enforced by the adapter.
+	maxTTL := cfg.MaxTTL
+	if maxTTL < cfg.TTL {
+		maxTTL = cfg.TTL
+	}
+	clientTTL := maxTTL + cfg.MaxStale
+
 	// Create sturdyc client with core parameters
 	client := sturdyc.New[any](
 		cfg.Capacity,
 		cfg.NumShards,
-		cfg.TTL,
+		clientTTL,
 		cfg.EvictionPercentage,
 		cfg.ToSturdycOptions()...,
 	)
 
-	return &sturdycService{client: client}, nil
+	return &sturdycService{
+		client:   client,
+		tags:     newTagIndex(cfg.NumShards, clientTTL*tagRetentionFactor),
+		ttl:      cfg.TTL,
+		maxTTL:   maxTTL,
+		maxStale: cfg.MaxStale,
+		now:      time.Now,
+
+		storeMissing: cfg.MissingRecordStorage,
+		fetchTimeout: cfg.FetchTimeout,
+		metrics:      cfg.Metrics,
+	}, nil
 }
 
 // GetOrFetch implements cache.CacheService.GetOrFetch.
@@ -238,14 +352,285 @@ func (s *sturdycService) GetOrFetch(ctx context.Context, key string, fetchFn any
 		return nil, err
 	}
 
-	// Use reflection to create a wrapper that calls the generic fetchFn
-	// and returns the result as any type for sturdyc compatibility
+	return await(ctx, s.detach, s.cached(key), func(ctx context.Context) (any, error) {
+		return s.getOrFetchWithOptions(ctx, key, EntryOptions{}, fetchFn)
+	})
+}
+
+// getOrFetch reads key through the sturdyc client and stores fetched values with
+// opts.TTL, and not found errors with opts.NotFoundTTL. An entry found past its
+// own TTL is deleted and fetched again; when that refresh fails, the expired
+// value may be served stale per opts.StaleIfError. restorable reports whether a
+// stale entry may be stored back; nil means always.
+func (s *sturdycService) getOrFetch(ctx context.Context, key string, opts EntryOptions, restorable func() bool, fetchFn func(ctx context.Context) (any, error)) (any, error) {
+	storeFn := func(ctx context.Context) (any, error) {
+		ctx, cancel := s.fetchContext(ctx)
+		defer cancel()
+		value, err := fetchFn(ctx)
+		if err != nil {
+			if s.isNotFound(opts, err) {
+				return s.notFoundEntry(err, opts.NotFoundTTL), nil
+			}
+			return value, err
+		}
+		return s.wrapEntry(value, opts.TTL), nil
+	}
+
+	stored, err := s.client.GetOrFetch(ctx, key, storeFn)
+	if err != nil {
+		return stored, err
+	}
+	value, fresh, err := s.readEntry(stored)
+	if fresh {
+		return value, err
+	}
+	s.client.Delete(key)
+	refreshed, refreshErr := s.client.GetOrFetch(ctx, key, storeFn)
+	if refreshErr != nil {
+		if !s.servesStale(opts, stored, refreshErr) {
+			return refreshed, refreshErr
+		}
+		// Keep the entry, still expired, so later reads serve it too while
+		// they retry the refresh.
+		if restorable == nil || restorable() {
+			s.client.Set(key, stored)
+			if restorable != nil && !restorable() {
+				s.client.Delete(key)
+			}
+		}
+		return nil, &StaleError{Value: value, Err: refreshErr}
+	}
+	value, _, err = s.readEntry(refreshed)
+	return value, err
+}
+
+// GetOrFetchWithOptions implements cache.OptionsFetcher.GetOrFetchWithOptions.
+// The key is registered under opts.Tags before fetching. A fill is dropped instead
+// of stored when any of those tags is invalidated, or the namespace of the key is
+// flushed with DeleteByPrefix, while the fetch runs, so a read that started before
+// a write cannot put the old value back after InvalidateTags. The fetched value is
+// still returned to the caller.
+func (s *sturdycService) GetOrFetchWithOptions(ctx context.Context, key string, opts EntryOptions, fetchFn any) (any, error) {
+	if err := validateFetchFn(fetchFn); err != nil {
+		return nil, err
+	}
+	return await(ctx, s.detach, s.cached(key), func(ctx context.Context) (any, error) {
+		return s.getOrFetchWithOptions(ctx, key, opts, fetchFn)
+	})
+}
+
+func (s *sturdycService) getOrFetchWithOptions(ctx context.Context, key string, opts EntryOptions, fetchFn any) (any, error) {
+	tags := nonEmptyTags(opts.Tags)
+	// A fill is also dropped when a DeleteByPrefix flushes its namespace.
+	guards := append(flushMarkers(key), tags...)
+	snapshot := s.epochs.snapshot(guards)
+	if err := s.AddTags(ctx, key, tags); err != nil {
+		return nil, err
+	}
+
 	typedFetchFn := func(ctx context.Context) (any, error) {
-		return callFetchFunctionWithReflection(ctx, fetchFn)
+		value, err := callFetchFunctionWithReflection(ctx, fetchFn)
+		if err != nil && !s.isNotFound(opts, err) {
+			return value, err
+		}
+		if s.epochs.changed(guards, snapshot) {
+			// sturdyc only stores successful fetches, so the value travels
+			// back to the caller inside the error. sturdyc rejects nil
+			// responses, hence the error doubles as the response.
+			stale := &staleFillError{value: value, err: err}
+			return stale, stale
+		}
+		return value, err
+	}
+
+	unchanged := func() bool { return !s.epochs.changed(guards, snapshot) }
+	value, err := s.getOrFetch(ctx, key, opts, unchanged, typedFetchFn)
+	var stale *staleFillError
+	if errors.As(err, &stale) {
+		return stale.value, stale.err
+	}
+	if (err == nil || s.isNotFound(opts, err)) && s.epochs.changed(guards, snapshot) {
+		// An invalidation landed between the epoch check and the store.
+		s.client.Delete(key)
+	}
+	return value, err
+}
+
+// Set implements cache.EntryWriter.Set. The key is registered under opts.Tags
+// before the value is stored, so an invalidation of those tags evicts it.
+func (s *sturdycService) Set(ctx context.Context, key string, value any, opts EntryOptions) error {
+	if tags := nonEmptyTags(opts.Tags); len(tags) > 0 {
+		s.tags.add(key, tags)
 	}
+	s.client.Set(key, s.wrapEntry(value, opts.TTL))
+	return nil
+}
 
-	// Use sturdyc's GetOrFetch with the typed function
-	return s.client.GetOrFetch(ctx, key, typedFetchFn)
+// DefaultTTL implements cache.TTLReporter.DefaultTTL.
+func (s *sturdycService) DefaultTTL() time.Duration {
+	return s.ttl
+}
+
+// MaxTTL implements cache.TTLReporter.MaxTTL. It is Config.MaxTTL, or Config.TTL
+// when unset.
+func (s *sturdycService) MaxTTL() time.Duration {
+	return s.maxTTL
+}
+
+// ErrMissingRecord is returned for keys marked as missing by a batch fetch.
+var ErrMissingRecord = sturdyc.ErrMissingRecord
+
+// GetOrFetchBatch implements cache.BatchFetcher.GetOrFetchBatch using sturdyc's
+// batch fetch. Every ID is registered under optsFn(id).Tags before fetching. When
+// any of those tags is invalidated while the fetch runs, the fetched records are
+// returned but none of them are stored.
+func (s *sturdycService) GetOrFetchBatch(
+	ctx context.Context,
+	ids []string,
+	keyFn func(id string) string,
+	optsFn func(id string) EntryOptions,
+	fetchFn func(ctx context.Context, ids []string) (map[string]any, error),
+) (map[string]any, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = keyFn(id)
+	}
+	return await(ctx, s.detach, s.cached(keys...), func(ctx context.Context) (map[string]any, error) {
+		return s.getOrFetchBatch(ctx, ids, keyFn, optsFn, fetchFn)
+	})
+}
+
+func (s *sturdycService) getOrFetchBatch(
+	ctx context.Context,
+	ids []string,
+	keyFn func(id string) string,
+	optsFn func(id string) EntryOptions,
+	fetchFn func(ctx context.Context, ids []string) (map[string]any, error),
+) (map[string]any, error) {
+	fetchFn = s.withFetchTimeout(fetchFn)
+	ttlByID := make(map[string]time.Duration, len(ids))
+	guardsByID := make(map[string][]string, len(ids))
+	snapshots := make(map[string][]uint64, len(ids))
+	for _, id := range ids {
+		opts := optsFn(id)
+		ttlByID[id] = opts.TTL
+		key := keyFn(id)
+		tags := nonEmptyTags(opts.Tags)
+		guards := append(flushMarkers(key), tags...)
+		guardsByID[id] = guards
+		snapshots[id] = s.epochs.snapshot(guards)
+		if len(tags) > 0 {
+			s.tags.add(key, tags)
+		}
+	}
+
+	changed := func(id string) bool {
+		guards, ok := guardsByID[id]
+		return ok && s.epochs.changed(guards, snapshots[id])
+	}
+
+	wrappedFetch := func(ctx context.Context, missing []string) (map[string]any, error) {
+		values, err := fetchFn(ctx, missing)
+		if err != nil {
+			return values, err
+		}
+		for _, id := range missing {
+			if changed(id) {
+				// sturdyc returns records fetched alongside an error without
+				// storing them.
+				return values, &staleFillError{value: values}
+			}
+		}
+		stored := make(map[string]any, len(values))
+		for id, value := range values {
+			stored[id] = s.wrapEntry(value, ttlByID[id])
+		}
+		return stored, nil
+	}
+
+	fetchBatch := func(ids []string) (map[string]any, error) {
+		values, err := s.client.GetOrFetchBatch(ctx, ids, keyFn, wrappedFetch)
+		var stale *staleFillError
+		if errors.As(err, &stale) {
+			// Requests deduplicated onto other in-flight batches may be cut
+			// short by the discarded fill, so fetch whatever is still
+			// missing directly.
+			var missing []string
+			for _, id := range ids {
+				if _, ok := values[id]; !ok {
+					missing = append(missing, id)
+				}
+			}
+			if len(missing) == 0 {
+				return values, nil
+			}
+			fetched, fetchErr := fetchFn(ctx, missing)
+			if values == nil {
+				values = make(map[string]any, len(fetched))
+			}
+			for id, value := range fetched {
+				values[id] = value
+			}
+			return values, fetchErr
+		}
+		if err == nil {
+			for _, id := range ids {
+				if changed(id) {
+					s.client.Delete(keyFn(id))
+				}
+			}
+		}
+		return values, err
+	}
+
+	values, err := fetchBatch(ids)
+	var expired []string
+	for id, stored := range values {
+		value, fresh, notFound := s.readEntry(stored)
+		switch {
+		case !fresh:
+			expired = append(expired, id)
+			delete(values, id)
+			s.client.Delete(keyFn(id))
+		case notFound != nil:
+			// A cached not found error: the ID has no record.
+			delete(values, id)
+		default:
+			values[id] = value
+		}
+	}
+	if err != nil || len(expired) == 0 {
+		return values, err
+	}
+
+	refetched, err := fetchBatch(expired)
+	for id, stored := range refetched {
+		if value, _, notFound := s.readEntry(stored); notFound == nil {
+			values[id] = value
+		}
+	}
+	return values, err
+}
+
+// staleFillError marks a fetched value that must not be stored because its
+// tags were invalidated during the fetch.
+type staleFillError struct {
+	value any
+	err   error
+}
+
+func (e *staleFillError) Error() string {
+	return "cacheinfra: fill discarded after concurrent invalidation"
+}
+
+func nonEmptyTags(tags []string) []string {
+	filtered := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != "" {
+			filtered = append(filtered, tag)
+		}
+	}
+	return filtered
 }
 
 // callFetchFunctionWithReflection uses reflection to call any function that matches
@@ -288,51 +673,29 @@ func callFetchFunctionWithReflection(ctx context.Context, fetchFn any) (any, err
 // Removes a single entry from the cache using the provided key.
 // This ensures subsequent GetOrFetch calls will fetch fresh data from the source.
 func (s *sturdycService) Delete(ctx context.Context, key string) error {
-	s.client.Delete(key)
+	s.epochs.bumpNamespace(keyNamespace(key))
+	s.evict(key)
 	return nil
 }
 
-func tagRegistryKey(tag string) string {
-	return "tag::" + tag
-}
-
-func (s *sturdycService) loadTagRegistry(key string) map[string]struct{} {
-	value, ok := s.client.Get(key)
-	if !ok || value == nil {
-		return nil
-	}
-
-	switch typed := value.(type) {
-	case map[string]struct{}:
-		return typed
-	case map[string]bool:
-		entries := make(map[string]struct{}, len(typed))
-		for k := range typed {
-			entries[k] = struct{}{}
-		}
-		return entries
-	case []string:
-		entries := make(map[string]struct{}, len(typed))
-		for _, k := range typed {
-			entries[k] = struct{}{}
-		}
-		return entries
-	default:
-		return nil
-	}
-}
-
 // DeleteByPrefix implements cache.CacheService.DeleteByPrefix.
 // Removes all entries from the cache that have keys starting with the given prefix.
 // This is useful for invalidating related cache entries (e.g., all entries for a specific entity).
 func (s *sturdycService) DeleteByPrefix(ctx context.Context, prefix string) error {
+	// Bump before deleting so fills that miss the delete see the new epoch.
+	s.epochs.bumpFlush(prefix)
+	if namespace := keyNamespace(prefix); namespace != "" {
+		s.epochs.bumpNamespace(namespace)
+	}
+
 	// Get all keys from the cache
 	keys := s.client.ScanKeys()
 
 	// Delete keys that match the prefix
 	for _, key := range keys {
 		if strings.HasPrefix(key, prefix) {
-			s.client.Delete(key)
+			s.epochs.bumpNamespace(keyNamespace(key))
+			s.evict(key)
 		}
 	}
 
@@ -340,28 +703,12 @@ func (s *sturdycService) DeleteByPrefix(ctx context.Context, prefix string) erro
 }
 
 // AddTags implements cache.TagRegistry.AddTags.
-// Stores tag registries inside the cache using keys prefixed with "tag::".
+// Registrations are kept in a dedicated tag index rather than in the value cache.
 func (s *sturdycService) AddTags(ctx context.Context, key string, tags []string) error {
 	if key == "" || len(tags) == 0 {
 		return nil
 	}
-
-	s.tagMu.Lock()
-	defer s.tagMu.Unlock()
-
-	for _, tag := range tags {
-		if tag == "" {
-			continue
-		}
-		registryKey := tagRegistryKey(tag)
-		registry := s.loadTagRegistry(registryKey)
-		if registry == nil {
-			registry = make(map[string]struct{})
-		}
-		registry[key] = struct{}{}
-		s.client.Set(registryKey, registry)
-	}
-
+	s.tags.add(key, tags)
 	return nil
 }
 
@@ -372,19 +719,13 @@ func (s *sturdycService) InvalidateTags(ctx context.Context, tags []string) erro
 		return nil
 	}
 
-	s.tagMu.Lock()
-	defer s.tagMu.Unlock()
+	// Bump before deleting so fills that miss the delete see the new epoch.
+	s.epochs.bump(tags)
 
 	for _, tag := range tags {
-		if tag == "" {
-			continue
-		}
-		registryKey := tagRegistryKey(tag)
-		registry := s.loadTagRegistry(registryKey)
-		for key := range registry {
-			s.client.Delete(key)
+		for _, key := range s.tags.take(tag) {
+			s.evict(key)
 		}
-		s.client.Delete(registryKey)
 	}
 
 	return nil
@@ -396,7 +737,29 @@ func (s *sturdycService) InvalidateTags(ctx context.Context, tags []string) erro
 // in a single operation.
 func (s *sturdycService) InvalidateKeys(ctx context.Context, keys []string) error {
 	for _, key := range keys {
-		s.client.Delete(key)
+		s.epochs.bumpNamespace(keyNamespace(key))
+		s.evict(key)
 	}
 	return nil
 }
+
+// WatchTags implements cache.TagWatcher.WatchTags.
+func (s *sturdycService) WatchTags(tags []string) func() bool {
+	return s.epochs.watch(nonEmptyTags(tags))
+}
+
+// WatchNamespace implements cache.TagWatcher.WatchNamespace. Invalidating a tag
+// or deleting a key counts for the namespace leading it, and deleting a prefix
+// without a namespace counts for every namespace.
+func (s *sturdycService) WatchNamespace(namespace string) func() bool {
+	return s.epochs.watch([]string{namespaceMarker(namespace), flushMarker("")})
+}
+
+// evict deletes key on behalf of an invalidation, reporting it as an
+// invalidated key.
+func (s *sturdycService) evict(key string) {
+	if s.metrics != nil {
+		s.metrics.Add(keyNamespace(key), "", CounterInvalidatedKey, 1)
+	}
+	s.client.Delete(key)
+}
@@ -3,6 +3,7 @@ package cacheinfra
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -135,6 +136,42 @@ func TestConfig_Validate(t *testing.T) {
 			wantError: true,
 			errorMsg:  "must be between 1 and 100",
 		},
+		{
+			name: "invalid max TTL - below TTL",
+			cfg: Config{
+				Capacity:           1000,
+				NumShards:          256,
+				TTL:                5 * time.Minute,
+				MaxTTL:             time.Minute,
+				EvictionPercentage: 10,
+			},
+			wantError: true,
+			errorMsg:  "must be zero or at least TTL",
+		},
+		{
+			name: "invalid fetch timeout - negative",
+			cfg: Config{
+				Capacity:           1000,
+				NumShards:          256,
+				TTL:                5 * time.Minute,
+				FetchTimeout:       -time.Second,
+				EvictionPercentage: 10,
+			},
+			wantError: true,
+			errorMsg:  "must be non-negative",
+		},
+		{
+			name: "invalid max stale - negative",
+			cfg: Config{
+				Capacity:           1000,
+				NumShards:          256,
+				TTL:                5 * time.Minute,
+				MaxStale:           -time.Minute,
+				EvictionPercentage: 10,
+			},
+			wantError: true,
+			errorMsg:  "must be non-negative",
+		},
 		{
 			name: "invalid early refresh min async time",
 			cfg: Config{
@@ -204,6 +241,11 @@ func TestConfig_ToSturdycOptions(t *testing.T) {
 		t.Errorf("expected no sturdyc options for minimal config, got %d", len(minimalOptions))
 	}
 
+	minimalCfg.Metrics = NewMemoryMetrics()
+	if metricsOptions := minimalCfg.ToSturdycOptions(); len(metricsOptions) != 1 {
+		t.Errorf("expected 1 sturdyc option for metrics config, got %d", len(metricsOptions))
+	}
+
 	// Test with only missing record storage enabled
 	missingRecordCfg := Config{
 		Capacity:             1000,
@@ -791,17 +833,14 @@ func TestSturdycService_TagRegistry(t *testing.T) {
 		t.Fatalf("expected no error adding empty tags: %v", err)
 	}
 
-	registryValue, ok := service.client.Get(tagRegistryKey(tagA))
-	if !ok {
-		t.Fatalf("expected tag registry for %s to exist", tagA)
-	}
-
-	registry, ok := registryValue.(map[string]struct{})
-	if !ok {
-		t.Fatalf("expected tag registry to be map[string]struct{}, got %T", registryValue)
+	registered := service.tags.keys(tagA)
+	if len(registered) != 1 || registered[0] != key1 {
+		t.Fatalf("expected key1 to be registered under tag %s, got %v", tagA, registered)
 	}
-	if _, exists := registry[key1]; !exists {
-		t.Fatalf("expected key1 to be registered under tag %s", tagA)
+	for _, key := range service.client.ScanKeys() {
+		if key != key1 && key != key2 {
+			t.Fatalf("expected tag registrations to stay out of the value cache, found %s", key)
+		}
 	}
 
 	if err := service.InvalidateTags(ctx, []string{tagA}); err != nil {
@@ -822,9 +861,806 @@ func TestSturdycService_TagRegistry(t *testing.T) {
 	if _, ok := service.client.Get(key2); !ok {
 		t.Fatalf("expected key2 to remain cached for tag %s", tagB)
 	}
-	if _, ok := service.client.Get(tagRegistryKey(tagA)); ok {
-		t.Fatalf("expected tag registry for %s to be removed", tagA)
+	if registered := service.tags.keys(tagA); len(registered) != 0 {
+		t.Fatalf("expected tag registry for %s to be removed, got %v", tagA, registered)
+	}
+}
+
+func TestSturdycService_GetOrFetchWithOptions(t *testing.T) {
+	cfg := Config{
+		Capacity:           100,
+		NumShards:          2,
+		TTL:                1 * time.Minute,
+		EvictionPercentage: 10,
+	}
+	ctx := context.Background()
+	tag := "user::id:user-1"
+
+	t.Run("registers tags and stores fill", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		value, err := service.GetOrFetchWithOptions(ctx, "user::GetByID:1", EntryOptions{Tags: []string{tag, ""}}, func(ctx context.Context) (string, error) {
+			return "fresh", nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value != "fresh" {
+			t.Fatalf("expected fresh, got %v", value)
+		}
+		if _, ok := service.client.Get("user::GetByID:1"); !ok {
+			t.Fatal("expected fill to be stored")
+		}
+
+		if err := service.InvalidateTags(ctx, []string{tag}); err != nil {
+			t.Fatalf("failed to invalidate tag: %v", err)
+		}
+		if _, ok := service.client.Get("user::GetByID:1"); ok {
+			t.Fatal("expected key registered before fetch to be invalidated")
+		}
+	})
+
+	t.Run("drops fill invalidated during fetch", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		value, err := service.GetOrFetchWithOptions(ctx, "user::GetByID:1", EntryOptions{Tags: []string{tag}}, func(ctx context.Context) (string, error) {
+			if err := service.InvalidateTags(ctx, []string{tag}); err != nil {
+				t.Fatalf("failed to invalidate tag: %v", err)
+			}
+			return "stale", nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value != "stale" {
+			t.Fatalf("expected fetched value to reach the caller, got %v", value)
+		}
+		if _, ok := service.client.Get("user::GetByID:1"); ok {
+			t.Fatal("expected raced fill to be dropped")
+		}
+
+		value, err = service.GetOrFetchWithOptions(ctx, "user::GetByID:1", EntryOptions{Tags: []string{tag}}, func(ctx context.Context) (string, error) {
+			return "fresh", nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value != "fresh" {
+			t.Fatalf("expected refetch after dropped fill, got %v", value)
+		}
+		if _, ok := service.client.Get("user::GetByID:1"); !ok {
+			t.Fatal("expected later fill to be stored")
+		}
+	})
+
+	t.Run("drops fills flushed during fetch", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		for _, prefix := range []string{"user::", ""} {
+			value, err := service.GetOrFetch(ctx, "user::GetByID:1", func(ctx context.Context) (string, error) {
+				if err := service.DeleteByPrefix(ctx, prefix); err != nil {
+					t.Fatalf("failed to delete prefix: %v", err)
+				}
+				return "stale", nil
+			})
+			if err != nil || value != "stale" {
+				t.Fatalf("expected fetched value to reach the caller, got %v (%v)", value, err)
+			}
+			if _, ok := service.client.Get("user::GetByID:1"); ok {
+				t.Fatalf("expected fill raced by a flush of %q to be dropped", prefix)
+			}
+		}
+
+		if _, err := service.GetOrFetch(ctx, "user::GetByID:1", func(ctx context.Context) (string, error) {
+			if err := service.DeleteByPrefix(ctx, "order::"); err != nil {
+				t.Fatalf("failed to delete prefix: %v", err)
+			}
+			return "fresh", nil
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := service.client.Get("user::GetByID:1"); !ok {
+			t.Fatal("expected a flush of another namespace to keep the fill")
+		}
+	})
+
+	t.Run("propagates fetch errors", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		_, err = service.GetOrFetchWithOptions(ctx, "user::GetByID:1", EntryOptions{Tags: []string{tag}}, func(ctx context.Context) (string, error) {
+			return "", concreteFetchErr{}
+		})
+		if !errors.As(err, &concreteFetchErr{}) {
+			t.Fatalf("expected concreteFetchErr, got %v", err)
+		}
+	})
+}
+
+func TestSturdycService_GetOrFetchBatch(t *testing.T) {
+	cfg := Config{
+		Capacity:           100,
+		NumShards:          2,
+		TTL:                1 * time.Minute,
+		EvictionPercentage: 10,
+	}
+	ctx := context.Background()
+	keyFn := func(id string) string { return "user::GetByID:" + id }
+	optsFn := func(id string) EntryOptions { return EntryOptions{Tags: []string{"user::id:" + id}} }
+
+	t.Run("fetches only missing ids", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		var fetched [][]string
+		fetchFn := func(ctx context.Context, ids []string) (map[string]any, error) {
+			fetched = append(fetched, append([]string(nil), ids...))
+			values := make(map[string]any, len(ids))
+			for _, id := range ids {
+				if id != "3" {
+					values[id] = "user-" + id
+				}
+			}
+			return values, nil
+		}
+
+		values, err := service.GetOrFetchBatch(ctx, []string{"1", "2"}, keyFn, optsFn, fetchFn)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(values) != 2 {
+			t.Fatalf("expected two values, got %v", values)
+		}
+
+		values, err = service.GetOrFetchBatch(ctx, []string{"1", "2", "3"}, keyFn, optsFn, fetchFn)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(values) != 2 || values["2"] != "user-2" {
+			t.Fatalf("expected cached values without the missing id, got %v", values)
+		}
+		if len(fetched) != 2 || len(fetched[1]) != 1 || fetched[1][0] != "3" {
+			t.Fatalf("expected second fetch to request only the uncached id, got %v", fetched)
+		}
+
+		if err := service.InvalidateTags(ctx, []string{"user::id:1"}); err != nil {
+			t.Fatalf("failed to invalidate tag: %v", err)
+		}
+		if _, ok := service.client.Get(keyFn("1")); ok {
+			t.Fatal("expected batch entry to be registered under its tags")
+		}
+	})
+
+	t.Run("drops fills invalidated during fetch", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		values, err := service.GetOrFetchBatch(ctx, []string{"1", "2"}, keyFn, optsFn, func(ctx context.Context, ids []string) (map[string]any, error) {
+			if err := service.InvalidateTags(ctx, []string{"user::id:2"}); err != nil {
+				t.Fatalf("failed to invalidate tag: %v", err)
+			}
+			return map[string]any{"1": "user-1", "2": "user-2"}, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(values) != 2 {
+			t.Fatalf("expected fetched values to reach the caller, got %v", values)
+		}
+		for _, id := range []string{"1", "2"} {
+			if _, ok := service.client.Get(keyFn(id)); ok {
+				t.Fatalf("expected raced fill of %s to be dropped", id)
+			}
+		}
+	})
+
+	t.Run("propagates fetch errors", func(t *testing.T) {
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		_, err = service.GetOrFetchBatch(ctx, []string{"1"}, keyFn, optsFn, func(ctx context.Context, ids []string) (map[string]any, error) {
+			return nil, concreteFetchErr{}
+		})
+		if !errors.As(err, &concreteFetchErr{}) {
+			t.Fatalf("expected concreteFetchErr, got %v", err)
+		}
+	})
+}
+
+func TestSturdycService_Set(t *testing.T) {
+	service, err := NewSturdycService(DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := service.Set(ctx, "user::GetByID:1", "written", EntryOptions{Tags: []string{"user::id:1"}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, err := service.GetOrFetch(ctx, "user::GetByID:1", func(ctx context.Context) (string, error) {
+		return "fetched", nil
+	})
+	if err != nil || value != "written" {
+		t.Fatalf("expected stored value, got %v (%v)", value, err)
+	}
+
+	if err := service.InvalidateTags(ctx, []string{"user::id:1"}); err != nil {
+		t.Fatalf("failed to invalidate tag: %v", err)
+	}
+	if _, ok := service.client.Get("user::GetByID:1"); ok {
+		t.Fatal("expected stored value to be registered under its tags")
+	}
+}
+
+func TestSturdycService_EntryTTL(t *testing.T) {
+	newService := func(t *testing.T) (*sturdycService, *time.Time) {
+		t.Helper()
+		cfg := DefaultConfig()
+		cfg.EarlyRefresh = nil
+		cfg.TTL = time.Minute
+		cfg.MaxTTL = time.Hour
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		now := time.Now()
+		service.now = func() time.Time { return now }
+		return service, &now
+	}
+	ctx := context.Background()
+
+	t.Run("GetOrFetchWithOptions", func(t *testing.T) {
+		service, now := newService(t)
+		calls := 0
+		fetch := func(ctx context.Context) (int, error) {
+			calls++
+			return calls, nil
+		}
+		get := func(key string, ttl time.Duration) any {
+			value, err := service.GetOrFetchWithOptions(ctx, key, EntryOptions{Tags: []string{"tag"}, TTL: ttl}, fetch)
+			if err != nil {
+				t.Fatalf("GetOrFetchWithOptions failed: %v", err)
+			}
+			return value
+		}
+
+		short, long, def := get("short", 10*time.Second), get("long", 2*time.Hour), get("default", 0)
+		*now = now.Add(30 * time.Second)
+		if get("short", 10*time.Second) == short {
+			t.Fatal("expected entry to expire after its own TTL")
+		}
+		if get("default", 0) != def {
+			t.Fatal("expected entry without TTL to live for the default TTL")
+		}
+
+		*now = now.Add(time.Minute)
+		if get("default", 0) == def {
+			t.Fatal("expected entry without TTL to expire after the default TTL")
+		}
+		if get("long", 2*time.Hour) != long {
+			t.Fatal("expected entry with a long TTL to outlive the default TTL")
+		}
+		if value, _ := service.client.Get("long"); value != long {
+			t.Fatalf("expected entry capped at MaxTTL to be left to the client TTL, got %v", value)
+		}
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		service, now := newService(t)
+		if err := service.Set(ctx, "key", "written", EntryOptions{TTL: 10 * time.Second}); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		*now = now.Add(30 * time.Second)
+		value, err := service.GetOrFetch(ctx, "key", func(ctx context.Context) (string, error) {
+			return "fetched", nil
+		})
+		if err != nil || value != "fetched" {
+			t.Fatalf("expected expired entry to be fetched again, got %v (%v)", value, err)
+		}
+	})
+
+	t.Run("GetOrFetchBatch", func(t *testing.T) {
+		service, now := newService(t)
+		var fetched [][]string
+		fetch := func(ctx context.Context, ids []string) (map[string]any, error) {
+			fetched = append(fetched, ids)
+			values := make(map[string]any, len(ids))
+			for _, id := range ids {
+				values[id] = fmt.Sprintf("%s@%d", id, len(fetched))
+			}
+			return values, nil
+		}
+		optsFn := func(id string) EntryOptions {
+			if id == "short" {
+				return EntryOptions{TTL: 10 * time.Second}
+			}
+			return EntryOptions{}
+		}
+		keyFn := func(id string) string { return "batch:" + id }
+
+		if _, err := service.GetOrFetchBatch(ctx, []string{"short", "default"}, keyFn, optsFn, fetch); err != nil {
+			t.Fatalf("GetOrFetchBatch failed: %v", err)
+		}
+		*now = now.Add(30 * time.Second)
+		values, err := service.GetOrFetchBatch(ctx, []string{"short", "default"}, keyFn, optsFn, fetch)
+		if err != nil {
+			t.Fatalf("GetOrFetchBatch failed: %v", err)
+		}
+		if values["short"] != "short@2" || values["default"] != "default@1" {
+			t.Fatalf("expected only the expired ID to be fetched again, got %v", values)
+		}
+		if len(fetched) != 2 || len(fetched[1]) != 1 || fetched[1][0] != "short" {
+			t.Fatalf("expected one refetch of the expired ID, got %v", fetched)
+		}
+	})
+}
+
+func TestSturdycService_NotFoundCaching(t *testing.T) {
+	errNotFound := errors.New("record not found")
+	newService := func(t *testing.T, storeMissing bool) (*sturdycService, *time.Time) {
+		t.Helper()
+		cfg := DefaultConfig()
+		cfg.EarlyRefresh = nil
+		cfg.MissingRecordStorage = storeMissing
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		now := time.Now()
+		service.now = func() time.Time { return now }
+		return service, &now
+	}
+	opts := EntryOptions{
+		Tags:        []string{"user::id:1"},
+		NotFound:    func(err error) bool { return errors.Is(err, errNotFound) },
+		NotFoundTTL: 10 * time.Second,
 	}
+	ctx := context.Background()
+
+	t.Run("CachesOriginalError", func(t *testing.T) {
+		service, now := newService(t, true)
+		calls := 0
+		fetch := func(ctx context.Context) (string, error) {
+			calls++
+			return "", fmt.Errorf("user 1: %w", errNotFound)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := service.GetOrFetchWithOptions(ctx, "user::GetByID:1", opts, fetch); !errors.Is(err, errNotFound) {
+				t.Fatalf("expected the original not found error, got %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected not found error to be cached, got %d fetches", calls)
+		}
+
+		*now = now.Add(30 * time.Second)
+		service.GetOrFetchWithOptions(ctx, "user::GetByID:1", opts, fetch)
+		if calls != 2 {
+			t.Fatalf("expected not found error to expire after its TTL, got %d fetches", calls)
+		}
+
+		if err := service.InvalidateTags(ctx, opts.Tags); err != nil {
+			t.Fatalf("failed to invalidate tags: %v", err)
+		}
+		value, err := service.GetOrFetchWithOptions(ctx, "user::GetByID:1", opts, func(ctx context.Context) (string, error) {
+			return "created", nil
+		})
+		if err != nil || value != "created" {
+			t.Fatalf("expected invalidation to evict the not found error, got %v (%v)", value, err)
+		}
+	})
+
+	t.Run("SkipsOtherErrorsAndDisabledStorage", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			storeMissing bool
+			err          error
+		}{
+			"other error":      {storeMissing: true, err: errors.New("connection refused")},
+			"storage disabled": {storeMissing: false, err: errNotFound},
+		} {
+			service, _ := newService(t, tc.storeMissing)
+			calls := 0
+			for i := 0; i < 2; i++ {
+				service.GetOrFetchWithOptions(ctx, "user::GetByID:1", opts, func(ctx context.Context) (string, error) {
+					calls++
+					return "", tc.err
+				})
+			}
+			if calls != 2 {
+				t.Errorf("%s: expected error not to be cached, got %d fetches", name, calls)
+			}
+		}
+	})
+
+	t.Run("BatchTreatsCachedErrorAsMissing", func(t *testing.T) {
+		service, _ := newService(t, true)
+		keyFn := func(id string) string { return "user::GetByID:" + id }
+		service.GetOrFetchWithOptions(ctx, keyFn("1"), opts, func(ctx context.Context) (string, error) {
+			return "", errNotFound
+		})
+		values, err := service.GetOrFetchBatch(ctx, []string{"1", "2"}, keyFn, func(string) EntryOptions { return EntryOptions{} }, func(ctx context.Context, ids []string) (map[string]any, error) {
+			values := make(map[string]any, len(ids))
+			for _, id := range ids {
+				values[id] = "user " + id
+			}
+			return values, nil
+		})
+		if err != nil {
+			t.Fatalf("GetOrFetchBatch failed: %v", err)
+		}
+		if _, ok := values["1"]; ok || values["2"] != "user 2" {
+			t.Fatalf("expected the cached not found ID to be absent, got %v", values)
+		}
+	})
+}
+
+func TestSturdycService_StaleIfError(t *testing.T) {
+	errUnavailable := errors.New("connection refused")
+	newService := func(t *testing.T) (*sturdycService, *time.Time) {
+		t.Helper()
+		cfg := DefaultConfig()
+		cfg.EarlyRefresh = nil
+		cfg.TTL = time.Minute
+		cfg.MaxStale = 5 * time.Minute
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		now := time.Now()
+		service.now = func() time.Time { return now }
+		return service, &now
+	}
+	opts := EntryOptions{
+		Tags:         []string{"user::id:1"},
+		StaleIfError: 2 * time.Minute,
+		Transient:    func(err error) bool { return errors.Is(err, errUnavailable) },
+	}
+	ctx := context.Background()
+
+	t.Run("ServesStaleWithinGrace", func(t *testing.T) {
+		service, now := newService(t)
+		service.GetOrFetchWithOptions(ctx, "key", opts, func(ctx context.Context) (string, error) {
+			return "cached", nil
+		})
+
+		*now = now.Add(90 * time.Second)
+		refreshes := 0
+		failing := func(ctx context.Context) (string, error) {
+			refreshes++
+			return "", errUnavailable
+		}
+		for i := 0; i < 2; i++ {
+			_, err := service.GetOrFetchWithOptions(ctx, "key", opts, failing)
+			var stale *StaleError
+			if !errors.As(err, &stale) || stale.Value != "cached" || !errors.Is(err, errUnavailable) {
+				t.Fatalf("expected the stale value with the refresh error, got %v", err)
+			}
+		}
+		if refreshes != 2 {
+			t.Fatalf("expected every stale read to retry the refresh, got %d refreshes", refreshes)
+		}
+
+		*now = now.Add(2 * time.Minute)
+		if _, err := service.GetOrFetchWithOptions(ctx, "key", opts, failing); !errors.Is(err, errUnavailable) || errors.As(err, new(*StaleError)) {
+			t.Fatalf("expected the refresh error after the grace window, got %v", err)
+		}
+	})
+
+	t.Run("RefreshesWhenPossible", func(t *testing.T) {
+		service, now := newService(t)
+		service.GetOrFetchWithOptions(ctx, "key", opts, func(ctx context.Context) (string, error) {
+			return "cached", nil
+		})
+		*now = now.Add(90 * time.Second)
+
+		if _, err := service.GetOrFetchWithOptions(ctx, "key", opts, func(ctx context.Context) (string, error) {
+			return "", errors.New("syntax error")
+		}); err == nil || errors.As(err, new(*StaleError)) {
+			t.Fatalf("expected non-transient errors to be returned, got %v", err)
+		}
+
+		value, err := service.GetOrFetchWithOptions(ctx, "key", opts, func(ctx context.Context) (string, error) {
+			return "refreshed", nil
+		})
+		if err != nil || value != "refreshed" {
+			t.Fatalf("expected the refreshed value, got %v (%v)", value, err)
+		}
+	})
+
+	t.Run("InvalidatedEntriesAreNotServed", func(t *testing.T) {
+		service, now := newService(t)
+		service.GetOrFetchWithOptions(ctx, "key", opts, func(ctx context.Context) (string, error) {
+			return "cached", nil
+		})
+		*now = now.Add(90 * time.Second)
+		if err := service.InvalidateTags(ctx, opts.Tags); err != nil {
+			t.Fatalf("failed to invalidate tags: %v", err)
+		}
+		if _, err := service.GetOrFetchWithOptions(ctx, "key", opts, func(ctx context.Context) (string, error) {
+			return "", errUnavailable
+		}); errors.As(err, new(*StaleError)) {
+			t.Fatalf("expected no stale value after invalidation, got %v", err)
+		}
+	})
+}
+
+func TestSturdycService_DetachedFetch(t *testing.T) {
+	type tenantKey struct{}
+	ctx := context.Background()
+
+	t.Run("CanceledCallerDoesNotFailWaiters", func(t *testing.T) {
+		service, err := NewSturdycService(DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var fetchErr error
+		var tenant any
+		fetch := func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			fetchErr = ctx.Err()
+			tenant = ctx.Value(tenantKey{})
+			return "value", nil
+		}
+
+		first, cancel := context.WithCancel(context.WithValue(ctx, tenantKey{}, "tenant-1"))
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := service.GetOrFetchWithOptions(first, "key", EntryOptions{Tags: []string{"tag"}}, fetch)
+			firstErr <- err
+		}()
+		<-started
+
+		second := make(chan any, 1)
+		go func() {
+			value, _ := service.GetOrFetchWithOptions(ctx, "key", EntryOptions{Tags: []string{"tag"}}, fetch)
+			second <- value
+		}()
+
+		cancel()
+		select {
+		case err := <-firstErr:
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected the canceled caller to get context.Canceled, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the canceled caller to stop waiting")
+		}
+
+		close(release)
+		if value := <-second; value != "value" {
+			t.Fatalf("expected the other waiter to get the fetched value, got %v", value)
+		}
+		if fetchErr != nil || tenant != "tenant-1" {
+			t.Fatalf("expected a detached fetch context with request values, got %v and %v", fetchErr, tenant)
+		}
+	})
+
+	t.Run("FetchTimeout", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.FetchTimeout = 20 * time.Millisecond
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		_, err = service.GetOrFetch(ctx, "key", func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the fetch to time out, got %v", err)
+		}
+
+		_, err = service.GetOrFetchBatch(ctx, []string{"1"}, func(id string) string { return "batch:" + id }, func(string) EntryOptions { return EntryOptions{} }, func(ctx context.Context, ids []string) (map[string]any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the batch fetch to time out, got %v", err)
+		}
+	})
+
+	t.Run("KeepsCallerDeadlineWithoutFetchTimeout", func(t *testing.T) {
+		service, err := NewSturdycService(DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		caller, cancel := context.WithTimeout(ctx, time.Hour)
+		defer cancel()
+		want, _ := caller.Deadline()
+		var got time.Time
+		if _, err := service.GetOrFetch(caller, "key", func(ctx context.Context) (string, error) {
+			got, _ = ctx.Deadline()
+			return "value", nil
+		}); err != nil {
+			t.Fatalf("GetOrFetch failed: %v", err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("expected the fetch to keep the caller's deadline %v, got %v", want, got)
+		}
+	})
+
+	t.Run("HitsAreNotDetached", func(t *testing.T) {
+		service, err := NewSturdycService(DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		if service.cached("key") {
+			t.Fatal("expected a missing key not to be cached")
+		}
+		if _, err := service.GetOrFetch(ctx, "key", func(context.Context) (string, error) { return "value", nil }); err != nil {
+			t.Fatalf("GetOrFetch failed: %v", err)
+		}
+		if !service.cached("key") || service.cached("key", "other") {
+			t.Fatal("expected only the fetched key to be cached")
+		}
+
+		caller, cancel := context.WithCancel(ctx)
+		defer cancel()
+		value, err := service.GetOrFetch(caller, "key", func(context.Context) (string, error) {
+			return "", errors.New("expected a hit")
+		})
+		if err != nil || value != "value" {
+			t.Fatalf("expected the cached value, got %v (%v)", value, err)
+		}
+	})
+}
+
+func TestSturdycService_Metrics(t *testing.T) {
+	ctx := context.Background()
+	fetch := func(value string) func(context.Context) (string, error) {
+		return func(context.Context) (string, error) { return value, nil }
+	}
+
+	t.Run("Invalidations", func(t *testing.T) {
+		metrics := NewMemoryMetrics()
+		cfg := DefaultConfig()
+		cfg.Metrics = metrics
+		service, err := NewSturdycService(cfg)
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		for _, key := range []string{"users::get_by_id::1", "users::get_by_id::2", "orders::list"} {
+			if _, err := service.GetOrFetchWithOptions(ctx, key, EntryOptions{Tags: []string{"tag"}}, fetch(key)); err != nil {
+				t.Fatalf("GetOrFetchWithOptions failed: %v", err)
+			}
+		}
+		if err := service.InvalidateTags(ctx, []string{"tag"}); err != nil {
+			t.Fatalf("InvalidateTags failed: %v", err)
+		}
+		if err := service.Delete(ctx, "users::get_by_id::1"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+
+		snapshot := metrics.Snapshot()
+		if got := snapshot.Counter("users", "", CounterInvalidatedKey); got != 3 {
+			t.Fatalf("expected 3 users invalidated keys, got %d", got)
+		}
+		if got := snapshot.Counter("orders", "", CounterInvalidatedKey); got != 1 {
+			t.Fatalf("expected 1 orders invalidated key, got %d", got)
+		}
+		if got := snapshot.Counter("users", "", CounterEviction); got != 0 {
+			t.Fatalf("expected invalidations not to count as evictions, got %d", got)
+		}
+	})
+
+	t.Run("ForcedEviction", func(t *testing.T) {
+		metrics := NewMemoryMetrics()
+		service, err := NewSturdycService(Config{
+			Capacity:           2,
+			NumShards:          1,
+			TTL:                time.Minute,
+			EvictionPercentage: 50,
+			Metrics:            metrics,
+		})
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		for _, key := range []string{"users::1", "users::2", "users::3"} {
+			if _, err := service.GetOrFetch(ctx, key, fetch(key)); err != nil {
+				t.Fatalf("GetOrFetch failed: %v", err)
+			}
+		}
+
+		snapshot := metrics.Snapshot()
+		if got := snapshot.Counter("", "", CounterForcedEviction); got != 1 {
+			t.Fatalf("expected 1 forced eviction, got %d", got)
+		}
+		if got := snapshot.Counter("", "", CounterEviction); got != 1 {
+			t.Fatalf("expected 1 evicted entry, got %d", got)
+		}
+	})
+
+	t.Run("ExpiredShortTTLEntriesAreSwept", func(t *testing.T) {
+		metrics := NewMemoryMetrics()
+		service, err := NewSturdycService(Config{
+			Capacity:           4,
+			NumShards:          1,
+			TTL:                time.Hour,
+			EvictionPercentage: 50,
+			Metrics:            metrics,
+		})
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		now := time.Now()
+		service.now = func() time.Time { return now }
+
+		short := EntryOptions{TTL: time.Minute}
+		for _, key := range []string{"users::1", "users::2", "users::3", "users::4"} {
+			if _, err := service.GetOrFetchWithOptions(ctx, key, short, fetch(key)); err != nil {
+				t.Fatalf("GetOrFetchWithOptions failed: %v", err)
+			}
+		}
+		now = now.Add(2 * time.Minute)
+		live := []string{"users::5", "users::6", "users::7", "users::8"}
+		for _, key := range live {
+			if _, err := service.GetOrFetch(ctx, key, fetch(key)); err != nil {
+				t.Fatalf("GetOrFetch failed: %v", err)
+			}
+		}
+
+		snapshot := metrics.Snapshot()
+		if got := snapshot.Counter("", "", CounterForcedEviction); got != 0 {
+			t.Fatalf("expected expired entries not to force evictions, got %d", got)
+		}
+		if got := snapshot.Counter("", "", CounterEviction); got != 4 {
+			t.Fatalf("expected the 4 expired entries to be swept, got %d", got)
+		}
+		for _, key := range live {
+			if _, ok := service.client.Get(key); !ok {
+				t.Fatalf("expected %q to stay cached", key)
+			}
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		metrics := NewMemoryMetrics()
+		service, err := NewSturdycService(Config{
+			Capacity:           100,
+			NumShards:          1,
+			TTL:                10 * time.Millisecond,
+			EvictionPercentage: 10,
+			EvictionInterval:   5 * time.Millisecond,
+			Metrics:            metrics,
+		})
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+		if _, err := service.GetOrFetch(ctx, "users::get_by_id::1", fetch("value")); err != nil {
+			t.Fatalf("GetOrFetch failed: %v", err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for metrics.Snapshot().Counter("", "", CounterEviction) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the expired entry to be reported as evicted")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
 }
 
 func TestSturdycService_InterfaceCompliance(t *testing.T) {
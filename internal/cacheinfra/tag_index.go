@@ -0,0 +1,213 @@
+package cacheinfra
+
+import (
+	"sync"
+	"time"
+)
+
+// tagRetentionFactor scales the cache TTL into the lifetime of a tag
+// registration. Registrations must outlive the entries they point at: a
+// background refresh stores a new entry some time after the read that
+// re-registered its key.
+const tagRetentionFactor = 2
+
+// tagIndex maps tags to the cache keys registered under them. It lives outside
+// the value cache, so registrations never compete with cached values for
+// capacity and are not evicted while the keys they track are still cached.
+//
+// Each registration carries a deadline that is pushed forward whenever the key
+// is registered again. Shards drop registrations past their deadline lazily,
+// which keeps the index bounded by the keys that can still be cached.
+//
+// Reads register their tags on every lookup, so registration is on the hot path.
+// Tags are grouped by shard so each shard is locked once per call, and
+// registrations that are still fresh are confirmed under a read lock without
+// taking the shard's write lock.
+type tagIndex struct {
+	shards    []*tagIndexShard
+	retention time.Duration
+	now       func() time.Time
+}
+
+type tagIndexShard struct {
+	mu        sync.RWMutex
+	tags      map[string]map[string]time.Time
+	lastSweep time.Time
+}
+
+func newTagIndex(numShards int, retention time.Duration) *tagIndex {
+	if numShards <= 0 {
+		numShards = 1
+	}
+	index := &tagIndex{
+		shards:    make([]*tagIndexShard, numShards),
+		retention: retention,
+		now:       time.Now,
+	}
+	for i := range index.shards {
+		index.shards[i] = &tagIndexShard{tags: make(map[string]map[string]time.Time)}
+	}
+	return index
+}
+
+func (i *tagIndex) shard(tag string) *tagIndexShard {
+	return i.shards[tagHash(tag)%uint64(len(i.shards))]
+}
+
+// maxInlineTags bounds the per-call shard lookup kept on the stack.
+const maxInlineTags = 8
+
+// add registers key under every non-empty tag.
+func (i *tagIndex) add(key string, tags []string) {
+	now := i.now()
+	deadline := now.Add(i.retention)
+	// A registration that outlives refreshAfter still covers an entry stored
+	// now for at least half the retention, so it is not rewritten.
+	refreshAfter := now.Add(i.retention / 2)
+
+	var inline [maxInlineTags]*tagIndexShard
+	owners := inline[:0]
+	if len(tags) > maxInlineTags {
+		owners = make([]*tagIndexShard, 0, len(tags))
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			owners = append(owners, nil)
+			continue
+		}
+		owners = append(owners, i.shard(tag))
+	}
+
+	for idx, shard := range owners {
+		if shard == nil || firstShardIndex(owners, shard) != idx {
+			continue
+		}
+		if shard.fresh(key, tags, owners, refreshAfter, now, i.retention) {
+			continue
+		}
+		shard.mu.Lock()
+		if now.Sub(shard.lastSweep) >= i.retention {
+			shard.sweep(now)
+		}
+		for j, tag := range tags {
+			if owners[j] != shard {
+				continue
+			}
+			keys := shard.tags[tag]
+			if keys == nil {
+				keys = make(map[string]time.Time)
+				shard.tags[tag] = keys
+			}
+			keys[key] = deadline
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// fresh reports, under the read lock, whether key is registered under every tag
+// owned by s with a deadline past refreshAfter and no sweep is due.
+func (s *tagIndexShard) fresh(key string, tags []string, owners []*tagIndexShard, refreshAfter, now time.Time, retention time.Duration) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if now.Sub(s.lastSweep) >= retention {
+		return false
+	}
+	for j, tag := range tags {
+		if owners[j] != s {
+			continue
+		}
+		deadline, ok := s.tags[tag][key]
+		if !ok || deadline.Before(refreshAfter) {
+			return false
+		}
+	}
+	return true
+}
+
+func firstShardIndex(owners []*tagIndexShard, shard *tagIndexShard) int {
+	for idx, existing := range owners {
+		if existing == shard {
+			return idx
+		}
+	}
+	return -1
+}
+
+// tagHash is an allocation free FNV-1a hash of tag.
+func tagHash(tag string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hash := uint64(offset64)
+	for i := 0; i < len(tag); i++ {
+		hash ^= uint64(tag[i])
+		hash *= prime64
+	}
+	return hash
+}
+
+// take removes tag from the index and returns the keys that were still live.
+func (i *tagIndex) take(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	now := i.now()
+	shard := i.shard(tag)
+	shard.mu.Lock()
+	registered := shard.tags[tag]
+	delete(shard.tags, tag)
+	shard.mu.Unlock()
+
+	return liveKeys(registered, now)
+}
+
+// keys returns the live keys registered under tag without removing them.
+func (i *tagIndex) keys(tag string) []string {
+	now := i.now()
+	shard := i.shard(tag)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return liveKeys(shard.tags[tag], now)
+}
+
+// size returns the number of registrations held, expired or not.
+func (i *tagIndex) size() int {
+	total := 0
+	for _, shard := range i.shards {
+		shard.mu.RLock()
+		for _, keys := range shard.tags {
+			total += len(keys)
+		}
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+// sweep drops expired registrations. Callers must hold s.mu.
+func (s *tagIndexShard) sweep(now time.Time) {
+	for tag, keys := range s.tags {
+		for key, deadline := range keys {
+			if !now.Before(deadline) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+	s.lastSweep = now
+}
+
+func liveKeys(registered map[string]time.Time, now time.Time) []string {
+	if len(registered) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(registered))
+	for key, deadline := range registered {
+		if now.Before(deadline) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,90 @@
+package cacheinfra
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/viccon/sturdyc"
+)
+
+// legacyTagRegistry reproduces the tag registry used before the dedicated
+// index: one mutex guarding tag sets stored as entries of the value cache.
+type legacyTagRegistry struct {
+	client *sturdyc.Client[any]
+	mu     sync.Mutex
+}
+
+func newLegacyTagRegistry() *legacyTagRegistry {
+	return &legacyTagRegistry{client: sturdyc.New[any](1_000_000, 256, time.Hour, 10)}
+}
+
+func (r *legacyTagRegistry) add(key string, tags []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tag := range tags {
+		registryKey := "tag::" + tag
+		registry, _ := r.client.Get(registryKey)
+		entries, _ := registry.(map[string]struct{})
+		if entries == nil {
+			entries = make(map[string]struct{})
+		}
+		entries[key] = struct{}{}
+		r.client.Set(registryKey, entries)
+	}
+}
+
+type tagRegistrar interface {
+	add(key string, tags []string)
+}
+
+const benchKeys = 4096
+
+// benchWorkload mirrors decorator reads: every key carries the namespace list
+// tag, one of a few scope tags and its own ID tag.
+func benchWorkload() ([]string, [][]string) {
+	keys := make([]string, benchKeys)
+	tags := make([][]string, benchKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user::get_by_id::%d", i)
+		tags[i] = []string{
+			"user::list",
+			fmt.Sprintf("user::scope::tenant-%d", i%8),
+			fmt.Sprintf("user::id::%d", i),
+		}
+	}
+	return keys, tags
+}
+
+func runRegistrationBenchmark(b *testing.B, registrar tagRegistrar, goroutines int) {
+	keys, tags := benchWorkload()
+	perWorker := b.N/goroutines + 1
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for n := 0; n < perWorker; n++ {
+				idx := (offset*7919 + n) % benchKeys
+				registrar.add(keys[idx], tags[idx])
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkTagRegistration(b *testing.B) {
+	for _, goroutines := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("legacy/goroutines=%d", goroutines), func(b *testing.B) {
+			runRegistrationBenchmark(b, newLegacyTagRegistry(), goroutines)
+		})
+		b.Run(fmt.Sprintf("index/goroutines=%d", goroutines), func(b *testing.B) {
+			runRegistrationBenchmark(b, newTagIndex(256, time.Hour), goroutines)
+		})
+	}
+}
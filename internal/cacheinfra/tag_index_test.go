@@ -0,0 +1,161 @@
+package cacheinfra
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestTagIndex(retention time.Duration) (*tagIndex, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	index := newTagIndex(4, retention)
+	index.now = clock.Now
+	return index, clock
+}
+
+func TestTagIndex_AddAndTake(t *testing.T) {
+	index, _ := newTestTagIndex(time.Minute)
+
+	index.add("key-1", []string{"tag-a", "", "tag-b"})
+	index.add("key-2", []string{"tag-a"})
+
+	keys := index.keys("tag-a")
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "key-1" || keys[1] != "key-2" {
+		t.Fatalf("expected both keys under tag-a, got %v", keys)
+	}
+
+	taken := index.take("tag-a")
+	if len(taken) != 2 {
+		t.Fatalf("expected take to return both keys, got %v", taken)
+	}
+	if keys := index.keys("tag-a"); len(keys) != 0 {
+		t.Fatalf("expected tag-a to be removed, got %v", keys)
+	}
+	if keys := index.keys("tag-b"); len(keys) != 1 || keys[0] != "key-1" {
+		t.Fatalf("expected tag-b to keep key-1, got %v", keys)
+	}
+	if taken := index.take(""); taken != nil {
+		t.Fatalf("expected empty tag to return nil, got %v", taken)
+	}
+}
+
+func TestTagIndex_ExpiresRegistrations(t *testing.T) {
+	index, clock := newTestTagIndex(time.Minute)
+
+	index.add("key-1", []string{"tag-a"})
+	index.add("key-2", []string{"tag-a"})
+
+	clock.Advance(40 * time.Second)
+	index.add("key-2", []string{"tag-a"}) // re-registration extends the deadline
+
+	clock.Advance(30 * time.Second)
+	keys := index.keys("tag-a")
+	if len(keys) != 1 || keys[0] != "key-2" {
+		t.Fatalf("expected only the re-registered key to be live, got %v", keys)
+	}
+	if taken := index.take("tag-a"); len(taken) != 1 || taken[0] != "key-2" {
+		t.Fatalf("expected take to skip expired keys, got %v", taken)
+	}
+}
+
+func TestTagIndex_SweepsExpiredRegistrations(t *testing.T) {
+	index, clock := newTestTagIndex(time.Minute)
+
+	for i := 0; i < 100; i++ {
+		index.add(fmt.Sprintf("key-%d", i), []string{fmt.Sprintf("tag-%d", i)})
+	}
+	if size := index.size(); size != 100 {
+		t.Fatalf("expected 100 registrations, got %d", size)
+	}
+
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 16; i++ {
+		index.add("fresh", []string{fmt.Sprintf("fresh-tag-%d", i)})
+	}
+
+	if size := index.size(); size != 16 {
+		t.Fatalf("expected expired registrations to be swept, got %d remaining", size)
+	}
+}
+
+func TestSturdycService_TagIndexSurvivesEviction(t *testing.T) {
+	service, err := NewSturdycService(Config{
+		Capacity:           10,
+		NumShards:          1,
+		TTL:                time.Minute,
+		EvictionPercentage: 50,
+	})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := service.AddTags(ctx, "tagged", []string{"tag-a"}); err != nil {
+		t.Fatalf("failed to add tags: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("filler-%d", i)
+		if _, err := service.GetOrFetch(ctx, key, func(ctx context.Context) (int, error) {
+			return i, nil
+		}); err != nil {
+			t.Fatalf("failed to fill %s: %v", key, err)
+		}
+	}
+
+	if keys := service.tags.keys("tag-a"); len(keys) != 1 || keys[0] != "tagged" {
+		t.Fatalf("expected registration to survive capacity pressure, got %v", keys)
+	}
+
+	if _, err := service.GetOrFetch(ctx, "tagged", func(ctx context.Context) (string, error) {
+		return "value", nil
+	}); err != nil {
+		t.Fatalf("failed to cache tagged key: %v", err)
+	}
+	if err := service.InvalidateTags(ctx, []string{"tag-a"}); err != nil {
+		t.Fatalf("failed to invalidate: %v", err)
+	}
+	if _, ok := service.client.Get("tagged"); ok {
+		t.Fatal("expected tagged key to be invalidated")
+	}
+}
+
+func TestTagIndex_SkipsFreshRegistrations(t *testing.T) {
+	index, clock := newTestTagIndex(time.Minute)
+	start := clock.Now()
+
+	deadlineOf := func(tag, key string) time.Time {
+		t.Helper()
+		shard := index.shard(tag)
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+		return shard.tags[tag][key]
+	}
+
+	index.add("key-1", []string{"tag-a", "tag-b"})
+
+	clock.Advance(10 * time.Second)
+	index.add("key-1", []string{"tag-a", "tag-b"})
+	if got := deadlineOf("tag-a", "key-1"); !got.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected fresh registration to be kept, got deadline %v", got.Sub(start))
+	}
+
+	clock.Advance(25 * time.Second)
+	index.add("key-1", []string{"tag-a", "tag-b"})
+	for _, tag := range []string{"tag-a", "tag-b"} {
+		if got := deadlineOf(tag, "key-1"); !got.Equal(clock.Now().Add(time.Minute)) {
+			t.Fatalf("expected %s registration to be extended, got deadline %v", tag, got.Sub(start))
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package di
+
+import (
+	"context"
+
+	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/goliatone/go-repository-cache/repositorycache"
+)
+
+// UseInvalidationBus connects the container to the other nodes caching the same
+// data. Repositories built by the container afterwards publish their
+// invalidations on bus with cfg.Node as origin, and the invalidations other nodes
+// publish are applied to the container's cache service until ctx is done. Call
+// it while wiring the application, before building repositories.
+func (c *Container) UseInvalidationBus(ctx context.Context, bus cache.InvalidationBus, cfg cache.SubscriberConfig) error {
+	if err := cache.SubscribeInvalidations(ctx, bus, c.cacheService, cfg); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaultOptions = append(c.defaultOptions, repositorycache.WithInvalidationBus(bus, cfg.Node))
+	return nil
+}
@@ -1,6 +1,11 @@
 package di
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
 	repository "github.com/goliatone/go-repository-bun"
 	"github.com/goliatone/go-repository-cache/cache"
 	"github.com/goliatone/go-repository-cache/repositorycache"
@@ -10,15 +15,28 @@ import (
 // It manages singleton instances of cache services and key serializers,
 // and provides factory methods for creating cached repositories.
 type Container struct {
-	cacheService  cache.CacheService
-	keySerializer cache.KeySerializer
-	config        cache.Config
+	cacheService   cache.CacheService
+	keySerializer  cache.KeySerializer
+	config         cache.Config
+	defaultOptions []repositorycache.Option
+	observers      *observerSet
+
+	mu         sync.Mutex
+	namespaces map[string]struct{}
 }
 
+// ErrNamespaceConflict is returned by RegisterCachedRepository when a repository
+// with the same namespace was already built by the container.
+var ErrNamespaceConflict = errors.New("di: cache namespace already registered")
+
 // NewContainer creates a new DI container with the provided cache configuration.
 // It initializes the cache service using the sturdyc adapter and sets up
 // the default key serializer for consistent key generation.
-func NewContainer(config cache.Config) (*Container, error) {
+//
+// defaults are applied to every repository built by NewCachedRepository, before
+// the options passed to it. When config.Metrics is set, the repositories report
+// to it as well, unless an option says otherwise.
+func NewContainer(config cache.Config, defaults ...repositorycache.Option) (*Container, error) {
 	// Initialize the cache service using the sturdyc adapter
 	cacheService, err := cache.NewCacheService(config)
 	if err != nil {
@@ -28,10 +46,18 @@ func NewContainer(config cache.Config) (*Container, error) {
 	// Initialize the default key serializer
 	keySerializer := cache.NewDefaultKeySerializer()
 
+	var defaultOptions []repositorycache.Option
+	if config.Metrics != nil {
+		defaultOptions = append(defaultOptions, repositorycache.WithMetrics(config.Metrics))
+	}
+	defaultOptions = append(defaultOptions, defaults...)
+
 	return &Container{
-		cacheService:  cacheService,
-		keySerializer: keySerializer,
-		config:        config,
+		cacheService:   cacheService,
+		keySerializer:  keySerializer,
+		config:         config,
+		defaultOptions: defaultOptions,
+		observers:      &observerSet{},
 	}, nil
 }
 
@@ -60,12 +86,77 @@ func (c *Container) Config() cache.Config {
 	return c.config
 }
 
+// DefaultOptions returns the options applied to every repository the container builds.
+func (c *Container) DefaultOptions() []repositorycache.Option {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]repositorycache.Option(nil), c.defaultOptions...)
+}
+
 // NewCachedRepository creates a new cached repository that wraps the provided base repository.
 // It wires together the cache service, key serializer, and base repository to provide
-// a drop-in replacement with caching capabilities.
+// a drop-in replacement with caching capabilities. The container's default options
+// are applied first, then opts. The repository reports its events to the
+// observers added with AddObserver, after its own observers.
+//
+// The repository's namespace is recorded on the container. NewCachedRepository
+// panics with ErrNamespaceConflict when a repository with the same namespace was
+// built by the container before, since the two would read and invalidate each
+// other's entries; use RegisterCachedRepository to get the error instead.
 //
 // Since Go methods cannot have type parameters, this is provided as a package-level function.
 // Example: NewCachedRepository[User](container, baseUserRepository)
-func NewCachedRepository[T any](container *Container, base repository.Repository[T]) *repositorycache.CachedRepository[T] {
-	return repositorycache.New(base, container.cacheService, container.keySerializer)
+func NewCachedRepository[T any](container *Container, base repository.Repository[T], opts ...repositorycache.Option) *repositorycache.CachedRepository[T] {
+	repo, err := RegisterCachedRepository(container, base, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return repo
+}
+
+// RegisterCachedRepository creates a cached repository like NewCachedRepository and
+// registers its namespace on the container. It returns ErrNamespaceConflict when a
+// repository with the same namespace was built by the container before, where
+// NewCachedRepository panics. Call it while wiring the application so conflicts
+// surface at startup.
+func RegisterCachedRepository[T any](container *Container, base repository.Repository[T], opts ...repositorycache.Option) (*repositorycache.CachedRepository[T], error) {
+	repo := newCachedRepository(container, base, opts...)
+	namespace := repo.Namespace()
+
+	container.mu.Lock()
+	defer container.mu.Unlock()
+	if _, exists := container.namespaces[namespace]; exists {
+		return nil, fmt.Errorf("%w: %q", ErrNamespaceConflict, namespace)
+	}
+	container.recordNamespace(namespace)
+	return repo, nil
+}
+
+// Namespaces returns the sorted namespaces of the repositories built by the
+// container, such as the namespaces an invalidation bus flushes after a reconnect.
+func (c *Container) Namespaces() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	namespaces := make([]string, 0, len(c.namespaces))
+	for namespace := range c.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+func newCachedRepository[T any](container *Container, base repository.Repository[T], opts ...repositorycache.Option) *repositorycache.CachedRepository[T] {
+	options := append(container.DefaultOptions(), opts...)
+	if container.observers != nil {
+		options = append(options, repositorycache.WithObserver(container.observers))
+	}
+	return repositorycache.NewWithOptions(base, container.cacheService, container.keySerializer, options...)
+}
+
+// recordNamespace marks namespace as used. Callers must hold c.mu.
+func (c *Container) recordNamespace(namespace string) {
+	if c.namespaces == nil {
+		c.namespaces = make(map[string]struct{})
+	}
+	c.namespaces[namespace] = struct{}{}
 }
@@ -2,11 +2,14 @@ package di
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/goliatone/go-repository-cache/repositorycache"
 )
 
 func TestNewContainer(t *testing.T) {
@@ -198,3 +201,83 @@ func TestCacheServiceIntegration(t *testing.T) {
 		t.Errorf("Delete() failed: %v", err)
 	}
 }
+
+func TestRegisterCachedRepository_NamespaceConflict(t *testing.T) {
+	container, err := NewContainerWithDefaults()
+	if err != nil {
+		t.Fatalf("NewContainerWithDefaults() failed: %v", err)
+	}
+
+	first, err := RegisterCachedRepository(container, newMockUserRepository())
+	if err != nil {
+		t.Fatalf("RegisterCachedRepository() failed: %v", err)
+	}
+	if !strings.HasPrefix(first.Namespace(), "user_") {
+		t.Errorf("Expected namespace derived from the type name, got %q", first.Namespace())
+	}
+
+	if _, err := RegisterCachedRepository(container, newMockUserRepository()); !errors.Is(err, ErrNamespaceConflict) {
+		t.Fatalf("Expected ErrNamespaceConflict, got %v", err)
+	}
+
+	second, err := RegisterCachedRepository(container, newMockUserRepository(), repositorycache.WithNamespace("admin_user"))
+	if err != nil {
+		t.Fatalf("Expected explicit namespace to avoid the conflict, got %v", err)
+	}
+	if second.Namespace() != "admin_user" {
+		t.Errorf("Expected explicit namespace, got %q", second.Namespace())
+	}
+	if got, want := container.Namespaces(), []string{"admin_user", first.Namespace()}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected namespaces %v, got %v", want, got)
+	}
+
+	other, err := NewContainerWithDefaults()
+	if err != nil {
+		t.Fatalf("NewContainerWithDefaults() failed: %v", err)
+	}
+	if _, err := RegisterCachedRepository(other, newMockUserRepository()); err != nil {
+		t.Errorf("Expected namespaces to be tracked per container, got %v", err)
+	}
+
+	unregistered, err := NewContainerWithDefaults()
+	if err != nil {
+		t.Fatalf("NewContainerWithDefaults() failed: %v", err)
+	}
+	NewCachedRepository(unregistered, newMockUserRepository())
+	if _, err := RegisterCachedRepository(unregistered, newMockUserRepository()); !errors.Is(err, ErrNamespaceConflict) {
+		t.Errorf("Expected namespaces built by NewCachedRepository to conflict, got %v", err)
+	}
+}
+
+func TestNewCachedRepository_NamespaceConflictPanics(t *testing.T) {
+	expectConflict := func(t *testing.T, build func()) {
+		t.Helper()
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrNamespaceConflict) {
+				t.Errorf("Expected a panic with ErrNamespaceConflict, got %v", err)
+			}
+		}()
+		build()
+	}
+
+	t.Run("AfterNewCachedRepository", func(t *testing.T) {
+		container, err := NewContainerWithDefaults()
+		if err != nil {
+			t.Fatalf("NewContainerWithDefaults() failed: %v", err)
+		}
+		NewCachedRepository(container, newMockUserRepository())
+		expectConflict(t, func() { NewCachedRepository(container, newMockUserRepository()) })
+	})
+
+	t.Run("AfterRegisterCachedRepository", func(t *testing.T) {
+		container, err := NewContainerWithDefaults()
+		if err != nil {
+			t.Fatalf("NewContainerWithDefaults() failed: %v", err)
+		}
+		if _, err := RegisterCachedRepository(container, newMockUserRepository()); err != nil {
+			t.Fatalf("RegisterCachedRepository() failed: %v", err)
+		}
+		expectConflict(t, func() { NewCachedRepository(container, newMockUserRepository()) })
+	})
+}
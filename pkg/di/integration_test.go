@@ -2,6 +2,7 @@ package di
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"sync"
 	"testing"
@@ -9,6 +10,8 @@ import (
 
 	repository "github.com/goliatone/go-repository-bun"
 	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/goliatone/go-repository-cache/pkg/pgbus"
+	"github.com/goliatone/go-repository-cache/repositorycache"
 	"github.com/uptrace/bun"
 )
 
@@ -549,3 +552,282 @@ func TestDifferentRepositoryTypes(t *testing.T) {
 		t.Errorf("Retrieved user ID mismatch: got %s, expected %s", retrievedUser.ID, testUser.ID)
 	}
 }
+
+// TestContainerDefaultOptions verifies container defaults apply to every repository
+// and per-repository options override them
+func TestContainerDefaultOptions(t *testing.T) {
+	container, err := NewContainer(cache.DefaultConfig(), repositorycache.WithoutCachedMethods(repositorycache.MethodGetByID))
+	if err != nil {
+		t.Fatalf("Failed to create DI container: %v", err)
+	}
+	if len(container.DefaultOptions()) != 1 {
+		t.Fatalf("Expected 1 default option, got %d", len(container.DefaultOptions()))
+	}
+
+	ctx := context.Background()
+	mockRepo := newMockUserRepository()
+	mockRepo.Create(ctx, User{ID: "user-1", Name: "User"})
+	cachedRepo := NewCachedRepository(container, mockRepo)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cachedRepo.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+	}
+	if callCount := mockRepo.getCallCount("GetByID"); callCount != 2 {
+		t.Errorf("Expected default option to disable GetByID caching, got %d calls", callCount)
+	}
+
+	overrideRepo := newMockUserRepository()
+	overrideRepo.Create(ctx, User{ID: "user-1", Name: "User"})
+	cachedOverride := NewCachedRepository(container, overrideRepo,
+		repositorycache.WithNamespace("override_users"),
+		repositorycache.WithCachedMethods(repositorycache.MethodGetByID, repositorycache.MethodList),
+	)
+	for i := 0; i < 2; i++ {
+		cachedOverride.GetByID(ctx, "user-1")
+		cachedOverride.List(ctx)
+	}
+	if callCount := overrideRepo.getCallCount("GetByID"); callCount != 2 {
+		t.Errorf("Expected disabled methods to win over enabled ones, got %d GetByID calls", callCount)
+	}
+	if callCount := overrideRepo.getCallCount("List"); callCount != 1 {
+		t.Errorf("Expected List to be cached, got %d calls", callCount)
+	}
+}
+
+func TestContainerMetrics(t *testing.T) {
+	metrics := cache.NewMemoryMetrics()
+	config := cache.DefaultConfig()
+	config.Metrics = metrics
+	container, err := NewContainer(config)
+	if err != nil {
+		t.Fatalf("Failed to create DI container: %v", err)
+	}
+
+	ctx := context.Background()
+	mockRepo := newMockUserRepository()
+	mockRepo.Create(ctx, User{ID: "user-1", Name: "User"})
+	cachedRepo := NewCachedRepository(container, mockRepo)
+	if cachedRepo.Metrics() != metrics {
+		t.Fatal("Expected repositories to report to the configured metrics")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cachedRepo.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+	}
+	snapshot := metrics.Snapshot()
+	method := string(repositorycache.MethodGetByID)
+	if hits := snapshot.Counter(cachedRepo.Namespace(), method, cache.CounterHit); hits != 1 {
+		t.Errorf("Expected 1 hit, got %d", hits)
+	}
+	if misses := snapshot.Counter(cachedRepo.Namespace(), method, cache.CounterMiss); misses != 1 {
+		t.Errorf("Expected 1 miss, got %d", misses)
+	}
+
+	quiet := NewCachedRepository(container, newMockUserRepository(),
+		repositorycache.WithNamespace("quiet_users"),
+		repositorycache.WithMetrics(nil),
+	)
+	if quiet.Metrics() != nil {
+		t.Error("Expected an option to override the container metrics")
+	}
+}
+
+type countingObserver struct {
+	repositorycache.NopObserver
+	mu     sync.Mutex
+	hits   int
+	misses int
+}
+
+func (o *countingObserver) OnHit(ctx context.Context, event repositorycache.ReadEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hits++
+}
+
+func (o *countingObserver) OnMiss(ctx context.Context, event repositorycache.ReadEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.misses++
+}
+
+func TestContainerObserver(t *testing.T) {
+	container, err := NewContainer(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create DI container: %v", err)
+	}
+
+	ctx := context.Background()
+	mockRepo := newMockUserRepository()
+	mockRepo.Create(ctx, User{ID: "user-1", Name: "User"})
+	cachedRepo := NewCachedRepository(container, mockRepo)
+
+	// Observers added later still see repositories built earlier.
+	observer := &countingObserver{}
+	container.AddObserver(observer)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cachedRepo.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+	}
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if observer.misses != 1 || observer.hits != 2 {
+		t.Errorf("Expected 1 miss and 2 hits, got %d misses and %d hits", observer.misses, observer.hits)
+	}
+}
+
+func TestContainerInvalidationBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := cache.NewMemoryBus()
+
+	newNode := func(node string) (*mockUserRepository, *repositorycache.CachedRepository[User]) {
+		container, err := NewContainer(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("Failed to create DI container: %v", err)
+		}
+		if err := container.UseInvalidationBus(ctx, bus, cache.SubscriberConfig{Node: node}); err != nil {
+			t.Fatalf("UseInvalidationBus failed: %v", err)
+		}
+		mockRepo := newMockUserRepository()
+		mockRepo.Create(ctx, User{ID: "user-1", Name: "User"})
+		return mockRepo, NewCachedRepository(container, mockRepo)
+	}
+	_, writer := newNode("node-a")
+	readerRepo, reader := newNode("node-b")
+
+	reader.GetByID(ctx, "user-1")
+	if _, err := writer.Update(ctx, User{ID: "user-1", Name: "Renamed"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	readerRepo.Update(ctx, User{ID: "user-1", Name: "Renamed"})
+
+	user, err := reader.GetByID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if user.Name != "Renamed" {
+		t.Errorf("Expected the remote update to invalidate the reader's cache, got %q", user.Name)
+	}
+}
+
+// fakePostgres runs pg_notify for pgbus, delivering every payload to each open
+// listener.
+type fakePostgres struct {
+	mu        sync.Mutex
+	payloads  []string
+	listeners []chan string
+}
+
+func (p *fakePostgres) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payload := args[1].(string)
+	p.payloads = append(p.payloads, payload)
+	for _, ch := range p.listeners {
+		ch <- payload
+	}
+	return nil, nil
+}
+
+func (p *fakePostgres) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *fakePostgres) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return nil
+}
+
+func (p *fakePostgres) newListener(ctx context.Context) (pgbus.Listener, error) {
+	ch := make(chan string, 16)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, ch)
+	return fakePgListener(ch), nil
+}
+
+func (p *fakePostgres) published() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.payloads)
+}
+
+type fakePgListener chan string
+
+func (l fakePgListener) Listen(ctx context.Context, channels ...string) error {
+	return nil
+}
+
+func (l fakePgListener) Receive(ctx context.Context) (string, string, error) {
+	select {
+	case payload := <-l:
+		return pgbus.DefaultChannel, payload, nil
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+func (l fakePgListener) Close() error {
+	return nil
+}
+
+func TestContainerPgBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := &fakePostgres{}
+
+	// Wire each node in the documented order: the bus first, then the
+	// repositories.
+	newNode := func(node string) (*mockUserRepository, *repositorycache.CachedRepository[User]) {
+		container, err := NewContainer(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("Failed to create DI container: %v", err)
+		}
+		bus, err := pgbus.New(pgbus.Config{
+			DB:              db,
+			NewListener:     db.newListener,
+			FlushNamespaces: container.Namespaces,
+		})
+		if err != nil {
+			t.Fatalf("pgbus.New failed: %v", err)
+		}
+		if err := container.UseInvalidationBus(ctx, bus, cache.SubscriberConfig{Node: node}); err != nil {
+			t.Fatalf("UseInvalidationBus failed: %v", err)
+		}
+		mockRepo := newMockUserRepository()
+		mockRepo.Create(ctx, User{ID: "user-1", Name: "User"})
+		return mockRepo, NewCachedRepository(container, mockRepo)
+	}
+	_, writer := newNode("node-a")
+	readerRepo, reader := newNode("node-b")
+
+	reader.GetByID(ctx, "user-1")
+	if _, err := writer.Update(ctx, User{ID: "user-1", Name: "Renamed"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if db.published() == 0 {
+		t.Fatal("Expected the write to be published on the bus")
+	}
+	readerRepo.Update(ctx, User{ID: "user-1", Name: "Renamed"})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		user, err := reader.GetByID(ctx, "user-1")
+		if err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if user.Name == "Renamed" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the published write to invalidate the reader's cache, got %q", user.Name)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,74 @@
+package di
+
+import (
+	"context"
+	"sync"
+
+	"github.com/goliatone/go-repository-cache/repositorycache"
+)
+
+// AddObserver registers observer for the events of every repository built by
+// the container, including repositories built before the call.
+func (c *Container) AddObserver(observer repositorycache.Observer) {
+	if observer == nil || c.observers == nil {
+		return
+	}
+	c.observers.add(observer)
+}
+
+// observerSet forwards repository events to the observers registered on a
+// container.
+type observerSet struct {
+	mu        sync.RWMutex
+	observers []repositorycache.Observer
+}
+
+var _ repositorycache.Observer = (*observerSet)(nil)
+
+func (s *observerSet) add(observer repositorycache.Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+func (s *observerSet) list() []repositorycache.Observer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.observers
+}
+
+func (s *observerSet) OnHit(ctx context.Context, event repositorycache.ReadEvent) {
+	for _, observer := range s.list() {
+		observer.OnHit(ctx, event)
+	}
+}
+
+func (s *observerSet) OnMiss(ctx context.Context, event repositorycache.ReadEvent) {
+	for _, observer := range s.list() {
+		observer.OnMiss(ctx, event)
+	}
+}
+
+func (s *observerSet) OnFill(ctx context.Context, event repositorycache.ReadEvent) {
+	for _, observer := range s.list() {
+		observer.OnFill(ctx, event)
+	}
+}
+
+func (s *observerSet) OnBypass(ctx context.Context, event repositorycache.BypassEvent) {
+	for _, observer := range s.list() {
+		observer.OnBypass(ctx, event)
+	}
+}
+
+func (s *observerSet) OnInvalidate(ctx context.Context, event repositorycache.InvalidationEvent) {
+	for _, observer := range s.list() {
+		observer.OnInvalidate(ctx, event)
+	}
+}
+
+func (s *observerSet) OnInvalidationError(ctx context.Context, event repositorycache.InvalidationEvent, err error) {
+	for _, observer := range s.list() {
+		observer.OnInvalidationError(ctx, event, err)
+	}
+}
@@ -0,0 +1,300 @@
+// Package pgbus implements cache.InvalidationBus over Postgres LISTEN/NOTIFY.
+//
+// Publish sends each invalidation with pg_notify on a channel through a bun
+// connection, as compact JSON. Subscribe listens on the channel through a
+// Listener, such as the one pgdriver.NewListener returns, and reconnects with
+// backoff when the connection drops. Connect the bus before building the
+// repositories, which only publish when built afterwards:
+//
+//	bus, err := pgbus.New(pgbus.Config{
+//		DB:              db,
+//		FlushNamespaces: container.Namespaces,
+//		NewListener: func(ctx context.Context) (pgbus.Listener, error) {
+//			return pgdriver.NewListener(db), nil
+//		},
+//	})
+//	err = container.UseInvalidationBus(ctx, bus, cache.SubscriberConfig{Node: node})
+//	users := di.NewCachedRepository(container, userRepo)
+//
+// Notifications sent while a subscriber is disconnected are lost, so after a
+// reconnect the subscriber receives a flush of every namespace
+// Config.FlushNamespaces returns then, or of the whole cache without any.
+package pgbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/uptrace/bun"
+)
+
+// DefaultChannel is the notification channel used when Config.Channel is empty.
+const DefaultChannel = "cache_invalidations"
+
+// Defaults used by New for unset Config fields.
+const (
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// maxPayload is the largest payload pg_notify accepts by default.
+const maxPayload = 7999
+
+// Listener receives the notifications of a Postgres connection. It is
+// implemented by *pgdriver.Listener.
+type Listener interface {
+	Listen(ctx context.Context, channels ...string) error
+	Receive(ctx context.Context) (channel string, payload string, err error)
+	Close() error
+}
+
+// Config configures a Bus.
+type Config struct {
+	// DB publishes the notifications with SELECT pg_notify(?, ?), such as a
+	// *bun.DB.
+	DB bun.IConn
+	// Channel is the notification channel. Empty uses DefaultChannel.
+	Channel string
+	// NewListener opens a listener connection. It is called again to
+	// reconnect after the listener fails.
+	NewListener func(ctx context.Context) (Listener, error)
+	// Backoff is the delay before the first reconnect attempt, doubled after
+	// each failed attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FlushNamespaces returns the namespaces subscribers flush after a
+	// reconnect, as returned by CachedRepository.Namespace. It is called on
+	// every reconnect, so di.Container.Namespaces covers repositories built
+	// after the bus. Nil, or no namespaces, flushes the whole cache.
+	FlushNamespaces func() []string
+	// OnError is called with listener and decoding errors.
+	OnError func(err error)
+}
+
+// Bus is a cache.InvalidationBus over Postgres LISTEN/NOTIFY.
+type Bus struct {
+	cfg Config
+}
+
+var _ cache.InvalidationBus = (*Bus)(nil)
+
+// New creates a Bus.
+func New(cfg Config) (*Bus, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("pgbus: DB is required")
+	}
+	if cfg.NewListener == nil {
+		return nil, errors.New("pgbus: NewListener is required")
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = DefaultChannel
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	return &Bus{cfg: cfg}, nil
+}
+
+// Channel returns the notification channel of the bus.
+func (b *Bus) Channel() string {
+	return b.cfg.Channel
+}
+
+// payload is the compact wire format of a message.
+type payload struct {
+	Namespace string   `json:"n,omitempty"`
+	Tags      []string `json:"t,omitempty"`
+	Keys      []string `json:"k,omitempty"`
+	Prefixes  []string `json:"p,omitempty"`
+	Origin    string   `json:"o,omitempty"`
+}
+
+// Publish implements cache.InvalidationBus.Publish. A message too large for
+// pg_notify is replaced by a flush of its namespace.
+func (b *Bus) Publish(ctx context.Context, msg cache.InvalidationMessage) error {
+	data, err := encode(msg)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxPayload {
+		if msg.Namespace == "" {
+			return fmt.Errorf("pgbus: payload of %d bytes exceeds the pg_notify limit", len(data))
+		}
+		if data, err = encode(flushMessage(msg.Namespace, msg.Origin)); err != nil {
+			return err
+		}
+	}
+	if _, err := b.cfg.DB.ExecContext(ctx, "SELECT pg_notify(?, ?)", b.cfg.Channel, string(data)); err != nil {
+		return fmt.Errorf("pgbus: notify: %w", err)
+	}
+	return nil
+}
+
+// Subscribe implements cache.InvalidationBus.Subscribe. It opens a listener and
+// returns its error when that fails; later failures are reported to OnError and
+// the listener reconnects in the background until ctx is done.
+func (b *Bus) Subscribe(ctx context.Context, handler cache.InvalidationHandler) error {
+	if handler == nil {
+		return errors.New("pgbus: nil invalidation handler")
+	}
+	ln, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	go b.run(ctx, ln, handler)
+	return nil
+}
+
+// connect opens a listener on the channel.
+func (b *Bus) connect(ctx context.Context) (Listener, error) {
+	ln, err := b.cfg.NewListener(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pgbus: open listener: %w", err)
+	}
+	if err := ln.Listen(ctx, b.cfg.Channel); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("pgbus: listen on %q: %w", b.cfg.Channel, err)
+	}
+	return ln, nil
+}
+
+// run receives notifications on ln, reconnecting with backoff until ctx is
+// done.
+func (b *Bus) run(ctx context.Context, ln Listener, handler cache.InvalidationHandler) {
+	for {
+		err := b.receive(ctx, ln, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		b.report(fmt.Errorf("pgbus: receive: %w", err))
+
+		if ln = b.reconnect(ctx); ln == nil {
+			return
+		}
+		// Notifications sent while disconnected are lost.
+		b.flush(ctx, handler)
+	}
+}
+
+// receive hands the notifications of ln to handler until Receive fails or ctx
+// is done, then closes ln.
+func (b *Bus) receive(ctx context.Context, ln Listener, handler cache.InvalidationHandler) error {
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer func() {
+		if stop() {
+			_ = ln.Close()
+		}
+	}()
+	for {
+		channel, data, err := ln.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if channel != b.cfg.Channel {
+			continue
+		}
+		msg, err := decode(data)
+		if err != nil {
+			b.report(err)
+			continue
+		}
+		handler(ctx, msg)
+	}
+}
+
+// reconnect opens a new listener, backing off between attempts, and returns
+// nil once ctx is done.
+func (b *Bus) reconnect(ctx context.Context) Listener {
+	backoff := b.cfg.Backoff
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		ln, err := b.connect(ctx)
+		if err == nil {
+			return ln
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		b.report(err)
+		backoff = min(backoff*2, b.cfg.MaxBackoff)
+	}
+}
+
+// flush hands handler a flush of every namespace FlushNamespaces returns, or of
+// the whole cache.
+func (b *Bus) flush(ctx context.Context, handler cache.InvalidationHandler) {
+	var namespaces []string
+	if b.cfg.FlushNamespaces != nil {
+		namespaces = b.cfg.FlushNamespaces()
+	}
+	if len(namespaces) == 0 {
+		handler(ctx, cache.InvalidationMessage{Invalidation: cache.Invalidation{Prefixes: []string{""}}})
+		return
+	}
+	for _, namespace := range namespaces {
+		handler(ctx, flushMessage(namespace, ""))
+	}
+}
+
+func (b *Bus) report(err error) {
+	if b.cfg.OnError != nil {
+		b.cfg.OnError(err)
+	}
+}
+
+// flushMessage returns a message deleting every entry of namespace.
+func flushMessage(namespace, origin string) cache.InvalidationMessage {
+	return cache.InvalidationMessage{
+		Invalidation: cache.Invalidation{
+			Namespace: namespace,
+			Prefixes:  []string{namespace + cache.KeySeparator},
+		},
+		Origin: origin,
+	}
+}
+
+func encode(msg cache.InvalidationMessage) ([]byte, error) {
+	data, err := json.Marshal(payload{
+		Namespace: msg.Namespace,
+		Tags:      msg.Tags,
+		Keys:      msg.Keys,
+		Prefixes:  msg.Prefixes,
+		Origin:    msg.Origin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pgbus: encode invalidation: %w", err)
+	}
+	return data, nil
+}
+
+func decode(data string) (cache.InvalidationMessage, error) {
+	var p payload
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return cache.InvalidationMessage{}, fmt.Errorf("pgbus: decode invalidation: %w", err)
+	}
+	return cache.InvalidationMessage{
+		Invalidation: cache.Invalidation{
+			Namespace: p.Namespace,
+			Tags:      p.Tags,
+			Keys:      p.Keys,
+			Prefixes:  p.Prefixes,
+		},
+		Origin: p.Origin,
+	}, nil
+}
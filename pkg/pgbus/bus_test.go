@@ -0,0 +1,289 @@
+package pgbus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// fakeServer stands in for Postgres: ExecContext runs pg_notify and delivers
+// the payload to the fake listeners of the channel.
+type fakeServer struct {
+	mu        sync.Mutex
+	listeners map[*fakeListener]string
+	payloads  []string
+	down      bool
+	opened    int
+}
+
+type notification struct {
+	channel, payload string
+}
+
+type fakeListener struct {
+	server *fakeServer
+	ch     chan notification
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{listeners: make(map[*fakeListener]string)}
+}
+
+func (s *fakeServer) newListener(ctx context.Context) (Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+	s.opened++
+	return &fakeListener{server: s, ch: make(chan notification, 16), closed: make(chan struct{})}, nil
+}
+
+func (s *fakeServer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *fakeServer) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return nil
+}
+
+func (s *fakeServer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if query != "SELECT pg_notify(?, ?)" || len(args) != 2 {
+		return nil, errors.New("unexpected query")
+	}
+	channel, payload := args[0].(string), args[1].(string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+	s.payloads = append(s.payloads, payload)
+	for ln, listening := range s.listeners {
+		if listening == channel {
+			ln.ch <- notification{channel: channel, payload: payload}
+		}
+	}
+	return nil, nil
+}
+
+// drop breaks every open listener connection and refuses new ones until up.
+func (s *fakeServer) drop() {
+	s.mu.Lock()
+	listeners := make([]*fakeListener, 0, len(s.listeners))
+	for ln := range s.listeners {
+		listeners = append(listeners, ln)
+	}
+	s.down = true
+	s.mu.Unlock()
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
+
+func (s *fakeServer) up() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = false
+}
+
+func (s *fakeServer) listening() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.listeners)
+}
+
+func (l *fakeListener) Listen(ctx context.Context, channels ...string) error {
+	l.server.mu.Lock()
+	defer l.server.mu.Unlock()
+	l.server.listeners[l] = channels[0]
+	return nil
+}
+
+func (l *fakeListener) Receive(ctx context.Context) (string, string, error) {
+	select {
+	case n := <-l.ch:
+		return n.channel, n.payload, nil
+	case <-l.closed:
+		return "", "", errors.New("connection closed")
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+func (l *fakeListener) Close() error {
+	l.once.Do(func() {
+		l.server.mu.Lock()
+		delete(l.server.listeners, l)
+		l.server.mu.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+func subscribe(t *testing.T, bus *Bus) <-chan cache.InvalidationMessage {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	received := make(chan cache.InvalidationMessage, 16)
+	if err := bus.Subscribe(ctx, func(ctx context.Context, msg cache.InvalidationMessage) {
+		received <- msg
+	}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	return received
+}
+
+func next(t *testing.T, received <-chan cache.InvalidationMessage) cache.InvalidationMessage {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a message")
+		return cache.InvalidationMessage{}
+	}
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+	msg := cache.InvalidationMessage{
+		Invalidation: cache.Invalidation{Namespace: "users", Tags: []string{"users::id::1"}, Keys: []string{"users::get"}},
+		Origin:       "node-a",
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		server := newFakeServer()
+		bus, err := New(Config{DB: server, NewListener: server.newListener})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		received := subscribe(t, bus)
+		if err := bus.Publish(ctx, msg); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		got := next(t, received)
+		if got.Origin != "node-a" || got.Namespace != "users" || len(got.Tags) != 1 || len(got.Keys) != 1 || got.Prefixes != nil {
+			t.Fatalf("unexpected message: %+v", got)
+		}
+		if want := `{"n":"users","t":["users::id::1"],"k":["users::get"],"o":"node-a"}`; server.payloads[0] != want {
+			t.Fatalf("expected payload %s, got %s", want, server.payloads[0])
+		}
+		if bus.Channel() != DefaultChannel {
+			t.Fatalf("expected the default channel, got %q", bus.Channel())
+		}
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		server := newFakeServer()
+		var errs []error
+		var mu sync.Mutex
+		bus, _ := New(Config{
+			DB:              server,
+			NewListener:     server.newListener,
+			Backoff:         time.Millisecond,
+			FlushNamespaces: func() []string { return []string{"users", "orders"} },
+			OnError: func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				errs = append(errs, err)
+			},
+		})
+		received := subscribe(t, bus)
+
+		server.drop()
+		time.Sleep(5 * time.Millisecond)
+		server.up()
+		for _, namespace := range []string{"users", "orders"} {
+			got := next(t, received)
+			if got.Namespace != namespace || len(got.Prefixes) != 1 || got.Prefixes[0] != namespace+cache.KeySeparator || got.Origin != "" {
+				t.Fatalf("expected a flush of %s after reconnecting, got %+v", namespace, got)
+			}
+		}
+		if err := bus.Publish(ctx, msg); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if got := next(t, received); got.Origin != "node-a" {
+			t.Fatalf("expected delivery after reconnecting, got %+v", got)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(errs) == 0 {
+			t.Fatal("expected the dropped connection to be reported")
+		}
+	})
+
+	t.Run("ReconnectFlushesEverything", func(t *testing.T) {
+		server := newFakeServer()
+		bus, _ := New(Config{DB: server, NewListener: server.newListener, Backoff: time.Millisecond})
+		received := subscribe(t, bus)
+
+		server.drop()
+		server.up()
+		if got := next(t, received); got.Namespace != "" || len(got.Prefixes) != 1 || got.Prefixes[0] != "" {
+			t.Fatalf("expected a flush of the whole cache, got %+v", got)
+		}
+	})
+
+	t.Run("OversizedPayload", func(t *testing.T) {
+		server := newFakeServer()
+		bus, _ := New(Config{DB: server, NewListener: server.newListener})
+		received := subscribe(t, bus)
+
+		large := msg
+		large.Keys = []string{strings.Repeat("k", maxPayload)}
+		if err := bus.Publish(ctx, large); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if got := next(t, received); len(got.Keys) != 0 || len(got.Prefixes) != 1 || got.Prefixes[0] != "users::" {
+			t.Fatalf("expected a namespace flush instead of the oversized message, got %+v", got)
+		}
+
+		large.Namespace = ""
+		if err := bus.Publish(ctx, large); err == nil {
+			t.Fatal("expected an error for an oversized message without a namespace")
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		server := newFakeServer()
+		bus, _ := New(Config{DB: server, NewListener: server.newListener})
+		subCtx, cancel := context.WithCancel(ctx)
+		if err := bus.Subscribe(subCtx, func(context.Context, cache.InvalidationMessage) {}); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		cancel()
+		deadline := time.Now().Add(time.Second)
+		for server.listening() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("expected the listener to be closed after cancel")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		server := newFakeServer()
+		if _, err := New(Config{NewListener: server.newListener}); err == nil {
+			t.Fatal("expected an error without DB")
+		}
+		if _, err := New(Config{DB: server}); err == nil {
+			t.Fatal("expected an error without NewListener")
+		}
+		server.down = true
+		bus, _ := New(Config{DB: server, NewListener: server.newListener})
+		if err := bus.Subscribe(ctx, func(context.Context, cache.InvalidationMessage) {}); err == nil {
+			t.Fatal("expected the first connection error")
+		}
+		if err := bus.Publish(ctx, msg); err == nil {
+			t.Fatal("expected the notify error")
+		}
+	})
+}
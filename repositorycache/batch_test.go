@@ -0,0 +1,137 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// pagedListRepository paginates List like go-repository-bun does: a default
+// limit applies unless the criteria set their own.
+type pagedListRepository struct {
+	*mockRepository[TestUser]
+	defaultLimit int
+}
+
+var limitPattern = regexp.MustCompile(`LIMIT (\d+)`)
+
+func (r *pagedListRepository) List(ctx context.Context, criteria ...repository.SelectCriteria) ([]TestUser, int, error) {
+	r.recordCall("List")
+	query := bun.NewSelectQuery(nil).Limit(r.defaultLimit)
+	for _, c := range criteria {
+		query = query.Apply(c)
+	}
+	sql, err := query.AppendQuery(schema.NewNopFormatter(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit := len(r.listRecords)
+	if match := limitPattern.FindSubmatch(sql); match != nil {
+		limit, _ = strconv.Atoi(string(match[1]))
+	}
+	records := r.listRecords
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, len(r.listRecords), nil
+}
+
+func newPagedListRepository(count, defaultLimit int) (*pagedListRepository, []string) {
+	records := make([]TestUser, count)
+	ids := make([]string, count)
+	for i := range records {
+		ids[i] = fmt.Sprintf("user-%03d", i)
+		records[i] = TestUser{ID: ids[i], Name: fmt.Sprintf("User %d", i)}
+	}
+	return &pagedListRepository{
+		mockRepository: &mockRepository[TestUser]{listRecords: records, listTotal: count},
+		defaultLimit:   defaultLimit,
+	}, ids
+}
+
+func TestCachedRepository_GetByIDs(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{
+		listRecords:   []TestUser{{ID: "user-2", Name: "User 2"}},
+		getByIDResult: TestUser{ID: "user-1", Name: "User 1"},
+		updateResult:  TestUser{ID: "user-2", Name: "User 2"},
+	}
+	cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+	ctx := context.Background()
+
+	if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		records, err := cached.GetByIDs(ctx, []string{"user-1", "user-2", "user-3", "user-2", ""})
+		if err != nil {
+			t.Fatalf("GetByIDs failed: %v", err)
+		}
+		if len(records) != 2 || records["user-1"].Name != "User 1" || records["user-2"].Name != "User 2" {
+			t.Fatalf("expected user-1 from cache and user-2 from base, got %v", records)
+		}
+	}
+	if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, []string{"GetByID", "List"}) {
+		t.Fatalf("expected one List for the uncached ids, got %v", calls)
+	}
+
+	baseRepo.clearCalls()
+	baseRepo.getByIDResult = TestUser{ID: "user-2", Name: "User 2"}
+	if _, err := cached.GetByID(ctx, "user-2"); err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if calls := baseRepo.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected GetByID to reuse the batch entry, got %v", calls)
+	}
+
+	notFound := errors.New("record not found")
+	baseRepo.getByIDError = notFound
+	if _, err := cached.GetByID(ctx, "user-3"); !errors.Is(err, notFound) {
+		t.Fatalf("expected the base not found error for an id the batch did not return, got %v", err)
+	}
+	baseRepo.getByIDError = nil
+
+	if _, err := cached.Update(ctx, TestUser{ID: "user-2", Name: "User 2"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	baseRepo.clearCalls()
+	if _, err := cached.GetByIDs(ctx, []string{"user-1", "user-2"}); err != nil {
+		t.Fatalf("GetByIDs failed: %v", err)
+	}
+	if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, []string{"List"}) {
+		t.Fatalf("expected evicted ids to be refetched with one List, got %v", calls)
+	}
+}
+
+func TestCachedRepository_GetByIDsOverridesDefaultPagination(t *testing.T) {
+	baseRepo, ids := newPagedListRepository(60, 25)
+	cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+
+	records, err := cached.GetByIDs(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("GetByIDs failed: %v", err)
+	}
+	if len(records) != len(ids) {
+		t.Fatalf("expected all %d records past the default page size, got %d", len(ids), len(records))
+	}
+	if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, []string{"List"}) {
+		t.Fatalf("expected a single List, got %v", calls)
+	}
+}
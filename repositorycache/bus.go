@@ -0,0 +1,77 @@
+package repositorycache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+type invalidationBus struct {
+	bus  cache.InvalidationBus
+	node string
+}
+
+// SetInvalidationBus publishes the invalidations of every write on bus, with
+// node as their origin, so the other processes caching the namespace invalidate
+// their own copies. Nil stops publishing. Pair it with cache.SubscribeInvalidations
+// on every node, using the same node ID.
+//
+// A write publishes the tags, keys and prefixes it invalidated or tried to
+// invalidate, after invalidating the local cache. Publish errors wrap
+// cache.ErrPublishFailed and are handled like other invalidation failures: they
+// reach observers and the invalidation failure policy, whose retry mode publishes
+// again instead of enqueueing them.
+func (c *CachedRepository[T]) SetInvalidationBus(bus cache.InvalidationBus, node string) {
+	if bus == nil {
+		c.bus.Store(nil)
+		return
+	}
+	c.bus.Store(&invalidationBus{bus: bus, node: node})
+}
+
+// InvalidationBus returns the bus the repository publishes on and its node ID.
+func (c *CachedRepository[T]) InvalidationBus() (cache.InvalidationBus, string) {
+	if bus := c.bus.Load(); bus != nil {
+		return bus.bus, bus.node
+	}
+	return nil, ""
+}
+
+// publishInvalidation publishes everything inv invalidated or failed to, and
+// records a publish failure on inv.
+func (c *CachedRepository[T]) publishInvalidation(ctx context.Context, inv *invalidation) {
+	if c.bus.Load() == nil {
+		return
+	}
+	published := inv.event
+	for _, failure := range inv.failures {
+		published.Tags = appendTags(published.Tags, failure.event.Tags)
+		published.Keys = appendTags(published.Keys, failure.event.Keys)
+		published.Prefixes = appendTags(published.Prefixes, failure.event.Prefixes)
+	}
+	failed := &InvalidationError{InvalidationEvent: published}
+	if failed.Invalidation().Empty() {
+		return
+	}
+	if failed = c.publish(ctx, failed); failed != nil {
+		inv.failures = append(inv.failures, invalidationFailure{event: failed.InvalidationEvent, err: failed.Err, publish: true})
+	}
+}
+
+// publish publishes the entries of event and returns it with the error when
+// publishing fails, or nil.
+func (c *CachedRepository[T]) publish(ctx context.Context, event *InvalidationError) *InvalidationError {
+	bus := c.bus.Load()
+	if bus == nil {
+		return nil
+	}
+	msg := cache.InvalidationMessage{Invalidation: event.Invalidation(), Origin: bus.node}
+	if err := bus.bus.Publish(ctx, msg); err != nil {
+		return &InvalidationError{
+			InvalidationEvent: event.InvalidationEvent,
+			Err:               fmt.Errorf("%w: %w", cache.ErrPublishFailed, err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// failingBus is an InvalidationBus whose Publish fails.
+type failingBus struct {
+	cache.MemoryBus
+	err error
+}
+
+func (b *failingBus) Publish(ctx context.Context, msg cache.InvalidationMessage) error {
+	return b.err
+}
+
+func TestCachedRepository_InvalidationBus(t *testing.T) {
+	ctx := context.Background()
+	newNode := func(t *testing.T, bus cache.InvalidationBus, node string, opts ...Option) (*mockRepository[TestUser], *CachedRepository[TestUser]) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			getByIDResult: TestUser{ID: "user-1", Name: "User 1"},
+			updateResult:  TestUser{ID: "user-1", Name: "Updated"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		subCtx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		if err := cache.SubscribeInvalidations(subCtx, bus, cacheService, cache.SubscriberConfig{Node: node}); err != nil {
+			t.Fatalf("SubscribeInvalidations failed: %v", err)
+		}
+		opts = append(opts, WithInvalidationBus(bus, node))
+		return baseRepo, NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), opts...)
+	}
+
+	t.Run("RemoteWriteInvalidatesLocalCache", func(t *testing.T) {
+		bus := cache.NewMemoryBus()
+		_, writer := newNode(t, bus, "node-a")
+		readerRepo, reader := newNode(t, bus, "node-b")
+
+		reader.GetByID(ctx, "user-1")
+		reader.GetByID(ctx, "user-1")
+		if calls := countCalls(readerRepo.getCalls(), "GetByID"); calls != 1 {
+			t.Fatalf("expected the second read to be cached, got %d base calls", calls)
+		}
+
+		if _, err := writer.Update(ctx, TestUser{ID: "user-1", Name: "Updated"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		reader.GetByID(ctx, "user-1")
+		if calls := countCalls(readerRepo.getCalls(), "GetByID"); calls != 2 {
+			t.Fatalf("expected the remote update to invalidate the entry, got %d base calls", calls)
+		}
+		if bus, node := writer.InvalidationBus(); bus == nil || node != "node-a" {
+			t.Fatalf("expected the configured bus, got %v, %q", bus, node)
+		}
+	})
+
+	t.Run("RemoteRenameInvalidatesIdentifierEntries", func(t *testing.T) {
+		bus := cache.NewMemoryBus()
+		_, writer := newNode(t, bus, "node-a", WithIdentifierFields("Name"))
+		readerRepo, reader := newNode(t, bus, "node-b", WithIdentifierFields("Name"))
+		readerRepo.getByIDResult2 = TestUser{ID: "user-1", Name: "User 1"}
+		tenantCtx := repository.WithScopeData(repository.WithSelectScopes(ctx, "tenant"), "tenant", "tenant-a")
+
+		reader.GetByIdentifier(tenantCtx, "User 1")
+		reader.GetByIdentifier(tenantCtx, "User 1")
+		if calls := countCalls(readerRepo.getCalls(), "GetByIdentifier"); calls != 1 {
+			t.Fatalf("expected the second read to be cached, got %d base calls", calls)
+		}
+
+		// The writer never read the record, so it cannot know its old name.
+		if _, err := writer.Update(ctx, TestUser{ID: "user-1", Name: "Updated"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		reader.GetByIdentifier(tenantCtx, "User 1")
+		if calls := countCalls(readerRepo.getCalls(), "GetByIdentifier"); calls != 2 {
+			t.Fatalf("expected the remote rename to invalidate the old identifier, got %d base calls", calls)
+		}
+	})
+
+	t.Run("PublishFailure", func(t *testing.T) {
+		errDown := errors.New("bus unavailable")
+		bus := &failingBus{err: errDown}
+		_, writer := newNode(t, bus, "node-a",
+			WithInvalidationFailurePolicy(InvalidationFailurePolicy{Mode: InvalidationFailureReturn}))
+
+		_, err := writer.Update(ctx, TestUser{ID: "user-1", Name: "Updated"})
+		var failed *InvalidationError
+		if !errors.As(err, &failed) || !errors.Is(err, cache.ErrPublishFailed) || !errors.Is(err, errDown) {
+			t.Fatalf("expected a publish failure, got %v", err)
+		}
+		if len(failed.Tags) == 0 {
+			t.Fatalf("expected the unpublished tags, got %+v", failed.InvalidationEvent)
+		}
+	})
+}
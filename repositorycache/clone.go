@@ -0,0 +1,390 @@
+package repositorycache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cloner returns a deep copy of value.
+type Cloner[T any] func(value T) (T, error)
+
+// CloneMode selects when cached values are copied. Modes combine with |.
+type CloneMode int
+
+// CloneNone shares cached values with callers. It is the default.
+const CloneNone CloneMode = 0
+
+const (
+	// CloneOnRead hands every caller its own copy of a cached value, so no caller
+	// can mutate what the cache holds.
+	CloneOnRead CloneMode = 1 << iota
+	// CloneOnWrite stores a copy of every value entering the cache, so the caller
+	// that fetched or wrote a value can keep mutating it. Cache hits still share
+	// the stored copy unless CloneOnRead is set too.
+	CloneOnWrite
+)
+
+// ClonePolicy configures defensive copying of cached values.
+type ClonePolicy[T any] struct {
+	Mode CloneMode
+	// Cloner copies values. Nil uses ReflectCloner.
+	Cloner Cloner[T]
+	// DetectMutations keeps a private copy of every value the decorator stores
+	// and compares cache hits against it. It doubles memory and adds a deep
+	// comparison to every hit, so use it in tests and debugging only. Copies
+	// are dropped when the decorator deletes their key or prefix, and otherwise
+	// once the value can no longer be cached.
+	DetectMutations bool
+	// OnMutation is called with the cache key of a value found mutated. Nil
+	// logs it with the standard logger. Each stored value is reported at most
+	// once.
+	OnMutation func(key string)
+}
+
+// SetClonePolicy configures defensive copying of cached values. Copies cover
+// values returned by cached reads, GetByIDs and normalized lists, and records
+// stored by write-through. The zero policy disables copying.
+func (c *CachedRepository[T]) SetClonePolicy(policy ClonePolicy[T]) {
+	if policy.Mode == CloneNone && !policy.DetectMutations {
+		c.clonePolicy.Store(nil)
+		return
+	}
+	if policy.Cloner == nil {
+		policy.Cloner = ReflectCloner[T]()
+	}
+	c.clonePolicy.Store(&clonePolicy[T]{ClonePolicy: policy, lifetime: c.snapshotRetention})
+}
+
+// snapshotRetentionFactor scales the longest entry TTL into how long a mutation
+// detection snapshot is kept.
+const snapshotRetentionFactor = 2
+
+// snapshotRetention returns how long a mutation detection snapshot is kept
+// after its value was stored.
+func (c *CachedRepository[T]) snapshotRetention() time.Duration {
+	return c.longestEntryTTL() * snapshotRetentionFactor
+}
+
+// clonePolicy is the active ClonePolicy plus the snapshots kept for mutation
+// detection.
+type clonePolicy[T any] struct {
+	ClonePolicy[T]
+	// lifetime returns how long a snapshot is kept.
+	lifetime  func() time.Duration
+	snapshots snapshotStore
+}
+
+func (p *clonePolicy[T]) onRead() bool  { return p != nil && p.Mode&CloneOnRead != 0 }
+func (p *clonePolicy[T]) onWrite() bool { return p != nil && p.Mode&CloneOnWrite != 0 }
+
+// stored prepares value for storage under key with tags: it copies value under
+// CloneOnWrite and snapshots the stored value when mutations are detected.
+func (p *clonePolicy[T]) stored(key string, value any, tags []string) (any, error) {
+	if p == nil {
+		return value, nil
+	}
+	stored := value
+	if p.onWrite() {
+		copied, err := p.clone(value)
+		if err != nil {
+			return nil, err
+		}
+		stored = copied
+	}
+	if p.DetectMutations {
+		snapshot, err := p.clone(stored)
+		if err != nil {
+			return nil, err
+		}
+		p.snapshots.add(key, snapshot, tags, p.lifetime())
+	}
+	return stored, nil
+}
+
+// forgetKey drops the snapshot of key.
+func (p *clonePolicy[T]) forgetKey(key string) {
+	if p != nil && p.DetectMutations {
+		p.snapshots.forget(func(stored string, _ []string) bool { return stored == key })
+	}
+}
+
+// forgetPrefix drops the snapshots of the keys starting with prefix.
+func (p *clonePolicy[T]) forgetPrefix(prefix string) {
+	if p != nil && p.DetectMutations {
+		p.snapshots.forget(func(stored string, _ []string) bool { return strings.HasPrefix(stored, prefix) })
+	}
+}
+
+// forgetTags drops the snapshots stored under any of tags.
+func (p *clonePolicy[T]) forgetTags(tags []string) {
+	if p == nil || !p.DetectMutations || len(tags) == 0 {
+		return
+	}
+	invalidated := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		invalidated[tag] = true
+	}
+	p.snapshots.forget(func(_ string, tags []string) bool {
+		for _, tag := range tags {
+			if invalidated[tag] {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// snapshotStore holds the private copies of stored values, with the tags they
+// were stored under. Snapshots are dropped with their entries, or after their
+// lifetime when the entry left the cache another way, such as capacity
+// eviction, so the store stays bounded by the entries that can still exist.
+type snapshotStore struct {
+	mu        sync.Mutex
+	byKey     map[string]mutationSnapshot
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// mutationSnapshot is the private copy of a stored value, kept until deadline.
+type mutationSnapshot struct {
+	value    any
+	tags     []string
+	deadline time.Time
+}
+
+// add keeps value for key, sweeping expired snapshots first when the last
+// sweep is older than their lifetime.
+func (s *snapshotStore) add(key string, value any, tags []string, lifetime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock()
+	if now.Sub(s.lastSweep) >= lifetime {
+		for stored, snapshot := range s.byKey {
+			if !now.Before(snapshot.deadline) {
+				delete(s.byKey, stored)
+			}
+		}
+		s.lastSweep = now
+	}
+	if s.byKey == nil {
+		s.byKey = make(map[string]mutationSnapshot)
+	}
+	s.byKey[key] = mutationSnapshot{value: value, tags: append([]string(nil), tags...), deadline: now.Add(lifetime)}
+}
+
+// lookup returns the snapshot of key.
+func (s *snapshotStore) lookup(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.byKey[key]
+	return snapshot.value, ok
+}
+
+// take returns the snapshot of key and forgets it.
+func (s *snapshotStore) take(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.byKey[key]
+	delete(s.byKey, key)
+	return snapshot.value, ok
+}
+
+// forget drops the snapshots matched reports true for.
+func (s *snapshotStore) forget(matched func(key string, tags []string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, snapshot := range s.byKey {
+		if matched(key, snapshot.tags) {
+			delete(s.byKey, key)
+		}
+	}
+}
+
+// size returns the number of snapshots held, expired or not.
+func (s *snapshotStore) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byKey)
+}
+
+func (s *snapshotStore) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// read checks a value served from the cache under key and copies it under
+// CloneOnRead.
+func (p *clonePolicy[T]) read(key string, value any) (any, error) {
+	if p == nil {
+		return value, nil
+	}
+	if p.DetectMutations {
+		if snapshot, ok := p.snapshots.lookup(key); ok && !reflect.DeepEqual(snapshot, value) {
+			if _, reported := p.snapshots.take(key); reported {
+				p.mutated(key)
+			}
+		}
+	}
+	if !p.onRead() {
+		return value, nil
+	}
+	return p.clone(value)
+}
+
+// mutated reports the mutation of the value cached under key.
+func (p *clonePolicy[T]) mutated(key string) {
+	if p.OnMutation == nil {
+		log.Printf("repositorycache: cached value %q was mutated", key)
+		return
+	}
+	p.OnMutation(key)
+}
+
+// clone copies the cached forms of T: records and list results.
+func (p *clonePolicy[T]) clone(value any) (any, error) {
+	switch v := value.(type) {
+	case T:
+		return p.Cloner(v)
+	case listResult[T]:
+		records, err := p.cloneRecords(v.Records)
+		return listResult[T]{Records: records, Total: v.Total}, err
+	default:
+		return value, nil
+	}
+}
+
+func (p *clonePolicy[T]) cloneRecords(records []T) ([]T, error) {
+	if records == nil {
+		return nil, nil
+	}
+	copied := make([]T, len(records))
+	for i, record := range records {
+		clone, err := p.Cloner(record)
+		if err != nil {
+			return nil, err
+		}
+		copied[i] = clone
+	}
+	return copied, nil
+}
+
+// ReflectCloner returns a Cloner that deep copies values with reflection. It
+// follows pointers, slices, maps, arrays and interfaces and preserves shared and
+// cyclic pointers. Unexported struct fields are copied shallowly.
+func ReflectCloner[T any]() Cloner[T] {
+	return func(value T) (T, error) {
+		src := reflect.ValueOf(&value).Elem()
+		dst := reflect.New(src.Type()).Elem()
+		deepCopy(dst, src, make(map[copiedPointer]reflect.Value))
+		return dst.Interface().(T), nil
+	}
+}
+
+// Codec encodes and decodes values for CodecCloner.
+type Codec interface {
+	Marshal(value any) ([]byte, error)
+	Unmarshal(data []byte, value any) error
+}
+
+// JSONCodec is a Codec backed by encoding/json.
+type JSONCodec struct{}
+
+// Marshal encodes value as JSON.
+func (JSONCodec) Marshal(value any) ([]byte, error) { return json.Marshal(value) }
+
+// Unmarshal decodes JSON data into value.
+func (JSONCodec) Unmarshal(data []byte, value any) error { return json.Unmarshal(data, value) }
+
+// CodecCloner returns a Cloner that copies values with an encode/decode round
+// trip through codec. Only the fields the codec serializes survive the copy.
+func CodecCloner[T any](codec Codec) Cloner[T] {
+	return func(value T) (T, error) {
+		var copied T
+		data, err := codec.Marshal(value)
+		if err != nil {
+			return copied, fmt.Errorf("repositorycache: clone: %w", err)
+		}
+		if err := codec.Unmarshal(data, &copied); err != nil {
+			return copied, fmt.Errorf("repositorycache: clone: %w", err)
+		}
+		return copied, nil
+	}
+}
+
+// copiedPointer identifies a source pointer. The type is part of the identity
+// because a struct and its first field share an address.
+type copiedPointer struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// deepCopy copies src into dst, which must be settable. seen maps source pointers
+// to their copies.
+func deepCopy(dst, src reflect.Value, seen map[copiedPointer]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		id := copiedPointer{addr: src.Pointer(), typ: src.Type()}
+		if copied, ok := seen[id]; ok {
+			dst.Set(copied)
+			return
+		}
+		copied := reflect.New(src.Elem().Type())
+		seen[id] = copied
+		deepCopy(copied.Elem(), src.Elem(), seen)
+		dst.Set(copied)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := src.Elem()
+		copied := reflect.New(elem.Type()).Elem()
+		deepCopy(copied, elem, seen)
+		dst.Set(copied)
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i), seen)
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(copied.Index(i), src.Index(i), seen)
+		}
+		dst.Set(copied)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i), seen)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			key := reflect.New(iter.Key().Type()).Elem()
+			deepCopy(key, iter.Key(), seen)
+			value := reflect.New(iter.Value().Type()).Elem()
+			deepCopy(value, iter.Value(), seen)
+			copied.SetMapIndex(key, value)
+		}
+		dst.Set(copied)
+	default:
+		dst.Set(src)
+	}
+}
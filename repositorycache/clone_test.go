@@ -0,0 +1,213 @@
+package repositorycache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+type clonedProfile struct {
+	Tags  []string
+	Attrs map[string]*string
+}
+
+type clonedUser struct {
+	ID      string
+	Name    string
+	Profile *clonedProfile
+	Self    *clonedUser
+	hidden  *string
+}
+
+func TestReflectCloner(t *testing.T) {
+	attr := "blue"
+	hidden := "hidden"
+	original := &clonedUser{
+		ID:      "user-1",
+		Name:    "Original",
+		Profile: &clonedProfile{Tags: []string{"a"}, Attrs: map[string]*string{"color": &attr}},
+		hidden:  &hidden,
+	}
+	original.Self = original
+
+	copied, err := ReflectCloner[*clonedUser]()(original)
+	if err != nil {
+		t.Fatalf("clone failed: %v", err)
+	}
+	if copied == original || copied.Profile == original.Profile {
+		t.Fatal("expected pointers to be copied")
+	}
+	if copied.Self != copied {
+		t.Fatal("expected cyclic pointer to point at the copy")
+	}
+	copied.Profile.Tags[0] = "changed"
+	*copied.Profile.Attrs["color"] = "red"
+	if original.Profile.Tags[0] != "a" || attr != "blue" {
+		t.Fatal("expected nested slices and maps to be copied")
+	}
+	if copied.hidden != original.hidden {
+		t.Fatal("expected unexported fields to be copied shallowly")
+	}
+}
+
+func TestCodecCloner(t *testing.T) {
+	original := &TestUser{ID: "user-1", Name: "Original"}
+	copied, err := CodecCloner[*TestUser](JSONCodec{})(original)
+	if err != nil {
+		t.Fatalf("clone failed: %v", err)
+	}
+	if copied == original || *copied != *original {
+		t.Fatalf("expected an equal copy, got %+v", copied)
+	}
+}
+
+func TestClonePolicy(t *testing.T) {
+	newRepo := func(t *testing.T, policy ClonePolicy[*TestUser]) (*mockRepository[*TestUser], *CachedRepository[*TestUser]) {
+		t.Helper()
+		baseRepo := &mockRepository[*TestUser]{
+			getByIDResult: &TestUser{ID: "user-1", Name: "Original"},
+			listRecords:   []*TestUser{{ID: "user-1", Name: "Original"}},
+			listTotal:     1,
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := New[*TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+		cached.SetClonePolicy(policy)
+		return baseRepo, cached
+	}
+	ctx := context.Background()
+
+	t.Run("SharedByDefault", func(t *testing.T) {
+		_, cached := newRepo(t, ClonePolicy[*TestUser]{})
+		first, _ := cached.GetByID(ctx, "user-1")
+		first.Name = "Mutated"
+		second, _ := cached.GetByID(ctx, "user-1")
+		if second.Name != "Mutated" {
+			t.Fatalf("expected values to be shared without a policy, got %q", second.Name)
+		}
+	})
+
+	t.Run("CloneOnRead", func(t *testing.T) {
+		_, cached := newRepo(t, ClonePolicy[*TestUser]{Mode: CloneOnRead})
+		first, _ := cached.GetByID(ctx, "user-1")
+		first.Name = "Mutated"
+		second, _ := cached.GetByID(ctx, "user-1")
+		second.Name = "Mutated again"
+		third, err := cached.GetByID(ctx, "user-1")
+		if err != nil || third.Name != "Original" {
+			t.Fatalf("expected every reader to get a copy, got %+v (%v)", third, err)
+		}
+
+		records, _, _ := cached.List(ctx)
+		records[0].Name = "Mutated"
+		records[0] = nil
+		records, _, _ = cached.List(ctx)
+		if records[0] == nil || records[0].Name != "Original" {
+			t.Fatalf("expected list records to be copied, got %+v", records)
+		}
+
+		byID, _ := cached.GetByIDs(ctx, []string{"user-1"})
+		byID["user-1"].Name = "Mutated"
+		if again, _ := cached.GetByID(ctx, "user-1"); again.Name != "Original" {
+			t.Fatalf("expected GetByIDs to return copies, got %q", again.Name)
+		}
+	})
+
+	t.Run("CloneOnWrite", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, ClonePolicy[*TestUser]{Mode: CloneOnWrite})
+		fetched, _ := cached.GetByID(ctx, "user-1")
+		if fetched != baseRepo.getByIDResult {
+			t.Fatal("expected the filling caller to keep the fetched value")
+		}
+		fetched.Name = "Mutated"
+		hit, _ := cached.GetByID(ctx, "user-1")
+		if hit.Name != "Original" {
+			t.Fatalf("expected the cache to hold its own copy, got %q", hit.Name)
+		}
+	})
+
+	t.Run("DetectMutations", func(t *testing.T) {
+		var reported []string
+		_, cached := newRepo(t, ClonePolicy[*TestUser]{
+			DetectMutations: true,
+			OnMutation:      func(key string) { reported = append(reported, key) },
+		})
+		first, _ := cached.GetByID(ctx, "user-1")
+		if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if len(reported) != 0 {
+			t.Fatalf("expected no report before mutation, got %v", reported)
+		}
+
+		first.Name = "Mutated"
+		cached.GetByID(ctx, "user-1")
+		cached.GetByID(ctx, "user-1")
+		if len(reported) != 1 {
+			t.Fatalf("expected the mutation to be reported once, got %v", reported)
+		}
+	})
+
+	t.Run("SnapshotsFollowEntries", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, ClonePolicy[*TestUser]{DetectMutations: true, OnMutation: func(string) {}})
+		policy := cached.clonePolicy.Load()
+		baseRepo.updateResult = &TestUser{ID: "user-1", Name: "Updated"}
+
+		cached.GetByID(ctx, "user-1")
+		cached.List(ctx)
+		if got := policy.snapshots.size(); got != 2 {
+			t.Fatalf("expected a snapshot per entry, got %d", got)
+		}
+		if _, err := cached.Update(ctx, baseRepo.updateResult); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got := policy.snapshots.size(); got != 0 {
+			t.Fatalf("expected invalidated entries to drop their snapshots, got %d", got)
+		}
+
+		now := time.Now()
+		policy.snapshots.now = func() time.Time { return now }
+		cached.GetByID(ctx, "user-1")
+		now = now.Add(cached.snapshotRetention())
+		cached.List(ctx)
+		if got := policy.snapshots.size(); got != 1 {
+			t.Fatalf("expected expired snapshots to be swept, got %d", got)
+		}
+	})
+
+	t.Run("ReportsMutationsWithoutHook", func(t *testing.T) {
+		var logged bytes.Buffer
+		log.SetOutput(&logged)
+		defer log.SetOutput(os.Stderr)
+
+		_, cached := newRepo(t, ClonePolicy[*TestUser]{DetectMutations: true})
+		first, _ := cached.GetByID(ctx, "user-1")
+		first.Name = "Mutated"
+		if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if !strings.Contains(logged.String(), "was mutated") {
+			t.Fatalf("expected the mutation to be logged, got %q", logged.String())
+		}
+	})
+
+	t.Run("ClonerErrors", func(t *testing.T) {
+		cloneErr := errors.New("clone failed")
+		_, cached := newRepo(t, ClonePolicy[*TestUser]{
+			Mode:   CloneOnRead,
+			Cloner: func(*TestUser) (*TestUser, error) { return nil, cloneErr },
+		})
+		if _, err := cached.GetByID(ctx, "user-1"); !errors.Is(err, cloneErr) {
+			t.Fatalf("expected clone error, got %v", err)
+		}
+	})
+}
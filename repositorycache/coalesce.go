@@ -0,0 +1,144 @@
+package repositorycache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CoalescingConfig configures automatic coalescing of GetByID cache misses.
+type CoalescingConfig struct {
+	// Window is how long the first miss of a batch waits for more IDs before the
+	// batch is fetched. A zero or negative window disables coalescing.
+	Window time.Duration
+	// MaxBatch fetches a batch as soon as it holds this many IDs, before the
+	// window elapses. Zero means no limit.
+	MaxBatch int
+	// Timeout bounds each batch fetch. Zero uses DefaultCoalescingTimeout.
+	Timeout time.Duration
+}
+
+// DefaultCoalescingTimeout is the default bound on a coalesced batch fetch.
+const DefaultCoalescingTimeout = 10 * time.Second
+
+// SetGetByIDCoalescing enables or disables coalescing of GetByID misses.
+//
+// When enabled, concurrent GetByID misses without criteria that arrive within the
+// window are loaded together with a single List filtered with IN, like GetByIDs.
+// Misses are grouped by scope signature, so only reads made under the same scopes
+// share a batch. Coalescing happens inside the per-key fetch, so concurrent misses
+// on one ID are still collapsed into a single request before they are batched.
+//
+// Each caller gets its own result: a failed List fails every ID of the batch, and
+// an ID the List did not return is loaded with GetByID so the caller receives the
+// base repository's not found error. Batches are fetched with the context of the
+// first miss, detached from its cancellation but keeping its deadline, and bounded
+// by the timeout; every caller still stops waiting when its own context is done.
+func (c *CachedRepository[T]) SetGetByIDCoalescing(cfg CoalescingConfig) {
+	if cfg.Window <= 0 {
+		c.coalescer.Store(nil)
+		return
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultCoalescingTimeout
+	}
+	c.coalescer.Store(&coalescer[T]{
+		window:   cfg.Window,
+		maxBatch: cfg.MaxBatch,
+		timeout:  cfg.Timeout,
+		fetch:    c.listByIDs,
+		pending:  make(map[string]*coalesceBatch[T]),
+	})
+}
+
+// GetByIDCoalescing reports the active coalescing configuration.
+func (c *CachedRepository[T]) GetByIDCoalescing() CoalescingConfig {
+	co := c.coalescer.Load()
+	if co == nil {
+		return CoalescingConfig{}
+	}
+	return CoalescingConfig{Window: co.window, MaxBatch: co.maxBatch, Timeout: co.timeout}
+}
+
+// coalescer collects IDs into per-group batches and fetches each batch once.
+type coalescer[T any] struct {
+	window   time.Duration
+	maxBatch int
+	timeout  time.Duration
+	fetch    func(ctx context.Context, ids []string) (map[string]T, error)
+
+	mu      sync.Mutex
+	pending map[string]*coalesceBatch[T]
+}
+
+type coalesceBatch[T any] struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ids     []string
+	seen    map[string]struct{}
+	timer   *time.Timer
+	done    chan struct{}
+	records map[string]T
+	err     error
+}
+
+// load adds id to the pending batch of group and waits for the batch result.
+// found is false when the batch succeeded without returning id.
+func (co *coalescer[T]) load(ctx context.Context, group, id string) (record T, found bool, err error) {
+	co.mu.Lock()
+	batch := co.pending[group]
+	if batch == nil {
+		batch = &coalesceBatch[T]{
+			ctx:    context.WithoutCancel(ctx),
+			cancel: func() {},
+			seen:   make(map[string]struct{}),
+			done:   make(chan struct{}),
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			batch.ctx, batch.cancel = context.WithDeadline(batch.ctx, deadline)
+		}
+		co.pending[group] = batch
+		batch.timer = time.AfterFunc(co.window, func() {
+			co.dispatch(group, batch)
+		})
+	}
+	if _, ok := batch.seen[id]; !ok {
+		batch.seen[id] = struct{}{}
+		batch.ids = append(batch.ids, id)
+	}
+	full := co.maxBatch > 0 && len(batch.ids) >= co.maxBatch
+	co.mu.Unlock()
+
+	if full {
+		go co.dispatch(group, batch)
+	}
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return record, false, ctx.Err()
+	}
+	if batch.err != nil {
+		return record, false, batch.err
+	}
+	record, found = batch.records[id]
+	return record, found, nil
+}
+
+// dispatch fetches batch unless the timer or a full batch already did.
+func (co *coalescer[T]) dispatch(group string, batch *coalesceBatch[T]) {
+	co.mu.Lock()
+	if co.pending[group] != batch {
+		co.mu.Unlock()
+		return
+	}
+	delete(co.pending, group)
+	co.mu.Unlock()
+
+	batch.timer.Stop()
+	ctx, cancel := context.WithTimeout(batch.ctx, co.timeout)
+	batch.records, batch.err = co.fetch(ctx, batch.ids)
+	cancel()
+	batch.cancel()
+	close(batch.done)
+}
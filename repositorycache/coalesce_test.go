@@ -0,0 +1,229 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+func newCoalescingRepository(t *testing.T, baseRepo *mockRepository[TestUser], cfg CoalescingConfig) *CachedRepository[TestUser] {
+	t.Helper()
+	cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+	cached.SetGetByIDCoalescing(cfg)
+	return cached
+}
+
+func countCalls(calls []string, method string) int {
+	total := 0
+	for _, call := range calls {
+		if call == method {
+			total++
+		}
+	}
+	return total
+}
+
+func TestGetByIDCoalescing_MergesConcurrentMisses(t *testing.T) {
+	notFound := errors.New("record not found")
+	baseRepo := &mockRepository[TestUser]{getByIDError: notFound}
+	ids := make([]string, 10)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user-%d", i)
+		baseRepo.listRecords = append(baseRepo.listRecords, TestUser{ID: ids[i], Name: fmt.Sprintf("User %d", i)})
+	}
+	ids = append(ids, "user-missing")
+	cached := newCoalescingRepository(t, baseRepo, CoalescingConfig{Window: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	results := make([]TestUser, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			results[i], errs[i] = cached.GetByID(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	for i, id := range ids[:10] {
+		if errs[i] != nil || results[i].ID != id {
+			t.Fatalf("expected %s, got %+v (%v)", id, results[i], errs[i])
+		}
+	}
+	if !errors.Is(errs[10], notFound) {
+		t.Fatalf("expected per-item not found error for the missing id, got %v", errs[10])
+	}
+
+	calls := baseRepo.getCalls()
+	if countCalls(calls, "List") != 1 || countCalls(calls, "GetByID") != 1 {
+		t.Fatalf("expected one List for the batch and one GetByID for the missing id, got %v", calls)
+	}
+
+	baseRepo.clearCalls()
+	if _, err := cached.GetByID(ctx, "user-3"); err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if calls := baseRepo.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected coalesced records to be cached per key, got %v", calls)
+	}
+}
+
+func TestGetByIDCoalescing_MaxBatchDispatchesEarly(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{listRecords: []TestUser{{ID: "user-1"}, {ID: "user-2"}}}
+	cached := newCoalescingRepository(t, baseRepo, CoalescingConfig{Window: time.Hour, MaxBatch: 2})
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"user-1", "user-2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if _, err := cached.GetByID(context.Background(), id); err != nil {
+				t.Errorf("GetByID(%s) failed: %v", id, err)
+			}
+		}(id)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a full batch to be fetched before the window elapsed")
+	}
+	if calls := baseRepo.getCalls(); countCalls(calls, "List") != 1 {
+		t.Fatalf("expected one List, got %v", calls)
+	}
+}
+
+func TestGetByIDCoalescing_BatchesPastDefaultPagination(t *testing.T) {
+	baseRepo, ids := newPagedListRepository(40, 25)
+	cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+	cached.SetGetByIDCoalescing(CoalescingConfig{Window: time.Hour, MaxBatch: len(ids)})
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if _, err := cached.GetByID(context.Background(), id); err != nil {
+				t.Errorf("GetByID(%s) failed: %v", id, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	calls := baseRepo.getCalls()
+	if countCalls(calls, "List") != 1 || countCalls(calls, "GetByID") != 0 {
+		t.Fatalf("expected one List and no per-ID fallback, got %v", calls)
+	}
+}
+
+// blockingListRepository blocks List until its context is done.
+type blockingListRepository struct {
+	*mockRepository[TestUser]
+}
+
+func (r *blockingListRepository) List(ctx context.Context, criteria ...repository.SelectCriteria) ([]TestUser, int, error) {
+	r.recordCall("List")
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestGetByIDCoalescing_Errors(t *testing.T) {
+	t.Run("StuckBatchTimesOut", func(t *testing.T) {
+		baseRepo := &blockingListRepository{mockRepository: &mockRepository[TestUser]{}}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+		cached.SetGetByIDCoalescing(CoalescingConfig{Window: time.Millisecond, Timeout: 20 * time.Millisecond})
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := cached.GetByID(context.Background(), "user-1")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the batch fetch to time out")
+		}
+	})
+
+	t.Run("BatchErrorReachesEveryCaller", func(t *testing.T) {
+		listErr := errors.New("list failed")
+		baseRepo := &mockRepository[TestUser]{listError: listErr}
+		cached := newCoalescingRepository(t, baseRepo, CoalescingConfig{Window: 10 * time.Millisecond})
+
+		errs := make([]error, 3)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = cached.GetByID(context.Background(), fmt.Sprintf("user-%d", i))
+			}(i)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if !errors.Is(err, listErr) {
+				t.Fatalf("caller %d: expected batch error, got %v", i, err)
+			}
+		}
+	})
+
+	t.Run("CanceledCallerStopsWaiting", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{listRecords: []TestUser{{ID: "user-1"}}}
+		cached := newCoalescingRepository(t, baseRepo, CoalescingConfig{Window: 50 * time.Millisecond})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := cached.GetByID(ctx, "user-1"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		user, err := cached.GetByID(context.Background(), "user-1")
+		if err != nil || user.ID != "user-1" {
+			t.Fatalf("expected later caller to be served, got %+v (%v)", user, err)
+		}
+	})
+}
+
+func TestGetByIDCoalescing_Disable(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{getByIDResult: TestUser{ID: "user-1"}}
+	cached := newCoalescingRepository(t, baseRepo, CoalescingConfig{Window: time.Millisecond, MaxBatch: 5})
+	if cfg := cached.GetByIDCoalescing(); cfg.Window != time.Millisecond || cfg.MaxBatch != 5 || cfg.Timeout != DefaultCoalescingTimeout {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	cached.SetGetByIDCoalescing(CoalescingConfig{})
+	if _, err := cached.GetByID(context.Background(), "user-1"); err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if calls := baseRepo.getCalls(); len(calls) != 1 || calls[0] != "GetByID" {
+		t.Fatalf("expected disabled coalescing to call GetByID, got %v", calls)
+	}
+}
@@ -2,10 +2,13 @@ package repositorycache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	repository "github.com/goliatone/go-repository-bun"
 	"github.com/goliatone/go-repository-cache/cache"
@@ -28,8 +31,27 @@ type CachedRepository[T any] struct {
 	keySerializer   cache.KeySerializer
 	namespace       string
 	identifiers     []string
+	identifierIndex identifierIndex
 	scopeDefaults   repository.ScopeDefaults
 	scopeDefaultsMu sync.RWMutex
+	// scopeInvalidation holds a ScopeInvalidationMode.
+	scopeInvalidation atomic.Int32
+	// coalescer batches GetByID misses when coalescing is enabled.
+	coalescer            atomic.Pointer[coalescer[T]]
+	writeThrough         atomic.Bool
+	normalizedLists      atomic.Bool
+	clonePolicy          atomic.Pointer[clonePolicy[T]]
+	ttlPolicy            atomic.Pointer[TTLPolicy]
+	negativeCaching      atomic.Pointer[NegativeCaching]
+	staleIfError         atomic.Pointer[StaleIfError]
+	staleServed          atomic.Uint64
+	metrics              atomic.Pointer[cache.Metrics]
+	observers            atomic.Pointer[[]Observer]
+	invalidationFailures atomic.Pointer[InvalidationFailurePolicy]
+	bus                  atomic.Pointer[invalidationBus]
+	enabledMethods       map[ReadMethod]bool
+	disabledMethods      map[ReadMethod]bool
+	tagDeriver           TagDeriver[T]
 }
 
 func (c *CachedRepository[T]) setScopeDefaults(defaults repository.ScopeDefaults) {
@@ -51,18 +73,19 @@ func (c *CachedRepository[T]) scopeSignature(ctx context.Context, op repository.
 
 // New creates a new CachedRepository that wraps the base repository with caching
 func New[T any](base repository.Repository[T], cacheService cache.CacheService, keySerializer cache.KeySerializer) *CachedRepository[T] {
-	return newCachedRepository(base, cacheService, keySerializer, nil)
+	return NewWithOptions(base, cacheService, keySerializer)
 }
 
 // NewWithIdentifierFields creates a CachedRepository with custom identifier field names.
 // Field names must match the struct field names returned by the repository handlers.
 func NewWithIdentifierFields[T any](base repository.Repository[T], cacheService cache.CacheService, keySerializer cache.KeySerializer, identifierFields ...string) *CachedRepository[T] {
-	return newCachedRepository(base, cacheService, keySerializer, identifierFields)
+	return NewWithOptions(base, cacheService, keySerializer, WithIdentifierFields(identifierFields...))
 }
 
-// Get caches value-only reads and passes criteria-bearing reads through to the base repository.
+// Get caches value-only reads and reads named with WithCacheKey; other criteria-bearing reads pass through to the base repository.
 func (c *CachedRepository[T]) Get(ctx context.Context, criteria ...repository.SelectCriteria) (T, error) {
-	if len(criteria) > 0 {
+	named, hasName := takeCacheKey(ctx)
+	if c.bypassed(ctx, "Get", MethodGet, len(criteria) > 0 && !hasName) {
 		return c.base.Get(ctx, criteria...)
 	}
 	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
@@ -71,43 +94,48 @@ func (c *CachedRepository[T]) Get(ctx context.Context, criteria ...repository.Se
 		args = append(args, signature)
 	}
 	key := c.key("Get", args...)
-	result, err := cache.GetOrFetch(ctx, c.cache, key, func(ctx context.Context) (T, error) {
-		return c.base.Get(ctx)
-	})
-	if err == nil {
-		tags := []string{c.scopeTag(signature)}
-		c.registerTags(ctx, key, tags)
+	tags := []string{c.listTag(), c.scopeTag(signature)}
+	if hasName {
+		key, tags = c.namedKey("Get", signature, named, tags)
 	}
-	return result, err
+	return fetchThrough(ctx, c, MethodGet, key, tags, func(ctx context.Context) (T, error) {
+		return c.base.Get(ctx, criteria...)
+	})
 }
 
-// GetByID caches value-only reads and passes criteria-bearing reads through to the base repository.
+// GetByID caches value-only reads and reads named with WithCacheKey; other criteria-bearing reads pass through to the base repository.
 func (c *CachedRepository[T]) GetByID(ctx context.Context, id string, criteria ...repository.SelectCriteria) (T, error) {
-	if len(criteria) > 0 {
+	named, hasName := takeCacheKey(ctx)
+	if c.bypassed(ctx, "GetByID", MethodGetByID, len(criteria) > 0 && !hasName) {
 		return c.base.GetByID(ctx, id, criteria...)
 	}
 	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
-	args := []any{id}
-	if !signature.IsZero() {
-		args = append(args, signature)
+	key := c.recordKey("GetByID", id, signature)
+	tags := []string{c.scopeTag(signature)}
+	if tag, ok := c.idTag(id); ok {
+		tags = appendTag(tags, tag)
 	}
-	key := c.key("GetByID", args...)
-	result, err := cache.GetOrFetch(ctx, c.cache, key, func(ctx context.Context) (T, error) {
-		return c.base.GetByID(ctx, id)
-	})
-	if err == nil {
-		tags := []string{c.scopeTag(signature)}
-		if tag, ok := c.idTag(id); ok {
-			tags = appendTag(tags, tag)
+	if hasName {
+		key, tags = c.namedKey("GetByID", signature, named, tags, id)
+	}
+	result, err := fetchThrough(ctx, c, MethodGetByID, key, tags, func(ctx context.Context) (T, error) {
+		if co := c.coalescer.Load(); co != nil && !hasName {
+			return c.coalescedGetByID(ctx, co, signature, id)
 		}
-		c.registerTags(ctx, key, tags)
+		return c.base.GetByID(ctx, id, criteria...)
+	})
+	if errors.Is(err, cache.ErrMissingRecord) {
+		// GetByIDs marked the ID as missing; let the base repository report
+		// its own not found error.
+		return c.base.GetByID(ctx, id, criteria...)
 	}
 	return result, err
 }
 
-// List caches value-only reads and passes criteria-bearing reads through to the base repository.
+// List caches value-only reads and reads named with WithCacheKey; other criteria-bearing reads pass through to the base repository.
 func (c *CachedRepository[T]) List(ctx context.Context, criteria ...repository.SelectCriteria) ([]T, int, error) {
-	if len(criteria) > 0 {
+	named, hasName := takeCacheKey(ctx)
+	if c.bypassed(ctx, "List", MethodList, len(criteria) > 0 && !hasName) {
 		return c.base.List(ctx, criteria...)
 	}
 	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
@@ -115,22 +143,36 @@ func (c *CachedRepository[T]) List(ctx context.Context, criteria ...repository.S
 	if !signature.IsZero() {
 		args = append(args, signature)
 	}
+	if c.NormalizedLists() {
+		key := c.key("List", append([]any{normalizedListArg}, args...)...)
+		tags := c.membershipTags(signature)
+		if hasName {
+			key, _ = c.namedKey("List", signature, named, nil, normalizedListArg)
+			tags = appendTags(tags, named.tags)
+		}
+		return c.normalizedList(ctx, signature, key, tags, func(ctx context.Context) ([]T, int, error) {
+			return c.base.List(ctx, criteria...)
+		})
+	}
 	key := c.key("List", args...)
-	res, err := cache.GetOrFetch(ctx, c.cache, key, func(ctx context.Context) (listResult[T], error) {
-		records, total, err := c.base.List(ctx)
+	tags := []string{c.listTag(), c.scopeTag(signature)}
+	if hasName {
+		key, tags = c.namedKey("List", signature, named, tags)
+	}
+	res, err := fetchThrough(ctx, c, MethodList, key, tags, func(ctx context.Context) (listResult[T], error) {
+		records, total, err := c.base.List(ctx, criteria...)
 		return listResult[T]{Records: records, Total: total}, err
 	})
 	if err != nil {
 		return nil, 0, err
 	}
-	tags := []string{c.listTag(), c.scopeTag(signature)}
-	c.registerTags(ctx, key, tags)
 	return res.Records, res.Total, nil
 }
 
-// Count caches value-only reads and passes criteria-bearing reads through to the base repository.
+// Count caches value-only reads and reads named with WithCacheKey; other criteria-bearing reads pass through to the base repository.
 func (c *CachedRepository[T]) Count(ctx context.Context, criteria ...repository.SelectCriteria) (int, error) {
-	if len(criteria) > 0 {
+	named, hasName := takeCacheKey(ctx)
+	if c.bypassed(ctx, "Count", MethodCount, len(criteria) > 0 && !hasName) {
 		return c.base.Count(ctx, criteria...)
 	}
 	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
@@ -139,45 +181,298 @@ func (c *CachedRepository[T]) Count(ctx context.Context, criteria ...repository.
 		args = append(args, signature)
 	}
 	key := c.key("Count", args...)
-	result, err := cache.GetOrFetch(ctx, c.cache, key, func(ctx context.Context) (int, error) {
-		return c.base.Count(ctx)
+	tags := []string{c.listTag(), c.scopeTag(signature)}
+	if hasName {
+		key, tags = c.namedKey("Count", signature, named, tags)
+	}
+	return fetchThrough(ctx, c, MethodCount, key, tags, func(ctx context.Context) (int, error) {
+		return c.base.Count(ctx, criteria...)
 	})
+}
+
+// GetByIdentifier caches value-only reads and reads named with WithCacheKey; other criteria-bearing reads pass through to the base repository.
+func (c *CachedRepository[T]) GetByIdentifier(ctx context.Context, identifier string, criteria ...repository.SelectCriteria) (T, error) {
+	named, hasName := takeCacheKey(ctx)
+	if c.bypassed(ctx, "GetByIdentifier", MethodGetByIdentifier, len(criteria) > 0 && !hasName) {
+		return c.base.GetByIdentifier(ctx, identifier, criteria...)
+	}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	key := c.recordKey("GetByIdentifier", identifier, signature)
+	tags := []string{c.scopeTag(signature)}
+	if tag, ok := c.identifierTag(identifier); ok {
+		tags = appendTag(tags, tag)
+	}
+	if hasName {
+		key, tags = c.namedKey("GetByIdentifier", signature, named, tags, identifier)
+	}
+	result, err := fetchThrough(ctx, c, MethodGetByIdentifier, key, tags, c.tagRecordID(key, func(ctx context.Context) (T, error) {
+		return c.base.GetByIdentifier(ctx, identifier, criteria...)
+	}))
 	if err == nil {
-		tags := []string{c.listTag(), c.scopeTag(signature)}
-		c.registerTags(ctx, key, tags)
+		if id, idErr := c.extractID(result); idErr == nil {
+			c.identifierIndex.remember(id, identifier, c.identifierRetention())
+		}
 	}
 	return result, err
 }
 
-// GetByIdentifier caches value-only reads and passes criteria-bearing reads through to the base repository.
-func (c *CachedRepository[T]) GetByIdentifier(ctx context.Context, identifier string, criteria ...repository.SelectCriteria) (T, error) {
-	if len(criteria) > 0 {
-		return c.base.GetByIdentifier(ctx, identifier, criteria...)
+// GetByIDs returns the records of ids keyed by ID. Each record is cached under the
+// same key and tags as GetByID, and only the IDs missing from the cache reach the
+// base repository, as a single List filtered with IN. IDs without a record are
+// absent from the result.
+func (c *CachedRepository[T]) GetByIDs(ctx context.Context, ids []string) (map[string]T, error) {
+	ids = dedupeStrings(ids)
+	if len(ids) == 0 {
+		return map[string]T{}, nil
+	}
+	if c.bypassed(ctx, "GetByIDs", MethodGetByID, false) {
+		return c.listByIDs(ctx, ids)
 	}
 	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
-	args := []any{identifier}
+	return c.getByIDs(ctx, signature, ids)
+}
+
+// getByIDs resolves ids through their GetByID entries under signature.
+func (c *CachedRepository[T]) getByIDs(ctx context.Context, signature repository.ScopeState, ids []string) (map[string]T, error) {
+	keyFn := func(id string) string {
+		return c.recordKey("GetByID", id, signature)
+	}
+	listByIDs, measured := measureBatch(c, MethodGetByID, len(ids), c.listByIDs)
+	listByIDs, observed := observeBatch(ctx, c, MethodGetByID, ids, keyFn, listByIDs)
+	records, err := c.fetchByIDs(ctx, signature, ids, listByIDs)
+	measured(err)
+	observed(err)
+	return records, err
+}
+
+// fetchByIDs reads ids through the cache, loading the missing ones with
+// listByIDs.
+func (c *CachedRepository[T]) fetchByIDs(ctx context.Context, signature repository.ScopeState, ids []string, listByIDs cache.BatchFetchFn[T]) (map[string]T, error) {
+	keyFn := func(id string) string {
+		return c.recordKey("GetByID", id, signature)
+	}
+	ttl := c.methodTTL(MethodGetByID)
+	optsFn := func(id string) cache.EntryOptions {
+		tags := []string{c.scopeTag(signature)}
+		if tag, ok := c.idTag(id); ok {
+			tags = appendTag(tags, tag)
+		}
+		return cache.EntryOptions{Tags: c.readTags(ctx, tags), TTL: ttl}
+	}
+	policy := c.clonePolicy.Load()
+	if policy == nil && c.tagDeriver == nil {
+		return cache.GetOrFetchBatch(ctx, c.cache, ids, keyFn, optsFn, listByIDs)
+	}
+
+	var mu sync.Mutex
+	fetched := make(map[string]T)
+	records, err := cache.GetOrFetchBatch(ctx, c.cache, ids, keyFn, optsFn, func(ctx context.Context, missing []string) (map[string]T, error) {
+		records, err := listByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		stored := make(map[string]T, len(records))
+		mu.Lock()
+		defer mu.Unlock()
+		for id, record := range records {
+			if derived := c.derivedTags(ctx, record); len(derived) > 0 {
+				c.registerTags(ctx, keyFn(id), derived)
+			}
+			value, err := policy.stored(keyFn(id), record, optsFn(id).Tags)
+			if err != nil {
+				return nil, err
+			}
+			fetched[id] = record
+			stored[id] = value.(T)
+		}
+		return stored, nil
+	})
+	if err != nil {
+		return records, err
+	}
+
+	if policy == nil {
+		return records, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for id, record := range records {
+		if original, ok := fetched[id]; ok && policy.onWrite() {
+			records[id] = original
+			continue
+		}
+		value, err := policy.read(keyFn(id), record)
+		if err != nil {
+			return nil, err
+		}
+		records[id] = value.(T)
+	}
+	return records, nil
+}
+
+// recordKey returns the key GetByID or GetByIdentifier cache value under for the
+// given scope signature.
+func (c *CachedRepository[T]) recordKey(method, value string, signature repository.ScopeState) string {
+	args := []any{value}
 	if !signature.IsZero() {
 		args = append(args, signature)
 	}
-	key := c.key("GetByIdentifier", args...)
-	result, err := cache.GetOrFetch(ctx, c.cache, key, func(ctx context.Context) (T, error) {
-		return c.base.GetByIdentifier(ctx, identifier)
+	return c.key(method, args...)
+}
+
+// listByIDs loads the records of ids with a single List filtered with IN. The
+// limit covers every ID, overriding the base repository's default pagination.
+func (c *CachedRepository[T]) listByIDs(ctx context.Context, ids []string) (map[string]T, error) {
+	values := make([]any, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	query := Query{Where: []Condition{In("id", values...)}, Limit: len(ids)}
+	records, _, err := c.base.List(ctx, query.Criteria()...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]T, len(records))
+	for _, record := range records {
+		if id, idErr := c.extractID(record); idErr == nil {
+			result[id] = record
+		}
+	}
+	return result, nil
+}
+
+// coalescedGetByID loads id through the coalescer, batching it with concurrent
+// misses made under the same scope signature.
+func (c *CachedRepository[T]) coalescedGetByID(ctx context.Context, co *coalescer[T], signature repository.ScopeState, id string) (T, error) {
+	group := ""
+	if !signature.IsZero() {
+		group = c.key("GetByIDs", signature)
+	}
+	record, found, err := co.load(ctx, group, id)
+	if err != nil || found {
+		return record, err
+	}
+	// The batch did not return id; GetByID reports why.
+	return c.base.GetByID(ctx, id)
+}
+
+// GetQuery caches a Get filtered by query. Invalid queries return ErrInvalidQuery.
+func (c *CachedRepository[T]) GetQuery(ctx context.Context, query Query) (T, error) {
+	if err := query.Validate(); err != nil {
+		var zero T
+		return zero, err
+	}
+	if c.bypassed(ctx, "GetQuery", MethodGet, false) {
+		return c.base.Get(ctx, query.Criteria()...)
+	}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	key := c.queryCacheKey("Get", signature, query)
+	tags := []string{c.listTag(), c.scopeTag(signature)}
+	criteria := query.Criteria()
+	return fetchThrough(ctx, c, MethodGet, key, tags, func(ctx context.Context) (T, error) {
+		return c.base.Get(ctx, criteria...)
 	})
+}
+
+// GetByIDQuery caches a GetByID filtered by query. Invalid queries return ErrInvalidQuery.
+func (c *CachedRepository[T]) GetByIDQuery(ctx context.Context, id string, query Query) (T, error) {
+	if err := query.Validate(); err != nil {
+		var zero T
+		return zero, err
+	}
+	if c.bypassed(ctx, "GetByIDQuery", MethodGetByID, false) {
+		return c.base.GetByID(ctx, id, query.Criteria()...)
+	}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	key := c.queryCacheKey("GetByID", signature, query, id)
+	tags := []string{c.scopeTag(signature)}
+	if tag, ok := c.idTag(id); ok {
+		tags = appendTag(tags, tag)
+	}
+	criteria := query.Criteria()
+	return fetchThrough(ctx, c, MethodGetByID, key, tags, func(ctx context.Context) (T, error) {
+		return c.base.GetByID(ctx, id, criteria...)
+	})
+}
+
+// GetByIdentifierQuery caches a GetByIdentifier filtered by query. Invalid queries return ErrInvalidQuery.
+func (c *CachedRepository[T]) GetByIdentifierQuery(ctx context.Context, identifier string, query Query) (T, error) {
+	if err := query.Validate(); err != nil {
+		var zero T
+		return zero, err
+	}
+	if c.bypassed(ctx, "GetByIdentifierQuery", MethodGetByIdentifier, false) {
+		return c.base.GetByIdentifier(ctx, identifier, query.Criteria()...)
+	}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	key := c.queryCacheKey("GetByIdentifier", signature, query, identifier)
+	tags := []string{c.scopeTag(signature)}
+	if tag, ok := c.identifierTag(identifier); ok {
+		tags = appendTag(tags, tag)
+	}
+	criteria := query.Criteria()
+	result, err := fetchThrough(ctx, c, MethodGetByIdentifier, key, tags, c.tagRecordID(key, func(ctx context.Context) (T, error) {
+		return c.base.GetByIdentifier(ctx, identifier, criteria...)
+	}))
 	if err == nil {
-		tags := []string{c.scopeTag(signature)}
-		if tag, ok := c.identifierTag(identifier); ok {
-			tags = appendTag(tags, tag)
+		if id, idErr := c.extractID(result); idErr == nil {
+			c.identifierIndex.remember(id, identifier, c.identifierRetention())
 		}
-		c.registerTags(ctx, key, tags)
 	}
 	return result, err
 }
 
+// ListQuery caches a List filtered by query. Invalid queries return ErrInvalidQuery.
+func (c *CachedRepository[T]) ListQuery(ctx context.Context, query Query) ([]T, int, error) {
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
+	if c.bypassed(ctx, "ListQuery", MethodList, false) {
+		return c.base.List(ctx, query.Criteria()...)
+	}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	criteria := query.Criteria()
+	if c.NormalizedLists() {
+		key := c.queryCacheKey("List", signature, query, normalizedListArg)
+		return c.normalizedList(ctx, signature, key, c.membershipTags(signature), func(ctx context.Context) ([]T, int, error) {
+			return c.base.List(ctx, criteria...)
+		})
+	}
+	key := c.queryCacheKey("List", signature, query)
+	tags := []string{c.listTag(), c.scopeTag(signature)}
+	res, err := fetchThrough(ctx, c, MethodList, key, tags, func(ctx context.Context) (listResult[T], error) {
+		records, total, err := c.base.List(ctx, criteria...)
+		return listResult[T]{Records: records, Total: total}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return res.Records, res.Total, nil
+}
+
+// CountQuery caches a Count filtered by query. Invalid queries return ErrInvalidQuery.
+func (c *CachedRepository[T]) CountQuery(ctx context.Context, query Query) (int, error) {
+	if err := query.Validate(); err != nil {
+		return 0, err
+	}
+	if c.bypassed(ctx, "CountQuery", MethodCount, false) {
+		return c.base.Count(ctx, query.Criteria()...)
+	}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	key := c.queryCacheKey("Count", signature, query)
+	tags := []string{c.listTag(), c.scopeTag(signature)}
+	criteria := query.Criteria()
+	return fetchThrough(ctx, c, MethodCount, key, tags, func(ctx context.Context) (int, error) {
+		return c.base.Count(ctx, criteria...)
+	})
+}
+
 // Create creates a new record. Write operations pass through to base repository
 func (c *CachedRepository[T]) Create(ctx context.Context, record T, criteria ...repository.InsertCriteria) (T, error) {
 	result, err := c.base.Create(ctx, record, criteria...)
 	if err == nil {
-		c.invalidateAfterCreate(ctx, result)
+		err = c.invalidateAfterCreate(ctx, "Create", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "Create", result))
 	}
 	return result, err
 }
@@ -186,7 +481,9 @@ func (c *CachedRepository[T]) Create(ctx context.Context, record T, criteria ...
 func (c *CachedRepository[T]) CreateTx(ctx context.Context, tx bun.IDB, record T, criteria ...repository.InsertCriteria) (T, error) {
 	result, err := c.base.CreateTx(ctx, tx, record, criteria...)
 	if err == nil {
-		c.invalidateAfterCreate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "CreateTx", func() error {
+			return c.invalidateAfterCreate(ctx, "CreateTx", result)
+		}, result)
 	}
 	return result, err
 }
@@ -195,7 +492,8 @@ func (c *CachedRepository[T]) CreateTx(ctx context.Context, tx bun.IDB, record T
 func (c *CachedRepository[T]) CreateMany(ctx context.Context, records []T, criteria ...repository.InsertCriteria) ([]T, error) {
 	result, err := c.base.CreateMany(ctx, records, criteria...)
 	if err == nil {
-		c.invalidateAfterBulkCreate(ctx, result)
+		err = c.invalidateAfterBulkCreate(ctx, "CreateMany", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "CreateMany", result...))
 	}
 	return result, err
 }
@@ -204,7 +502,9 @@ func (c *CachedRepository[T]) CreateMany(ctx context.Context, records []T, crite
 func (c *CachedRepository[T]) CreateManyTx(ctx context.Context, tx bun.IDB, records []T, criteria ...repository.InsertCriteria) ([]T, error) {
 	result, err := c.base.CreateManyTx(ctx, tx, records, criteria...)
 	if err == nil {
-		c.invalidateAfterBulkCreate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "CreateManyTx", func() error {
+			return c.invalidateAfterBulkCreate(ctx, "CreateManyTx", result)
+		}, result...)
 	}
 	return result, err
 }
@@ -214,7 +514,8 @@ func (c *CachedRepository[T]) GetOrCreate(ctx context.Context, record T) (T, err
 	result, err := c.base.GetOrCreate(ctx, record)
 	if err == nil {
 		// GetOrCreate may have created a new record, so invalidate create related caches
-		c.invalidateAfterCreate(ctx, result)
+		err = c.invalidateAfterCreate(ctx, "GetOrCreate", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "GetOrCreate", result))
 	}
 	return result, err
 }
@@ -223,7 +524,9 @@ func (c *CachedRepository[T]) GetOrCreate(ctx context.Context, record T) (T, err
 func (c *CachedRepository[T]) GetOrCreateTx(ctx context.Context, tx bun.IDB, record T) (T, error) {
 	result, err := c.base.GetOrCreateTx(ctx, tx, record)
 	if err == nil {
-		c.invalidateAfterCreate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "GetOrCreateTx", func() error {
+			return c.invalidateAfterCreate(ctx, "GetOrCreateTx", result)
+		}, result)
 	}
 	return result, err
 }
@@ -232,7 +535,8 @@ func (c *CachedRepository[T]) GetOrCreateTx(ctx context.Context, tx bun.IDB, rec
 func (c *CachedRepository[T]) Update(ctx context.Context, record T, criteria ...repository.UpdateCriteria) (T, error) {
 	result, err := c.base.Update(ctx, record, criteria...)
 	if err == nil {
-		c.invalidateAfterUpdate(ctx, result)
+		err = c.invalidateAfterUpdate(ctx, "Update", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "Update", result))
 	}
 	return result, err
 }
@@ -241,7 +545,9 @@ func (c *CachedRepository[T]) Update(ctx context.Context, record T, criteria ...
 func (c *CachedRepository[T]) UpdateTx(ctx context.Context, tx bun.IDB, record T, criteria ...repository.UpdateCriteria) (T, error) {
 	result, err := c.base.UpdateTx(ctx, tx, record, criteria...)
 	if err == nil {
-		c.invalidateAfterUpdate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "UpdateTx", func() error {
+			return c.invalidateAfterUpdate(ctx, "UpdateTx", result)
+		}, result)
 	}
 	return result, err
 }
@@ -250,7 +556,8 @@ func (c *CachedRepository[T]) UpdateTx(ctx context.Context, tx bun.IDB, record T
 func (c *CachedRepository[T]) UpdateMany(ctx context.Context, records []T, criteria ...repository.UpdateCriteria) ([]T, error) {
 	result, err := c.base.UpdateMany(ctx, records, criteria...)
 	if err == nil {
-		c.invalidateAfterBulkUpdate(ctx, result)
+		err = c.invalidateAfterBulkUpdate(ctx, "UpdateMany", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "UpdateMany", result...))
 	}
 	return result, err
 }
@@ -259,7 +566,9 @@ func (c *CachedRepository[T]) UpdateMany(ctx context.Context, records []T, crite
 func (c *CachedRepository[T]) UpdateManyTx(ctx context.Context, tx bun.IDB, records []T, criteria ...repository.UpdateCriteria) ([]T, error) {
 	result, err := c.base.UpdateManyTx(ctx, tx, records, criteria...)
 	if err == nil {
-		c.invalidateAfterBulkUpdate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "UpdateManyTx", func() error {
+			return c.invalidateAfterBulkUpdate(ctx, "UpdateManyTx", result)
+		}, result...)
 	}
 	return result, err
 }
@@ -268,8 +577,9 @@ func (c *CachedRepository[T]) UpdateManyTx(ctx context.Context, tx bun.IDB, reco
 func (c *CachedRepository[T]) Upsert(ctx context.Context, record T, criteria ...repository.UpdateCriteria) (T, error) {
 	result, err := c.base.Upsert(ctx, record, criteria...)
 	if err == nil {
-		// Upsert can either insert or update, so we need to invalidate like an update
-		c.invalidateAfterUpdate(ctx, result)
+		// Upsert can either insert or update, so it also invalidates normalized lists
+		err = c.invalidateAfterUpsert(ctx, "Upsert", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "Upsert", result))
 	}
 	return result, err
 }
@@ -278,7 +588,9 @@ func (c *CachedRepository[T]) Upsert(ctx context.Context, record T, criteria ...
 func (c *CachedRepository[T]) UpsertTx(ctx context.Context, tx bun.IDB, record T, criteria ...repository.UpdateCriteria) (T, error) {
 	result, err := c.base.UpsertTx(ctx, tx, record, criteria...)
 	if err == nil {
-		c.invalidateAfterUpdate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "UpsertTx", func() error {
+			return c.invalidateAfterUpsert(ctx, "UpsertTx", result)
+		}, result)
 	}
 	return result, err
 }
@@ -287,7 +599,8 @@ func (c *CachedRepository[T]) UpsertTx(ctx context.Context, tx bun.IDB, record T
 func (c *CachedRepository[T]) UpsertMany(ctx context.Context, records []T, criteria ...repository.UpdateCriteria) ([]T, error) {
 	result, err := c.base.UpsertMany(ctx, records, criteria...)
 	if err == nil {
-		c.invalidateAfterBulkUpdate(ctx, result)
+		err = c.invalidateAfterBulkUpsert(ctx, "UpsertMany", result)
+		err = joinPopulateError(err, c.populateRecords(ctx, "UpsertMany", result...))
 	}
 	return result, err
 }
@@ -296,7 +609,9 @@ func (c *CachedRepository[T]) UpsertMany(ctx context.Context, records []T, crite
 func (c *CachedRepository[T]) UpsertManyTx(ctx context.Context, tx bun.IDB, records []T, criteria ...repository.UpdateCriteria) ([]T, error) {
 	result, err := c.base.UpsertManyTx(ctx, tx, records, criteria...)
 	if err == nil {
-		c.invalidateAfterBulkUpdate(ctx, result)
+		err = c.invalidateAfterTx(ctx, "UpsertManyTx", func() error {
+			return c.invalidateAfterBulkUpsert(ctx, "UpsertManyTx", result)
+		}, result...)
 	}
 	return result, err
 }
@@ -305,7 +620,7 @@ func (c *CachedRepository[T]) UpsertManyTx(ctx context.Context, tx bun.IDB, reco
 func (c *CachedRepository[T]) Delete(ctx context.Context, record T) error {
 	err := c.base.Delete(ctx, record)
 	if err == nil {
-		c.invalidateAfterDelete(ctx, record)
+		err = c.invalidateAfterDelete(ctx, "Delete", record)
 	}
 	return err
 }
@@ -314,7 +629,9 @@ func (c *CachedRepository[T]) Delete(ctx context.Context, record T) error {
 func (c *CachedRepository[T]) DeleteTx(ctx context.Context, tx bun.IDB, record T) error {
 	err := c.base.DeleteTx(ctx, tx, record)
 	if err == nil {
-		c.invalidateAfterDelete(ctx, record)
+		err = c.invalidateAfterTx(ctx, "DeleteTx", func() error {
+			return c.invalidateAfterDelete(ctx, "DeleteTx", record)
+		})
 	}
 	return err
 }
@@ -324,7 +641,7 @@ func (c *CachedRepository[T]) DeleteMany(ctx context.Context, criteria ...reposi
 	err := c.base.DeleteMany(ctx, criteria...)
 	if err == nil {
 		// Since we don't have the actual records, invalidate all relevant caches
-		c.invalidateAfterCriteriaOperation(ctx)
+		err = c.invalidateAfterCriteriaOperation(ctx, "DeleteMany")
 	}
 	return err
 }
@@ -333,7 +650,9 @@ func (c *CachedRepository[T]) DeleteMany(ctx context.Context, criteria ...reposi
 func (c *CachedRepository[T]) DeleteManyTx(ctx context.Context, tx bun.IDB, criteria ...repository.DeleteCriteria) error {
 	err := c.base.DeleteManyTx(ctx, tx, criteria...)
 	if err == nil {
-		c.invalidateAfterCriteriaOperation(ctx)
+		err = c.invalidateAfterTx(ctx, "DeleteManyTx", func() error {
+			return c.invalidateAfterCriteriaOperation(ctx, "DeleteManyTx")
+		})
 	}
 	return err
 }
@@ -343,7 +662,7 @@ func (c *CachedRepository[T]) DeleteWhere(ctx context.Context, criteria ...repos
 	err := c.base.DeleteWhere(ctx, criteria...)
 	if err == nil {
 		// Since we don't have the actual records, invalidate all relevant caches
-		c.invalidateAfterCriteriaOperation(ctx)
+		err = c.invalidateAfterCriteriaOperation(ctx, "DeleteWhere")
 	}
 	return err
 }
@@ -352,7 +671,9 @@ func (c *CachedRepository[T]) DeleteWhere(ctx context.Context, criteria ...repos
 func (c *CachedRepository[T]) DeleteWhereTx(ctx context.Context, tx bun.IDB, criteria ...repository.DeleteCriteria) error {
 	err := c.base.DeleteWhereTx(ctx, tx, criteria...)
 	if err == nil {
-		c.invalidateAfterCriteriaOperation(ctx)
+		err = c.invalidateAfterTx(ctx, "DeleteWhereTx", func() error {
+			return c.invalidateAfterCriteriaOperation(ctx, "DeleteWhereTx")
+		})
 	}
 	return err
 }
@@ -361,7 +682,7 @@ func (c *CachedRepository[T]) DeleteWhereTx(ctx context.Context, tx bun.IDB, cri
 func (c *CachedRepository[T]) ForceDelete(ctx context.Context, record T) error {
 	err := c.base.ForceDelete(ctx, record)
 	if err == nil {
-		c.invalidateAfterDelete(ctx, record)
+		err = c.invalidateAfterDelete(ctx, "ForceDelete", record)
 	}
 	return err
 }
@@ -370,43 +691,52 @@ func (c *CachedRepository[T]) ForceDelete(ctx context.Context, record T) error {
 func (c *CachedRepository[T]) ForceDeleteTx(ctx context.Context, tx bun.IDB, record T) error {
 	err := c.base.ForceDeleteTx(ctx, tx, record)
 	if err == nil {
-		c.invalidateAfterDelete(ctx, record)
+		err = c.invalidateAfterTx(ctx, "ForceDeleteTx", func() error {
+			return c.invalidateAfterDelete(ctx, "ForceDeleteTx", record)
+		})
 	}
 	return err
 }
 
 // GetTx retrieves a single record using the provided criteria within a transaction
 func (c *CachedRepository[T]) GetTx(ctx context.Context, tx bun.IDB, criteria ...repository.SelectCriteria) (T, error) {
+	c.bypass(ctx, "GetTx", BypassTx)
 	return c.base.GetTx(ctx, tx, criteria...)
 }
 
 // GetByIDTx retrieves a record by ID with optional criteria within a transaction
 func (c *CachedRepository[T]) GetByIDTx(ctx context.Context, tx bun.IDB, id string, criteria ...repository.SelectCriteria) (T, error) {
+	c.bypass(ctx, "GetByIDTx", BypassTx)
 	return c.base.GetByIDTx(ctx, tx, id, criteria...)
 }
 
 // ListTx retrieves multiple records using the provided criteria within a transaction
 func (c *CachedRepository[T]) ListTx(ctx context.Context, tx bun.IDB, criteria ...repository.SelectCriteria) ([]T, int, error) {
+	c.bypass(ctx, "ListTx", BypassTx)
 	return c.base.ListTx(ctx, tx, criteria...)
 }
 
 // CountTx returns the number of records matching the criteria within a transaction
 func (c *CachedRepository[T]) CountTx(ctx context.Context, tx bun.IDB, criteria ...repository.SelectCriteria) (int, error) {
+	c.bypass(ctx, "CountTx", BypassTx)
 	return c.base.CountTx(ctx, tx, criteria...)
 }
 
 // GetByIdentifierTx retrieves a record by identifier with optional criteria within a transaction
 func (c *CachedRepository[T]) GetByIdentifierTx(ctx context.Context, tx bun.IDB, identifier string, criteria ...repository.SelectCriteria) (T, error) {
+	c.bypass(ctx, "GetByIdentifierTx", BypassTx)
 	return c.base.GetByIdentifierTx(ctx, tx, identifier, criteria...)
 }
 
 // Raw executes a raw SQL query and returns the results
 func (c *CachedRepository[T]) Raw(ctx context.Context, sql string, args ...any) ([]T, error) {
+	c.bypass(ctx, "Raw", BypassRaw)
 	return c.base.Raw(ctx, sql, args...)
 }
 
 // RawTx executes a raw SQL query within a transaction and returns the results
 func (c *CachedRepository[T]) RawTx(ctx context.Context, tx bun.IDB, sql string, args ...any) ([]T, error) {
+	c.bypass(ctx, "RawTx", BypassRaw)
 	return c.base.RawTx(ctx, tx, sql, args...)
 }
 
@@ -477,9 +807,9 @@ func (c *CachedRepository[T]) extractIdentifierValues(record T) ([]string, error
 	return values, nil
 }
 
-func (c *CachedRepository[T]) invalidateRecordCaches(ctx context.Context, record T) {
+func (c *CachedRepository[T]) invalidateRecordCaches(ctx context.Context, inv *invalidation, record T) {
 	if id, err := c.extractID(record); err == nil && id != "" {
-		c.deleteByPrefix(ctx, c.methodPrefix("GetByID", id))
+		c.deleteByPrefix(ctx, inv, c.methodPrefix("GetByID", id))
 	}
 
 	if identifiers, err := c.extractIdentifierValues(record); err == nil {
@@ -487,91 +817,171 @@ func (c *CachedRepository[T]) invalidateRecordCaches(ctx context.Context, record
 			if identifier == "" {
 				continue
 			}
-			c.deleteByPrefix(ctx, c.methodPrefix("GetByIdentifier", identifier))
+			c.deleteByPrefix(ctx, inv, c.methodPrefix("GetByIdentifier", identifier))
 		}
 	} else {
-		c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("GetByIdentifier"))
+		c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("GetByIdentifier"))
 	}
 }
 
+// previousIdentifiers returns the identifier values GetByIdentifier entries were
+// cached under for record's ID, which may differ from the values record now holds.
+func (c *CachedRepository[T]) previousIdentifiers(record T) []string {
+	id, err := c.extractID(record)
+	if err != nil {
+		return nil
+	}
+	return c.identifierIndex.take(id)
+}
+
+// restoreIdentifiers puts previous back in the identifier index when inv failed
+// to evict their entries, so a later write retries them.
+func (c *CachedRepository[T]) restoreIdentifiers(inv *invalidation, record T, previous []string) {
+	if len(previous) == 0 || len(inv.failures) == 0 {
+		return
+	}
+	if id, err := c.extractID(record); err == nil {
+		c.identifierIndex.restore(id, previous, c.identifierRetention())
+	}
+}
+
+// invalidateAfterTx runs invalidate once the transaction carried by ctx commits.
+// Without a TxInvalidations collector in ctx the invalidation runs immediately
+// and its error is returned.
+func (c *CachedRepository[T]) invalidateAfterTx(ctx context.Context, operation string, invalidate func() error, written ...T) error {
+	// Written records are only populated once the transaction commits, so
+	// without a collector they are left for the next read.
+	deferred := func() error {
+		err := invalidate()
+		return joinPopulateError(err, c.populateRecords(ctx, operation, written...))
+	}
+	if pending := txInvalidationsFromContext(ctx); pending != nil && pending.add(deferred) {
+		return nil
+	}
+	return invalidate()
+}
+
 // invalidateAfterCreate invalidates caches after create operations
-func (c *CachedRepository[T]) invalidateAfterCreate(ctx context.Context, records ...T) error {
+func (c *CachedRepository[T]) invalidateAfterCreate(ctx context.Context, operation string, records ...T) error {
+	inv := c.startInvalidation(operation)
+
 	tags := c.writeInvalidationTags(ctx, records)
-	if c.invalidateTags(ctx, tags) {
-		return nil
+	tags = appendTags(tags, c.membershipInvalidationTags(ctx))
+	if c.invalidateTags(ctx, inv, tags) {
+		return c.finishInvalidation(ctx, inv)
 	}
 
 	for _, record := range records {
-		c.invalidateRecordCaches(ctx, record)
+		c.invalidateRecordCaches(ctx, inv, record)
 	}
 
-	c.invalidateGetCaches(ctx)
-	c.deleteKey(ctx, "List")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("List"))
-	c.deleteKey(ctx, "Count")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("Count"))
+	c.invalidateGetCaches(ctx, inv)
+	c.deleteKey(ctx, inv, "List")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("List"))
+	c.deleteKey(ctx, inv, "Count")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("Count"))
 
-	return nil
+	return c.finishInvalidation(ctx, inv)
 }
 
-// invalidateAfterUpdate invalidates all relevant caches after update operations
-func (c *CachedRepository[T]) invalidateAfterUpdate(ctx context.Context, record T) error {
+// invalidateAfterUpdate invalidates all relevant caches after update operations.
+// Normalized List entries are kept: an update does not change which records exist.
+func (c *CachedRepository[T]) invalidateAfterUpdate(ctx context.Context, operation string, record T) error {
+	return c.invalidateAfterRecordWrite(ctx, operation, record, nil)
+}
+
+// invalidateAfterRecordWrite invalidates the caches of a written record plus the
+// extra tags.
+func (c *CachedRepository[T]) invalidateAfterRecordWrite(ctx context.Context, operation string, record T, extra []string) error {
+	inv := c.startInvalidation(operation)
+
+	previous := c.previousIdentifiers(record)
 	tags := c.writeInvalidationTags(ctx, []T{record})
-	if c.invalidateTags(ctx, tags) {
-		return nil
+	tags = appendTags(tags, extra)
+	for _, identifier := range previous {
+		if tag, ok := c.identifierTag(identifier); ok {
+			tags = appendTag(tags, tag)
+		}
+	}
+	if c.invalidateTags(ctx, inv, tags) {
+		return c.finishInvalidation(ctx, inv)
 	}
 
-	c.invalidateRecordCaches(ctx, record)
+	c.invalidateRecordCaches(ctx, inv, record)
+	for _, identifier := range previous {
+		c.deleteByPrefix(ctx, inv, c.methodPrefix("GetByIdentifier", identifier))
+	}
+	c.restoreIdentifiers(inv, record, previous)
 
 	// Invalidate all query result caches (List/Count/Get with criteria)
-	c.invalidateGetCaches(ctx)
-	c.deleteKey(ctx, "List")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("List"))
-	c.deleteKey(ctx, "Count")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("Count"))
+	c.invalidateGetCaches(ctx, inv)
+	c.deleteKey(ctx, inv, "List")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("List"))
+	c.deleteKey(ctx, inv, "Count")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("Count"))
 
-	return nil
+	return c.finishInvalidation(ctx, inv)
 }
 
 // invalidateAfterDelete invalidates all relevant caches after delete operations
-func (c *CachedRepository[T]) invalidateAfterDelete(ctx context.Context, record T) error {
-	// Same logic as update - delete affects the same caches
-	return c.invalidateAfterUpdate(ctx, record)
+func (c *CachedRepository[T]) invalidateAfterDelete(ctx context.Context, operation string, record T) error {
+	// Same logic as update, but the record also leaves normalized List entries
+	return c.invalidateAfterRecordWrite(ctx, operation, record, c.membershipInvalidationTags(ctx))
+}
+
+// invalidateAfterUpsert invalidates caches after upsert operations, which may
+// insert the record
+func (c *CachedRepository[T]) invalidateAfterUpsert(ctx context.Context, operation string, record T) error {
+	return c.invalidateAfterRecordWrite(ctx, operation, record, c.membershipInvalidationTags(ctx))
+}
+
+// invalidateAfterBulkUpsert invalidates caches after bulk upsert operations
+func (c *CachedRepository[T]) invalidateAfterBulkUpsert(ctx context.Context, operation string, records []T) error {
+	var errs []error
+	for _, record := range records {
+		if err := c.invalidateAfterUpsert(ctx, operation, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // invalidateAfterBulkUpdate invalidates caches after bulk update operations
-func (c *CachedRepository[T]) invalidateAfterBulkUpdate(ctx context.Context, records []T) error {
+func (c *CachedRepository[T]) invalidateAfterBulkUpdate(ctx context.Context, operation string, records []T) error {
+	var errs []error
 	for _, record := range records {
-		if err := c.invalidateAfterUpdate(ctx, record); err != nil {
-			return err
+		if err := c.invalidateAfterUpdate(ctx, operation, record); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // invalidateAfterBulkCreate invalidates caches after bulk create operations
-func (c *CachedRepository[T]) invalidateAfterBulkCreate(ctx context.Context, records []T) error {
-	return c.invalidateAfterCreate(ctx, records...)
+func (c *CachedRepository[T]) invalidateAfterBulkCreate(ctx context.Context, operation string, records []T) error {
+	return c.invalidateAfterCreate(ctx, operation, records...)
 }
 
 // invalidateAfterCriteriaOperation invalidates caches after operations that use criteria instead of records
-func (c *CachedRepository[T]) invalidateAfterCriteriaOperation(ctx context.Context) error {
-	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
-	tags := []string{c.listTag(), c.scopeTag(signature)}
-	if c.invalidateTags(ctx, tags) {
-		return nil
+func (c *CachedRepository[T]) invalidateAfterCriteriaOperation(ctx context.Context, operation string) error {
+	inv := c.startInvalidation(operation)
+
+	tags := c.queryInvalidationTags(ctx)
+	tags = appendTags(tags, c.membershipInvalidationTags(ctx))
+	if c.invalidateTags(ctx, inv, tags) {
+		return c.finishInvalidation(ctx, inv)
 	}
 
 	// For operations like DeleteMany where we don't have the actual records,
 	// we must invalidate all relevant caches since we can't target specific keys
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("GetByID"))
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("GetByIdentifier"))
-	c.deleteKey(ctx, "List")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("List"))
-	c.deleteKey(ctx, "Count")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("Count"))
-	c.invalidateGetCaches(ctx)
-	return nil
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("GetByID"))
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("GetByIdentifier"))
+	c.deleteKey(ctx, inv, "List")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("List"))
+	c.deleteKey(ctx, inv, "Count")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("Count"))
+	c.invalidateGetCaches(ctx, inv)
+	return c.finishInvalidation(ctx, inv)
 }
 
 func structValue(record any) (reflect.Value, error) {
@@ -667,7 +1077,21 @@ func deriveNamespace[T any](_ repository.Repository[T]) string {
 		}
 	}
 
-	return toSnake(name)
+	namespace := toSnake(name)
+	if pkg := typ.PkgPath(); pkg != "" {
+		// Types named alike in different packages must not share entries.
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(pkg))
+		namespace += fmt.Sprintf("_%08x", hash.Sum32())
+	}
+	return namespace
+}
+
+// Namespace returns the prefix of the repository's keys and tags. It defaults to
+// the snake_cased record type name plus a hash of its package path, and can be
+// set with WithNamespace.
+func (c *CachedRepository[T]) Namespace() string {
+	return c.namespace
 }
 
 func (c *CachedRepository[T]) resolveIdentifierFields(explicit []string) []string {
@@ -741,6 +1165,29 @@ func (c *CachedRepository[T]) key(method string, args ...any) string {
 	return c.keySerializer.SerializeKey(methodKey, args...)
 }
 
+// namedKey returns the key and tags of a read named with WithCacheKey. Leading
+// args (an ID or identifier) come first so record-level prefix invalidation still
+// matches.
+func (c *CachedRepository[T]) namedKey(method string, signature repository.ScopeState, named *cacheKeyName, tags []string, leading ...any) (string, []string) {
+	args := append(leading, "custom", named.name)
+	if !signature.IsZero() {
+		args = append(args, signature)
+	}
+	tags = appendTag(tags, c.listTag())
+	tags = appendTags(tags, named.tags)
+	return c.key(method, args...), tags
+}
+
+// queryCacheKey builds the cache key of a Query read. Leading args (an ID or
+// identifier) come first so record-level prefix invalidation still matches.
+func (c *CachedRepository[T]) queryCacheKey(method string, signature repository.ScopeState, query Query, leading ...any) string {
+	args := append(leading, "query", query.key())
+	if !signature.IsZero() {
+		args = append(args, signature)
+	}
+	return c.key(method, args...)
+}
+
 func (c *CachedRepository[T]) methodKey(method string) string {
 	return strings.Join([]string{c.namespace, toSnake(method)}, cache.KeySeparator)
 }
@@ -757,37 +1204,168 @@ func (c *CachedRepository[T]) methodPrefixWithSeparator(method string) string {
 	return c.methodKey(method) + cache.KeySeparator
 }
 
-func (c *CachedRepository[T]) deleteKey(ctx context.Context, method string, args ...any) {
+func (c *CachedRepository[T]) deleteKey(ctx context.Context, inv *invalidation, method string, args ...any) {
 	key := c.key(method, args...)
-	_ = c.cache.Delete(ctx, key)
+	c.clonePolicy.Load().forgetKey(key)
+	if err := c.cache.Delete(ctx, key); err != nil {
+		inv.fail(InvalidationEvent{Keys: []string{key}}, err)
+		return
+	}
+	inv.key(key)
 }
 
-func (c *CachedRepository[T]) deleteByPrefix(ctx context.Context, prefix string) {
-	_ = c.cache.DeleteByPrefix(ctx, prefix)
+func (c *CachedRepository[T]) deleteByPrefix(ctx context.Context, inv *invalidation, prefix string) {
+	c.clonePolicy.Load().forgetPrefix(prefix)
+	if err := c.cache.DeleteByPrefix(ctx, prefix); err != nil {
+		inv.fail(InvalidationEvent{Prefixes: []string{prefix}}, err)
+		return
+	}
+	inv.prefix(prefix)
 }
 
-func (c *CachedRepository[T]) invalidateGetCaches(ctx context.Context) {
-	c.deleteKey(ctx, "Get")
-	c.deleteByPrefix(ctx, c.methodPrefixWithSeparator("Get"))
+func (c *CachedRepository[T]) invalidateGetCaches(ctx context.Context, inv *invalidation) {
+	c.deleteKey(ctx, inv, "Get")
+	c.deleteByPrefix(ctx, inv, c.methodPrefixWithSeparator("Get"))
 }
 
-func (c *CachedRepository[T]) registerTags(ctx context.Context, key string, tags []string) {
-	tagRegistry, ok := c.cache.(cache.TagRegistry)
-	if !ok {
-		return
+// fetchThrough performs a read-through lookup of key tagged with tags and any
+// context tags. Cache services implementing cache.OptionsFetcher register the tags
+// before fetching and drop fills that race with an invalidation; otherwise the
+// tags are registered after a successful fetch.
+//
+// Values are copied according to the clone policy: a fill made by this call
+// returns the fetched value itself when the cache stores a copy of it.
+func fetchThrough[T any, R any](ctx context.Context, c *CachedRepository[T], method ReadMethod, key string, tags []string, fetchFn cache.FetchFn[R]) (R, error) {
+	policy := c.clonePolicy.Load()
+	if policy == nil && c.tagDeriver == nil {
+		return fetchThroughCache(ctx, c, method, key, tags, fetchFn)
 	}
+
+	var fetched atomic.Pointer[R]
+	result, err := fetchThroughCache(ctx, c, method, key, tags, func(ctx context.Context) (R, error) {
+		value, err := fetchFn(ctx)
+		if err != nil {
+			return value, err
+		}
+		if derived := c.derivedResultTags(ctx, value); len(derived) > 0 {
+			c.registerTags(ctx, key, derived)
+		}
+		if policy == nil {
+			return value, nil
+		}
+		stored, err := policy.stored(key, value, tags)
+		if err != nil {
+			return value, err
+		}
+		fetched.Store(&value)
+		return stored.(R), nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if policy == nil {
+		return result, nil
+	}
+	if original := fetched.Load(); original != nil && policy.onWrite() {
+		return *original, nil
+	}
+	value, err := policy.read(key, result)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+	return value.(R), nil
+}
+
+func fetchThroughCache[T any, R any](ctx context.Context, c *CachedRepository[T], method ReadMethod, key string, tags []string, fetchFn cache.FetchFn[R]) (R, error) {
+	fetchFn, measured := measureRead(c, method, fetchFn)
+	fetchFn, observed := observeRead(ctx, c, method, key, fetchFn)
+	tags = c.readTags(ctx, tags)
+	if _, ok := c.cache.(cache.OptionsFetcher); ok {
+		opts := cache.EntryOptions{Tags: tags, TTL: c.methodTTL(method)}
+		if negative := c.negativeCaching.Load(); negative != nil {
+			opts.NotFound = negative.IsNotFound
+			opts.NotFoundTTL = negative.TTL
+		}
+		if stale := c.staleIfError.Load(); stale != nil {
+			opts.StaleIfError = stale.Grace
+			opts.Transient = stale.IsTransient
+		}
+		result, err := cache.GetOrFetchWithOptions(ctx, c.cache, key, opts, fetchFn)
+		measured(err)
+		observed(err)
+		var stale *cache.StaleError
+		if errors.As(err, &stale) {
+			c.servedStale(ctx, stale.Err)
+			return result, nil
+		}
+		return result, err
+	}
+	result, err := cache.GetOrFetch(ctx, c.cache, key, fetchFn)
+	measured(err)
+	observed(err)
+	if err == nil {
+		c.registerTags(ctx, key, tags)
+	}
+	return result, err
+}
+
+func (c *CachedRepository[T]) readTags(ctx context.Context, tags []string) []string {
 	contextTags := cacheTagsFromContext(ctx)
 	if len(contextTags) > 0 {
 		tags = appendTags(tags, contextTags)
 	}
-	unique := dedupeStrings(tags)
+	return dedupeStrings(tags)
+}
+
+func (c *CachedRepository[T]) registerTags(ctx context.Context, key string, tags []string) {
+	tagRegistry, ok := c.cache.(cache.TagRegistry)
+	if !ok {
+		return
+	}
+	unique := c.readTags(ctx, tags)
 	if len(unique) == 0 {
 		return
 	}
-	_ = tagRegistry.AddTags(ctx, key, unique)
+	if err := tagRegistry.AddTags(ctx, key, unique); err != nil {
+		c.tagsNotRegistered(ctx, key, unique, err)
+	}
+}
+
+// watchNamespace returns a function reporting whether anything in the namespace
+// was invalidated since the call, or nil when the cache service cannot tell.
+func (c *CachedRepository[T]) watchNamespace() func() bool {
+	watcher, ok := c.cache.(cache.TagWatcher)
+	if !ok {
+		return nil
+	}
+	return watcher.WatchNamespace(c.namespace)
 }
 
-func (c *CachedRepository[T]) invalidateTags(ctx context.Context, tags []string) bool {
+// tagRecordID registers the entry at key under the id tag of the record fetchFn
+// returns, which is only known once fetched. Invalidating the record then evicts
+// entries keyed by its identifiers on every node, including identifiers the
+// writer never read.
+func (c *CachedRepository[T]) tagRecordID(key string, fetchFn cache.FetchFn[T]) cache.FetchFn[T] {
+	return func(ctx context.Context) (T, error) {
+		record, err := fetchFn(ctx)
+		if err != nil {
+			return record, err
+		}
+		if id, idErr := c.extractID(record); idErr == nil {
+			if tag, ok := c.idTag(id); ok {
+				c.registerTags(ctx, key, []string{tag})
+			}
+		}
+		return record, nil
+	}
+}
+
+// invalidateTags invalidates tags through the cache's tag registry. It reports
+// false when the cache has none or the invalidation fails, and the caller falls
+// back to deleting keys. The failure policy only sees the failure when that
+// fallback fails too.
+func (c *CachedRepository[T]) invalidateTags(ctx context.Context, inv *invalidation, tags []string) bool {
 	tagRegistry, ok := c.cache.(cache.TagRegistry)
 	if !ok {
 		return false
@@ -797,8 +1375,11 @@ func (c *CachedRepository[T]) invalidateTags(ctx context.Context, tags []string)
 		return false
 	}
 	if err := tagRegistry.InvalidateTags(ctx, unique); err != nil {
+		inv.failTags(unique, err)
 		return false
 	}
+	c.clonePolicy.Load().forgetTags(unique)
+	inv.tags(unique)
 	return true
 }
 
@@ -870,10 +1451,10 @@ func (c *CachedRepository[T]) recordTags(record T) []string {
 }
 
 func (c *CachedRepository[T]) writeInvalidationTags(ctx context.Context, records []T) []string {
-	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
-	tags := []string{c.listTag(), c.scopeTag(signature)}
+	tags := c.queryInvalidationTags(ctx)
 	for _, record := range records {
 		tags = appendTags(tags, c.recordTags(record))
 	}
+	tags = appendTags(tags, c.derivedTags(ctx, records...))
 	return dedupeStrings(tags)
 }
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	htmltemplate "html/template"
 	"reflect"
 	"strings"
 	"sync"
 	"testing"
+	texttemplate "text/template"
+	"time"
 
 	repository "github.com/goliatone/go-repository-bun"
 	"github.com/goliatone/go-repository-cache/cache"
@@ -168,7 +171,8 @@ func (m *mockRepository[T]) GetByIdentifierTx(ctx context.Context, tx bun.IDB, i
 	return m.getByIDResult2, m.getByIDError2
 }
 func (m *mockRepository[T]) UpdateTx(ctx context.Context, tx bun.IDB, record T, criteria ...repository.UpdateCriteria) (T, error) {
-	panic("UpdateTx not implemented in mock")
+	m.recordCall("UpdateTx")
+	return m.updateResult, m.updateError
 }
 func (m *mockRepository[T]) UpdateMany(ctx context.Context, records []T, criteria ...repository.UpdateCriteria) ([]T, error) {
 	panic("UpdateMany not implemented in mock")
@@ -189,7 +193,8 @@ func (m *mockRepository[T]) UpsertManyTx(ctx context.Context, tx bun.IDB, record
 	panic("UpsertManyTx not implemented in mock")
 }
 func (m *mockRepository[T]) DeleteTx(ctx context.Context, tx bun.IDB, record T) error {
-	panic("DeleteTx not implemented in mock")
+	m.recordCall("DeleteTx")
+	return m.deleteError
 }
 func (m *mockRepository[T]) DeleteMany(ctx context.Context, criteria ...repository.DeleteCriteria) error {
 	m.recordCall("DeleteMany")
@@ -367,19 +372,20 @@ func (m *mockCacheService) GetOrFetch(ctx context.Context, key string, fetchFn a
 	m.recordCall(fmt.Sprintf("GetOrFetch:%s", key))
 
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	// Check for configured error
 	if err, exists := m.errors[key]; exists {
+		m.mu.Unlock()
 		return nil, err
 	}
 
 	// Check for cache hit
 	if value, exists := m.storage[key]; exists {
+		m.mu.Unlock()
 		return value, nil
 	}
+	m.mu.Unlock()
 
-	// Cache miss - call fetch function
+	// Cache miss - call fetch function, which may call back into the cache
 	fv := reflect.ValueOf(fetchFn)
 	result := fv.Call([]reflect.Value{reflect.ValueOf(ctx)})
 
@@ -394,6 +400,8 @@ func (m *mockCacheService) GetOrFetch(ctx context.Context, key string, fetchFn a
 
 	// Store result in cache and return
 	value := result[0].Interface()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.storage[key] = value
 	return value, nil
 }
@@ -551,6 +559,26 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestDeriveNamespace(t *testing.T) {
+	namespace := deriveNamespace[TestUser](nil)
+	if !strings.HasPrefix(namespace, "test_user_") {
+		t.Fatalf("expected namespace to start with the type name, got %q", namespace)
+	}
+	if pointer := deriveNamespace[*TestUser](nil); pointer != namespace {
+		t.Fatalf("expected pointer records to share the namespace, got %q and %q", pointer, namespace)
+	}
+
+	// Both types are named Template.
+	text := deriveNamespace[texttemplate.Template](nil)
+	html := deriveNamespace[htmltemplate.Template](nil)
+	if text == html {
+		t.Fatalf("expected types from different packages to get different namespaces, both got %q", text)
+	}
+	if strings.ContainsAny(text+html, "/.:") {
+		t.Fatalf("expected key-safe namespaces, got %q and %q", text, html)
+	}
+}
+
 // Test cache hit scenarios for read methods
 func TestCachedReadMethods_CacheHit(t *testing.T) {
 	tests := []struct {
@@ -1462,6 +1490,97 @@ func TestCacheInvalidation_Create(t *testing.T) {
 	}
 }
 
+// blockingGetByIDRepository returns the row visible when GetByID starts and
+// waits for release before handing it back, emulating a slow read that overlaps
+// a write.
+type blockingGetByIDRepository struct {
+	*mockRepository[TestUser]
+	mu      sync.Mutex
+	current TestUser
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingGetByIDRepository) GetByID(ctx context.Context, id string, criteria ...repository.SelectCriteria) (TestUser, error) {
+	r.recordCall("GetByID")
+	r.mu.Lock()
+	row := r.current
+	started, release := r.started, r.release
+	r.started, r.release = nil, nil
+	r.mu.Unlock()
+
+	if started != nil {
+		close(started)
+		<-release
+	}
+	return row, nil
+}
+
+func (r *blockingGetByIDRepository) setCurrent(user TestUser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = user
+}
+
+func TestCacheInvalidation_StaleFillRacingUpdate(t *testing.T) {
+	originalUser := TestUser{ID: "user-1", Name: "Original User"}
+	updatedUser := TestUser{ID: "user-1", Name: "Updated User"}
+
+	baseRepo := &blockingGetByIDRepository{
+		mockRepository: &mockRepository[TestUser]{updateResult: updatedUser},
+		current:        originalUser,
+		started:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	started, release := baseRepo.started, baseRepo.release
+
+	cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+	ctx := context.Background()
+
+	// The read misses and loads the original row, then stalls before the fill.
+	done := make(chan TestUser)
+	go func() {
+		user, err := cached.GetByID(ctx, "user-1")
+		if err != nil {
+			t.Errorf("racing GetByID failed: %v", err)
+		}
+		done <- user
+	}()
+	<-started
+
+	// The write commits and invalidates while the read is still in flight.
+	baseRepo.setCurrent(updatedUser)
+	if _, err := cached.Update(ctx, updatedUser); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	close(release)
+	if user := <-done; user.Name != originalUser.Name {
+		t.Fatalf("expected racing read to return the row it loaded, got %q", user.Name)
+	}
+
+	user, err := cached.GetByID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetByID after update failed: %v", err)
+	}
+	if user.Name != updatedUser.Name {
+		t.Fatalf("expected %q after update, got stale %q", updatedUser.Name, user.Name)
+	}
+	baseCalls := 0
+	for _, call := range baseRepo.getCalls() {
+		if call == "GetByID" {
+			baseCalls++
+		}
+	}
+	if got := baseCalls; got != 2 {
+		t.Fatalf("expected the stale fill to be dropped and GetByID refetched, got %d base calls", got)
+	}
+}
+
 // Test cache invalidation after update operations
 func TestCacheInvalidation_Update(t *testing.T) {
 	baseRepo := &mockRepository[TestUser]{}
@@ -1565,6 +1684,254 @@ func TestCacheInvalidation_Update(t *testing.T) {
 	}
 }
 
+func TestCacheInvalidation_UpdateEvictsPreviousIdentifier(t *testing.T) {
+	originalUser := TestUser{ID: "user-1", Name: "original"}
+	renamedUser := TestUser{ID: "user-1", Name: "renamed"}
+
+	countIdentifierCalls := func(calls []string) int {
+		count := 0
+		for _, call := range calls {
+			if call == "GetByIdentifier" {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("TagRegistry", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{getByIDResult2: originalUser, updateResult: renamedUser}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := NewWithIdentifierFields[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), "Name")
+		ctx := context.Background()
+		// Read under a scope the writer does not share so only identifier tags connect them.
+		readCtx := repository.WithScopeData(repository.WithSelectScopes(ctx, "tenant"), "tenant", "tenant-a")
+
+		if _, err := cached.GetByIdentifier(readCtx, "original"); err != nil {
+			t.Fatalf("GetByIdentifier failed: %v", err)
+		}
+		if _, err := cached.GetByIdentifier(readCtx, "original"); err != nil {
+			t.Fatalf("GetByIdentifier failed: %v", err)
+		}
+		if got := countIdentifierCalls(baseRepo.getCalls()); got != 1 {
+			t.Fatalf("expected second read to hit the cache, got %d base calls", got)
+		}
+
+		if _, err := cached.Update(ctx, renamedUser); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		if _, err := cached.GetByIdentifier(readCtx, "original"); err != nil {
+			t.Fatalf("GetByIdentifier failed: %v", err)
+		}
+		if got := countIdentifierCalls(baseRepo.getCalls()); got != 2 {
+			t.Fatalf("expected entry under the previous identifier to be evicted, got %d base calls", got)
+		}
+	})
+
+	t.Run("PrefixFallback", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{getByIDResult2: originalUser, updateResult: renamedUser}
+		cacheService := newMockCacheServiceNoTags()
+		cached := NewWithIdentifierFields[TestUser](baseRepo, cacheService, newTrackingKeySerializer(), "Name")
+		ctx := context.Background()
+
+		if _, err := cached.GetByIdentifier(ctx, "original"); err != nil {
+			t.Fatalf("GetByIdentifier failed: %v", err)
+		}
+		if _, err := cached.Update(ctx, renamedUser); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		expected := "DeleteByPrefix:" + cached.methodPrefix("GetByIdentifier", "original")
+		found := false
+		for _, call := range cacheService.getCalls() {
+			if call == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected %s, got calls %v", expected, cacheService.getCalls())
+		}
+
+		// The index entry is consumed by the write.
+		if previous := cached.identifierIndex.take("user-1"); len(previous) != 0 {
+			t.Fatalf("expected identifier index to be cleared, got %v", previous)
+		}
+	})
+}
+
+// prefixFailingCacheService fails every DeleteByPrefix.
+type prefixFailingCacheService struct {
+	*mockCacheServiceNoTags
+	err error
+}
+
+func (m *prefixFailingCacheService) DeleteByPrefix(ctx context.Context, prefix string) error {
+	m.recordCall(fmt.Sprintf("DeleteByPrefix:%s", prefix))
+	return m.err
+}
+
+func TestCacheInvalidation_IdentifierIndexLifetime(t *testing.T) {
+	originalUser := TestUser{ID: "user-1", Name: "original"}
+	renamedUser := TestUser{ID: "user-1", Name: "renamed"}
+
+	t.Run("ExpiresWithEntryTTL", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{getByIDResult2: originalUser}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := NewWithIdentifierFields[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), "Name")
+		now := time.Now()
+		cached.identifierIndex.now = func() time.Time { return now }
+
+		if _, err := cached.GetByIdentifier(context.Background(), "original"); err != nil {
+			t.Fatalf("GetByIdentifier failed: %v", err)
+		}
+		if got, want := cached.identifierRetention(), 2*cache.DefaultConfig().TTL; got != want {
+			t.Fatalf("expected retention %v, got %v", want, got)
+		}
+
+		now = now.Add(cached.identifierRetention())
+		cached.identifierIndex.remember("user-2", "other", cached.identifierRetention())
+		if got := cached.identifierIndex.size(); got != 1 {
+			t.Fatalf("expected expired identifiers to be swept, got %d entries", got)
+		}
+		if previous := cached.identifierIndex.take("user-1"); len(previous) != 0 {
+			t.Fatalf("expected no identifiers for user-1, got %v", previous)
+		}
+	})
+
+	t.Run("RestoredWhenInvalidationFails", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{getByIDResult2: originalUser, updateResult: renamedUser}
+		cacheService := &prefixFailingCacheService{
+			mockCacheServiceNoTags: newMockCacheServiceNoTags(),
+			err:                    errors.New("cache unavailable"),
+		}
+		cached := NewWithIdentifierFields[TestUser](baseRepo, cacheService, newTrackingKeySerializer(), "Name")
+		ctx := context.Background()
+
+		if _, err := cached.GetByIdentifier(ctx, "original"); err != nil {
+			t.Fatalf("GetByIdentifier failed: %v", err)
+		}
+		if _, err := cached.Update(ctx, renamedUser); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		previous := cached.identifierIndex.take("user-1")
+		if len(previous) != 1 || previous[0] != "original" {
+			t.Fatalf("expected the previous identifier to be kept for the next write, got %v", previous)
+		}
+	})
+}
+
+func TestCacheInvalidation_ScopeInvalidationMode(t *testing.T) {
+	user := TestUser{ID: "user-1", Name: "User 1"}
+	other := TestUser{ID: "user-2", Name: "User 2"}
+
+	countBaseCalls := func(calls []string, method string) int {
+		count := 0
+		for _, call := range calls {
+			if call == method {
+				count++
+			}
+		}
+		return count
+	}
+
+	type reads struct {
+		tenantGet, adminGet, tenantGetByID int
+	}
+
+	// List and Count entries always carried the list tag, so only Get and the
+	// scope-tagged record entries tell the modes apart.
+	run := func(t *testing.T, mode ScopeInvalidationMode) reads {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			getResult:     user,
+			getByIDResult: other,
+			updateResult:  user,
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+		cached.SetScopeInvalidationMode(mode)
+
+		tenantCtx := repository.WithScopeData(repository.WithSelectScopes(context.Background(), "tenant"), "tenant", "tenant-a")
+		adminCtx := repository.WithoutDefaultScopes(context.Background())
+
+		for _, ctx := range []context.Context{tenantCtx, adminCtx} {
+			if _, err := cached.Get(ctx); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+		}
+		if _, err := cached.GetByID(tenantCtx, other.ID); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+
+		if _, err := cached.Update(tenantCtx, user); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		var got reads
+		read := func(ctx context.Context, get func(context.Context) error, method string) int {
+			baseRepo.clearCalls()
+			if err := get(ctx); err != nil {
+				t.Fatalf("%s failed: %v", method, err)
+			}
+			return countBaseCalls(baseRepo.getCalls(), method)
+		}
+		getFn := func(ctx context.Context) error {
+			_, err := cached.Get(ctx)
+			return err
+		}
+		got.tenantGet = read(tenantCtx, getFn, "Get")
+		got.adminGet = read(adminCtx, getFn, "Get")
+		got.tenantGetByID = read(tenantCtx, func(ctx context.Context) error {
+			_, err := cached.GetByID(ctx, other.ID)
+			return err
+		}, "GetByID")
+		return got
+	}
+
+	t.Run("All", func(t *testing.T) {
+		got := run(t, ScopeInvalidationAll)
+		if got.tenantGet != 1 {
+			t.Fatalf("expected writer scope to refetch Get, got %d base reads", got.tenantGet)
+		}
+		if got.adminGet != 1 {
+			t.Fatalf("expected admin scope Get to be evicted through the list tag, got %d base reads", got.adminGet)
+		}
+		if got.tenantGetByID != 1 {
+			t.Fatalf("expected writer scope GetByID entries to be evicted, got %d base reads", got.tenantGetByID)
+		}
+	})
+
+	t.Run("Current", func(t *testing.T) {
+		got := run(t, ScopeInvalidationCurrent)
+		if got.tenantGet != 1 {
+			t.Fatalf("expected writer scope to refetch Get, got %d base reads", got.tenantGet)
+		}
+		if got.adminGet != 0 {
+			t.Fatalf("expected admin scope Get to stay cached, got %d base reads", got.adminGet)
+		}
+		if got.tenantGetByID != 1 {
+			t.Fatalf("expected the scope tag to evict writer scope GetByID entries of other records, got %d base reads", got.tenantGetByID)
+		}
+	})
+
+	t.Run("DefaultIsAll", func(t *testing.T) {
+		cached := New[TestUser](&mockRepository[TestUser]{}, newMockCacheService(), cache.NewDefaultKeySerializer())
+		if mode := cached.ScopeInvalidationMode(); mode != ScopeInvalidationAll {
+			t.Fatalf("expected default mode %s, got %s", ScopeInvalidationAll, mode)
+		}
+	})
+}
+
 func TestCacheInvalidation_UpdateWithScopedGetByID(t *testing.T) {
 	baseRepo := &mockRepository[TestUser]{}
 	cacheService := newMockCacheService()
@@ -2198,6 +2565,137 @@ func TestCachedRepository_ContextTags(t *testing.T) {
 	}
 }
 
+func TestCachedRepository_WithCacheKey(t *testing.T) {
+	activeAdmins := repository.SelectRawProcessor(func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("role = ?", "admin")
+	})
+
+	t.Run("CachesCriteriaReadsUnderName", func(t *testing.T) {
+		baseRepo := &criteriaRecordingRepository{mockRepository: &mockRepository[TestUser]{
+			listRecords: []TestUser{{ID: "user-1", Name: "Admin"}},
+			listTotal:   1,
+		}}
+		cacheService := newMockCacheService()
+		keySerializer := cache.NewDefaultKeySerializer()
+		cached := New[TestUser](baseRepo, cacheService, keySerializer)
+		for i := 0; i < 2; i++ {
+			ctx := WithCacheKey(context.Background(), "active-admins", "admins")
+			records, total, err := cached.List(ctx, activeAdmins)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if total != 1 || len(records) != 1 {
+				t.Fatalf("unexpected result %v %d", records, total)
+			}
+		}
+		if !reflect.DeepEqual(baseRepo.listCriteria, []int{1}) {
+			t.Fatalf("expected a single base List receiving the criteria, got %v", baseRepo.listCriteria)
+		}
+
+		if _, _, err := cached.List(WithCacheKey(context.Background(), "active-owners"), activeAdmins); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(baseRepo.listCriteria) != 2 {
+			t.Fatalf("expected a different name to use its own entry, got %v", baseRepo.listCriteria)
+		}
+
+		signature := cached.scopeSignature(context.Background(), repository.ScopeOperationSelect)
+		key := cached.key("List", "custom", "active-admins")
+		cacheService.mu.Lock()
+		defer cacheService.mu.Unlock()
+		for _, tag := range []string{cached.listTag(), cached.scopeTag(signature), "admins"} {
+			if _, ok := cacheService.tags[tag][key]; !ok {
+				t.Fatalf("expected %s to be registered under %s", key, tag)
+			}
+		}
+	})
+
+	t.Run("WritesInvalidateNamedEntries", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{
+			listRecords:   []TestUser{{ID: "user-1", Name: "Admin"}},
+			listTotal:     1,
+			getByIDResult: TestUser{ID: "user-1", Name: "Admin"},
+			createResult:  TestUser{ID: "user-2", Name: "Admin 2"},
+			updateResult:  TestUser{ID: "user-1", Name: "Admin"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+		named := func() context.Context { return WithCacheKey(context.Background(), "active-admins") }
+
+		if _, _, err := cached.List(named(), activeAdmins); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if _, err := cached.GetByID(named(), "user-1", activeAdmins); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if _, err := cached.GetByID(named(), "user-1", activeAdmins); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, []string{"List", "GetByID"}) {
+			t.Fatalf("expected named reads to be cached, got %v", calls)
+		}
+		baseRepo.clearCalls()
+
+		if _, err := cached.Create(context.Background(), TestUser{Name: "Admin 2"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, _, err := cached.List(named(), activeAdmins); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if _, err := cached.Update(context.Background(), TestUser{ID: "user-1", Name: "Admin"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if _, err := cached.GetByID(named(), "user-1", activeAdmins); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+
+		expected := []string{"Create", "List", "Update", "GetByID"}
+		if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, expected) {
+			t.Fatalf("expected writes to invalidate named entries, got %v", calls)
+		}
+	})
+
+	t.Run("NameAppliesToOneRead", func(t *testing.T) {
+		baseRepo := &criteriaRecordingRepository{mockRepository: &mockRepository[TestUser]{
+			listRecords: []TestUser{{ID: "user-1", Name: "Admin"}},
+			listTotal:   1,
+		}}
+		cached := New[TestUser](baseRepo, newMockCacheService(), cache.NewDefaultKeySerializer())
+		ctx := WithCacheKey(context.Background(), "active-admins")
+		activeOwners := repository.SelectRawProcessor(func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("role = ?", "owner")
+		})
+
+		if _, _, err := cached.List(ctx, activeAdmins); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		// A later read reusing ctx must not be served the named entry.
+		if _, _, err := cached.List(ctx, activeOwners); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(baseRepo.listCriteria) != 2 {
+			t.Fatalf("expected the second read to reach the base repository, got %v", baseRepo.listCriteria)
+		}
+	})
+
+	t.Run("EmptyNameKeepsPassThrough", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{}
+		cacheService := newMockCacheService()
+		cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+		ctx := WithCacheKey(context.Background(), "")
+
+		if _, _, err := cached.List(ctx, activeAdmins); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if calls := cacheService.getCalls(); len(calls) != 0 {
+			t.Fatalf("expected criteria read to bypass the cache, got %v", calls)
+		}
+	})
+}
+
 func TestCachedRepository_TagRegistration_CacheHit(t *testing.T) {
 	baseRepo := &mockRepository[TestUser]{}
 	cacheService := newMockCacheService()
@@ -58,6 +58,54 @@
 // Reads with function-valued SelectCriteria bypass the cache because closure code
 // pointers do not include captured query values.
 //
+// Filtered reads can be cached by describing them with a Query and calling
+// GetQuery, GetByIDQuery, GetByIdentifierQuery, ListQuery or CountQuery. A Query
+// holds where-equals and in conditions, ordering, limit/offset and soft-delete
+// inclusion as plain values; the decorator serializes its normalized form into the
+// key and translates it into SelectCriteria for the base repository.
+//
+// WithCacheKey is the escape hatch for filters a Query cannot express: it names
+// the next read made with the context so the decorator caches it despite its
+// criteria, keyed under the repository's method key and tagged like a List entry.
+//
+// GetByIDs resolves many IDs at once. Each record shares the GetByID key and tags
+// of its ID, so the two methods reuse each other's entries, and the IDs missing
+// from the cache are loaded with a single List filtered with IN.
+// SetGetByIDCoalescing applies the same loading to concurrent GetByID misses,
+// merging those that arrive within a short window into one batch.
+//
+// SetNormalizedLists switches List and ListQuery entries to ordered IDs plus the
+// total, resolving records from their GetByID entries so updates no longer evict
+// every cached page.
+//
+// Cached values are shared between callers. SetClonePolicy copies them on read
+// and/or on write with a Cloner, and can detect callers mutating cached values.
+//
+// SetWriteThrough stores the records returned by Create, Update and Upsert under
+// their GetByID and GetByIdentifier keys right after the write invalidates, so the
+// next read of a record just written is served from the cache.
+//
+// SetTTLPolicy gives the repository's entries their own TTL, with overrides per
+// read method, when the cache service supports per-entry TTLs.
+//
+// SetNegativeCaching caches the base repository's not found errors for a short
+// TTL and returns the original error on hits.
+//
+// SetStaleIfError keeps serving recently expired values when their refresh fails
+// with a transient error; WithStaleSignal reports such reads to the caller.
+//
+// SetMetrics reports hits, misses, fills, errors, stale reads, invalidations and
+// read and fetch latencies to a cache.Metrics, keyed by namespace and read method.
+//
+// AddObserver registers an Observer notified of hits, misses, fills, reads
+// bypassing the cache and the invalidations of each write, including the ones the
+// cache service failed to apply. Embed NopObserver to handle only some events.
+//
+// NewWithOptions builds a repository from functional options: identifier fields,
+// a namespace override, per-method enablement with WithCachedMethods and
+// WithoutCachedMethods, derived invalidation tags with WithTagDeriver, and the
+// settings of the setters above.
+//
 // # Transaction Handling
 //
 // Operations within transactions (*Tx methods) bypass the cache entirely to ensure
@@ -66,6 +114,19 @@
 //   - Cache pollution from uncommitted transaction data
 //   - Inconsistent reads across transaction boundaries
 //
+// Writes made through *Tx methods invalidate the cache immediately unless the
+// context carries a TxInvalidations collector. RunInTx attaches one, runs the
+// callback inside bun's RunInTx and performs the queued invalidations only after
+// the transaction commits; a rollback drops them:
+//
+//	err := repositorycache.RunInTx(ctx, db, func(ctx context.Context, tx bun.Tx) error {
+//		_, err := cached.UpdateTx(ctx, tx, user)
+//		return err
+//	})
+//
+// Callers that manage the transaction themselves can use DeferInvalidations and
+// call Flush after the commit or Discard after the rollback.
+//
 // # Cache Invalidation Strategy
 //
 // The invalidation strategy is documented in REPOSITORY_CACHE.md and implemented
@@ -74,8 +135,27 @@
 // record-aware eviction. If TagRegistry is not available, the decorator falls
 // back to prefix-based invalidation.
 //
+// By default writes evict query caches (Get, List, Count) of every scope signature
+// in the namespace. SetScopeInvalidationMode(ScopeInvalidationCurrent) limits that
+// to the writer's scope, where it evicts every cached entry, for repositories
+// whose scopes partition the data.
+//
+// Cache services implementing cache.OptionsFetcher receive the read tags before
+// the fetch runs. A fill whose tags are invalidated while it is in flight is
+// returned to its caller but not stored, so a read that overlaps a write cannot
+// cache the pre-write row.
+//
 // Custom read paths can attach extra tags using repositorycache.WithCacheTags.
 //
+// SetInvalidationFailurePolicy decides what a write does when the cache service
+// fails to invalidate: ignore the failure, pass it to a hook, return an
+// *InvalidationError carrying the tags and keys left in place, or retry with
+// backoff and then enqueue it.
+//
+// SetInvalidationBus publishes the invalidations of every write on a
+// cache.InvalidationBus, so processes running their own in-memory caches can
+// apply them with cache.SubscribeInvalidations.
+//
 // # Integration with Dependency Injection
 //
 // This package is designed to work with the dependency injection container
@@ -0,0 +1,143 @@
+package repositorycache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// identifierRetentionFactor scales the longest entry TTL into the lifetime of
+// an identifier index entry, which must outlive the GetByIdentifier entry it
+// points at.
+const identifierRetentionFactor = 2
+
+// identifierIndex maps record IDs to the identifier values their
+// GetByIdentifier entries were cached under. Writes consult it to evict entries
+// keyed by identifiers the record no longer carries, such as a renamed slug.
+//
+// Each identifier carries a deadline pushed forward whenever it is read again.
+// Identifiers past their deadline can no longer be cached and are dropped
+// lazily, which keeps the index bounded by the entries that can still exist.
+type identifierIndex struct {
+	mu        sync.Mutex
+	byID      map[string]map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func (i *identifierIndex) remember(id, identifier string, retention time.Duration) {
+	if id == "" || identifier == "" {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := i.clock()
+	if now.Sub(i.lastSweep) >= retention {
+		i.sweep(now)
+	}
+	i.add(id, identifier, now.Add(retention))
+}
+
+// take returns the live identifiers recorded for id and forgets them. Reads that
+// run after the write record the identifiers that are still current.
+func (i *identifierIndex) take(id string) []string {
+	if id == "" {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	identifiers := i.byID[id]
+	if len(identifiers) == 0 {
+		return nil
+	}
+	delete(i.byID, id)
+	now := i.clock()
+	values := make([]string, 0, len(identifiers))
+	for identifier, deadline := range identifiers {
+		if now.Before(deadline) {
+			values = append(values, identifier)
+		}
+	}
+	return values
+}
+
+// restore records identifiers for id again, after a write that took them failed
+// to evict their entries.
+func (i *identifierIndex) restore(id string, identifiers []string, retention time.Duration) {
+	if id == "" || len(identifiers) == 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	deadline := i.clock().Add(retention)
+	for _, identifier := range identifiers {
+		i.add(id, identifier, deadline)
+	}
+}
+
+// size returns the number of identifiers held, expired or not.
+func (i *identifierIndex) size() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	total := 0
+	for _, identifiers := range i.byID {
+		total += len(identifiers)
+	}
+	return total
+}
+
+// add records identifier for id until deadline. Callers must hold i.mu.
+func (i *identifierIndex) add(id, identifier string, deadline time.Time) {
+	if i.byID == nil {
+		i.byID = make(map[string]map[string]time.Time)
+	}
+	identifiers := i.byID[id]
+	if identifiers == nil {
+		identifiers = make(map[string]time.Time)
+		i.byID[id] = identifiers
+	}
+	if deadline.After(identifiers[identifier]) {
+		identifiers[identifier] = deadline
+	}
+}
+
+// sweep drops expired identifiers. Callers must hold i.mu.
+func (i *identifierIndex) sweep(now time.Time) {
+	for id, identifiers := range i.byID {
+		for identifier, deadline := range identifiers {
+			if !now.Before(deadline) {
+				delete(identifiers, identifier)
+			}
+		}
+		if len(identifiers) == 0 {
+			delete(i.byID, id)
+		}
+	}
+	i.lastSweep = now
+}
+
+func (i *identifierIndex) clock() time.Time {
+	if i.now != nil {
+		return i.now()
+	}
+	return time.Now()
+}
+
+// identifierRetention returns how long the identifier index keeps an identifier
+// after a read: twice the longest TTL a GetByIdentifier entry can have.
+func (c *CachedRepository[T]) identifierRetention() time.Duration {
+	longest := max(c.longestEntryTTL(), c.methodTTL(MethodGetByIdentifier))
+	return longest * identifierRetentionFactor
+}
+
+// longestEntryTTL returns the longest TTL the cache service gives an entry.
+// Services that do not implement cache.TTLReporter are assumed to use the
+// default TTL of cache.DefaultConfig.
+func (c *CachedRepository[T]) longestEntryTTL() time.Duration {
+	longest := cache.DefaultConfig().TTL
+	if reporter, ok := c.cache.(cache.TTLReporter); ok {
+		longest = max(reporter.DefaultTTL(), reporter.MaxTTL())
+	}
+	return longest
+}
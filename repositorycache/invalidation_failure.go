@@ -0,0 +1,286 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// InvalidationError reports cache entries a write failed to invalidate. The
+// write itself succeeded; the entries may serve stale data until they expire or
+// are invalidated again. Err joins the errors returned by the cache service.
+type InvalidationError struct {
+	InvalidationEvent
+	Err error
+}
+
+// Error implements error.
+func (e *InvalidationError) Error() string {
+	return fmt.Sprintf("repositorycache: %s failed to invalidate %s entries (tags=%v keys=%v prefixes=%v): %v",
+		e.Operation, e.Namespace, e.Tags, e.Keys, e.Prefixes, e.Err)
+}
+
+// Unwrap returns the cache service errors.
+func (e *InvalidationError) Unwrap() error {
+	return e.Err
+}
+
+// Invalidation returns the entries left in place, to apply them again with
+// cache.ApplyInvalidation or queue them.
+func (e *InvalidationError) Invalidation() cache.Invalidation {
+	return cache.Invalidation{
+		Namespace: e.Namespace,
+		Tags:      e.Tags,
+		Keys:      e.Keys,
+		Prefixes:  e.Prefixes,
+	}
+}
+
+// EnqueueTo returns an InvalidationFailurePolicy.Enqueue function handing failed
+// invalidations to queue, such as a *cache.RetryQueue retrying them in the
+// background.
+func EnqueueTo(queue cache.InvalidationQueue) func(ctx context.Context, failed *InvalidationError) error {
+	return func(ctx context.Context, failed *InvalidationError) error {
+		return queue.Enqueue(ctx, failed.Invalidation())
+	}
+}
+
+// InvalidationFailureMode selects what a write does when its invalidation fails.
+type InvalidationFailureMode int
+
+const (
+	// InvalidationFailureIgnore drops failures. Observers still receive them
+	// through OnInvalidationError. It is the default.
+	InvalidationFailureIgnore InvalidationFailureMode = iota
+
+	// InvalidationFailureHook passes every failure to OnFailure and lets the
+	// write succeed.
+	InvalidationFailureHook
+
+	// InvalidationFailureReturn returns the *InvalidationError from the write
+	// method alongside its result. The write has been applied, so callers must
+	// not retry it; they can retry the invalidation or flush the namespace.
+	InvalidationFailureReturn
+
+	// InvalidationFailureRetry retries the failed invalidations with exponential
+	// backoff before the write returns, then hands what still fails to Enqueue.
+	InvalidationFailureRetry
+)
+
+// String returns the mode name.
+func (m InvalidationFailureMode) String() string {
+	switch m {
+	case InvalidationFailureIgnore:
+		return "ignore"
+	case InvalidationFailureHook:
+		return "hook"
+	case InvalidationFailureReturn:
+		return "return"
+	case InvalidationFailureRetry:
+		return "retry"
+	default:
+		return "unknown"
+	}
+}
+
+// InvalidationFailurePolicy configures how writes handle invalidation failures.
+type InvalidationFailurePolicy struct {
+	Mode InvalidationFailureMode
+	// OnFailure receives failures in the hook mode, failures Enqueue rejects in
+	// the retry mode, and failures of reads that could not register their tags.
+	OnFailure func(ctx context.Context, failed *InvalidationError)
+	// Retries is how many times the retry mode retries a failed invalidation.
+	// Zero uses DefaultInvalidationRetries.
+	Retries int
+	// Backoff is the delay before the first retry, doubled after each attempt
+	// up to MaxBackoff. Zero uses DefaultInvalidationBackoff.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries. Zero uses
+	// DefaultInvalidationMaxBackoff.
+	MaxBackoff time.Duration
+	// Enqueue receives the invalidations that still fail after the retries,
+	// for example to retry them in the background. Nil passes them to
+	// OnFailure.
+	Enqueue func(ctx context.Context, failed *InvalidationError) error
+}
+
+const (
+	// DefaultInvalidationRetries is the default number of retries of the retry
+	// mode.
+	DefaultInvalidationRetries = 3
+	// DefaultInvalidationBackoff is the default delay before the first retry.
+	DefaultInvalidationBackoff = 10 * time.Millisecond
+	// DefaultInvalidationMaxBackoff is the default cap on the retry delay.
+	DefaultInvalidationMaxBackoff = 250 * time.Millisecond
+)
+
+// SetInvalidationFailurePolicy selects how writes handle invalidations the
+// cache service fails to apply. Failures of every part of a write's
+// invalidation, such as a tag invalidation and the key deletes of its fallback,
+// are reported together as one *InvalidationError. A failed tag invalidation
+// whose fallback succeeds is only reported to observers.
+//
+// The retry mode blocks the write while it retries; the write's context bounds
+// the wait. Bulk writes return the errors of every record joined. Writes deferred
+// with DeferInvalidations or RunInTx report their failures from Flush and RunInTx.
+func (c *CachedRepository[T]) SetInvalidationFailurePolicy(policy InvalidationFailurePolicy) {
+	if policy.Retries <= 0 {
+		policy.Retries = DefaultInvalidationRetries
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultInvalidationBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultInvalidationMaxBackoff
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	c.invalidationFailures.Store(&policy)
+}
+
+// InvalidationFailurePolicy reports the active invalidation failure policy.
+func (c *CachedRepository[T]) InvalidationFailurePolicy() InvalidationFailurePolicy {
+	policy := c.invalidationFailures.Load()
+	if policy == nil {
+		return InvalidationFailurePolicy{}
+	}
+	return *policy
+}
+
+// invalidationFailed applies the failure policy to the failures collected by
+// inv and returns the error the write reports.
+func (c *CachedRepository[T]) invalidationFailed(ctx context.Context, inv *invalidation) error {
+	if len(inv.failures) == 0 {
+		return nil
+	}
+	policy := c.invalidationFailures.Load()
+	if policy == nil || policy.Mode == InvalidationFailureIgnore {
+		return nil
+	}
+
+	all := func(invalidationFailure) bool { return true }
+	switch policy.Mode {
+	case InvalidationFailureHook:
+		policy.report(ctx, mergeFailures(inv, all))
+	case InvalidationFailureReturn:
+		return mergeFailures(inv, all)
+	case InvalidationFailureRetry:
+		local := mergeFailures(inv, func(f invalidationFailure) bool { return !f.publish })
+		if local != nil {
+			local = c.retryInvalidation(ctx, policy, local, c.applyInvalidation)
+		}
+		if local != nil && policy.Enqueue != nil {
+			if err := policy.Enqueue(ctx, local); err != nil {
+				local.Err = errors.Join(local.Err, err)
+			} else {
+				local = nil
+			}
+		}
+		if local != nil {
+			policy.report(ctx, local)
+		}
+
+		// Queued invalidations are applied locally, so publish failures are
+		// retried here and reported when they keep failing.
+		published := mergeFailures(inv, func(f invalidationFailure) bool { return f.publish })
+		if published != nil {
+			published = c.retryInvalidation(ctx, policy, published, c.publish)
+		}
+		if published != nil {
+			policy.report(ctx, published)
+		}
+	}
+	return nil
+}
+
+// mergeFailures returns the failures of inv selected by keep as one
+// *InvalidationError, or nil when there are none.
+func mergeFailures(inv *invalidation, keep func(invalidationFailure) bool) *InvalidationError {
+	failed := &InvalidationError{InvalidationEvent: InvalidationEvent{
+		Namespace: inv.event.Namespace,
+		Operation: inv.event.Operation,
+	}}
+	var errs []error
+	for _, failure := range inv.failures {
+		if !keep(failure) {
+			continue
+		}
+		failed.Tags = appendTags(failed.Tags, failure.event.Tags)
+		failed.Keys = appendTags(failed.Keys, failure.event.Keys)
+		failed.Prefixes = appendTags(failed.Prefixes, failure.event.Prefixes)
+		errs = append(errs, failure.err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	failed.Err = errors.Join(errs...)
+	return failed
+}
+
+// retryInvalidation calls attempt with failed, then with what still fails, up to
+// the policy's retries with backoff, and returns what still fails, or nil.
+func (c *CachedRepository[T]) retryInvalidation(ctx context.Context, policy *InvalidationFailurePolicy, failed *InvalidationError, attempt func(context.Context, *InvalidationError) *InvalidationError) *InvalidationError {
+	backoff := policy.Backoff
+	for i := 0; i < policy.Retries; i++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			failed.Err = errors.Join(failed.Err, ctx.Err())
+			return failed
+		case <-timer.C:
+		}
+		if failed = attempt(ctx, failed); failed == nil {
+			return nil
+		}
+		backoff = min(backoff*2, policy.MaxBackoff)
+	}
+	return failed
+}
+
+// applyInvalidation invalidates the tags, keys and prefixes of failed again and
+// returns the ones that still fail, or nil.
+func (c *CachedRepository[T]) applyInvalidation(ctx context.Context, failed *InvalidationError) *InvalidationError {
+	remaining, err := cache.ApplyInvalidation(ctx, c.cache, failed.Invalidation())
+	if err == nil {
+		return nil
+	}
+	return &InvalidationError{
+		InvalidationEvent: InvalidationEvent{
+			Namespace: failed.Namespace,
+			Operation: failed.Operation,
+			Tags:      remaining.Tags,
+			Keys:      remaining.Keys,
+			Prefixes:  remaining.Prefixes,
+		},
+		Err: err,
+	}
+}
+
+// tagsNotRegistered handles a cached entry whose tags could not be registered:
+// no write would invalidate it, so it is deleted. Reads never return the error;
+// a failed delete is passed to the policy's OnFailure.
+func (c *CachedRepository[T]) tagsNotRegistered(ctx context.Context, key string, tags []string, err error) {
+	deleteErr := c.cache.Delete(ctx, key)
+	if deleteErr == nil {
+		return
+	}
+	policy := c.invalidationFailures.Load()
+	if policy == nil || policy.Mode == InvalidationFailureIgnore {
+		return
+	}
+	policy.report(ctx, &InvalidationError{
+		InvalidationEvent: InvalidationEvent{Namespace: c.namespace, Tags: tags, Keys: []string{key}},
+		Err:               errors.Join(err, deleteErr),
+	})
+}
+
+func (p *InvalidationFailurePolicy) report(ctx context.Context, failed *InvalidationError) {
+	if p.OnFailure != nil {
+		p.OnFailure(ctx, failed)
+	}
+}
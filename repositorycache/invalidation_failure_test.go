@@ -0,0 +1,177 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// flakyTagCache fails the first failures calls to InvalidateTags. With
+// failPrefixes set, DeleteByPrefix fails too while the last InvalidateTags
+// failed.
+type flakyTagCache struct {
+	*mockCacheService
+	failures     atomic.Int32
+	err          error
+	failPrefixes bool
+	down         atomic.Bool
+}
+
+func (f *flakyTagCache) InvalidateTags(ctx context.Context, tags []string) error {
+	if f.failures.Add(-1) >= 0 {
+		f.down.Store(true)
+		return f.err
+	}
+	f.down.Store(false)
+	return f.mockCacheService.InvalidateTags(ctx, tags)
+}
+
+func (f *flakyTagCache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	if f.failPrefixes && f.down.Load() {
+		return f.err
+	}
+	return f.mockCacheService.DeleteByPrefix(ctx, prefix)
+}
+
+func TestCachedRepository_InvalidationFailurePolicy(t *testing.T) {
+	errTagIndex := errors.New("tag index unavailable")
+	newRepo := func(t *testing.T, failures int32, policy InvalidationFailurePolicy) *CachedRepository[TestUser] {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{updateResult: TestUser{ID: "user-1", Name: "Updated"}}
+		cacheService := &flakyTagCache{mockCacheService: newMockCacheService(), err: errTagIndex, failPrefixes: true}
+		cacheService.failures.Store(failures)
+		return NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(),
+			WithInvalidationFailurePolicy(policy))
+	}
+	ctx := context.Background()
+	user := TestUser{ID: "user-1", Name: "Updated"}
+
+	t.Run("Ignore", func(t *testing.T) {
+		cached := newRepo(t, 1, InvalidationFailurePolicy{})
+		if _, err := cached.Update(ctx, user); err != nil {
+			t.Fatalf("expected the failure to be ignored, got %v", err)
+		}
+	})
+
+	t.Run("Hook", func(t *testing.T) {
+		var reported []*InvalidationError
+		cached := newRepo(t, 1, InvalidationFailurePolicy{
+			Mode: InvalidationFailureHook,
+			OnFailure: func(ctx context.Context, failed *InvalidationError) {
+				reported = append(reported, failed)
+			},
+		})
+		if _, err := cached.Update(ctx, user); err != nil {
+			t.Fatalf("expected the write to succeed, got %v", err)
+		}
+		if len(reported) != 1 || !errors.Is(reported[0], errTagIndex) {
+			t.Fatalf("expected one reported failure, got %v", reported)
+		}
+	})
+
+	t.Run("Return", func(t *testing.T) {
+		cached := newRepo(t, 1, InvalidationFailurePolicy{Mode: InvalidationFailureReturn})
+		result, err := cached.Update(ctx, user)
+		if result.ID != "user-1" {
+			t.Fatalf("expected the written record, got %+v", result)
+		}
+		var failed *InvalidationError
+		if !errors.As(err, &failed) {
+			t.Fatalf("expected an InvalidationError, got %v", err)
+		}
+		idTag, _ := cached.idTag("user-1")
+		if failed.Operation != "Update" || failed.Namespace != cached.Namespace() || !containsString(failed.Tags, idTag) {
+			t.Fatalf("expected the record's tags, got %+v", failed.InvalidationEvent)
+		}
+		if !errors.Is(err, errTagIndex) {
+			t.Fatalf("expected the cache service error to be wrapped, got %v", err)
+		}
+	})
+
+	t.Run("FallbackCoversTagFailure", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{updateResult: user}
+		cacheService := &flakyTagCache{mockCacheService: newMockCacheService(), err: errTagIndex}
+		cacheService.failures.Store(1)
+		cached := NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(),
+			WithInvalidationFailurePolicy(InvalidationFailurePolicy{Mode: InvalidationFailureReturn}))
+		if _, err := cached.Update(ctx, user); err != nil {
+			t.Fatalf("expected the prefix fallback to cover the failed tags, got %v", err)
+		}
+	})
+
+	t.Run("RetrySucceeds", func(t *testing.T) {
+		var enqueued atomic.Int32
+		cached := newRepo(t, 2, InvalidationFailurePolicy{
+			Mode:    InvalidationFailureRetry,
+			Retries: 3,
+			Backoff: time.Millisecond,
+			Enqueue: func(ctx context.Context, failed *InvalidationError) error {
+				enqueued.Add(1)
+				return nil
+			},
+		})
+		if _, err := cached.Update(ctx, user); err != nil {
+			t.Fatalf("expected the write to succeed, got %v", err)
+		}
+		if enqueued.Load() != 0 {
+			t.Fatal("expected the retry to succeed without enqueueing")
+		}
+	})
+
+	t.Run("RetryThenEnqueue", func(t *testing.T) {
+		var enqueued []*InvalidationError
+		cached := newRepo(t, 10, InvalidationFailurePolicy{
+			Mode:    InvalidationFailureRetry,
+			Retries: 2,
+			Backoff: time.Millisecond,
+			Enqueue: func(ctx context.Context, failed *InvalidationError) error {
+				enqueued = append(enqueued, failed)
+				return nil
+			},
+		})
+		if _, err := cached.Update(ctx, user); err != nil {
+			t.Fatalf("expected the write to succeed, got %v", err)
+		}
+		if len(enqueued) != 1 || len(enqueued[0].Tags) == 0 || len(enqueued[0].Keys) != 0 {
+			t.Fatalf("expected the failed tags to be enqueued, got %+v", enqueued)
+		}
+	})
+
+	t.Run("RetryQueue", func(t *testing.T) {
+		baseRepo := &mockRepository[TestUser]{updateResult: user}
+		cacheService := &flakyTagCache{mockCacheService: newMockCacheService(), err: errTagIndex, failPrefixes: true}
+		cacheService.failures.Store(2)
+		queue, err := cache.NewRetryQueue(ctx, cacheService, cache.RetryQueueConfig{Backoff: time.Hour})
+		if err != nil {
+			t.Fatalf("NewRetryQueue failed: %v", err)
+		}
+		cached := NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(),
+			WithInvalidationFailurePolicy(InvalidationFailurePolicy{
+				Mode:    InvalidationFailureRetry,
+				Retries: 1,
+				Backoff: time.Millisecond,
+				Enqueue: EnqueueTo(queue),
+			}))
+		if _, err := cached.Update(ctx, user); err != nil {
+			t.Fatalf("expected the write to succeed, got %v", err)
+		}
+		if queue.Len() != 1 {
+			t.Fatalf("expected the failed tags to be queued, got %d", queue.Len())
+		}
+		if err := queue.Drain(ctx); err != nil {
+			t.Fatalf("expected the queued tags to be invalidated, got %v", err)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		cached := newRepo(t, 0, InvalidationFailurePolicy{Mode: InvalidationFailureRetry})
+		policy := cached.InvalidationFailurePolicy()
+		if policy.Retries != DefaultInvalidationRetries || policy.Backoff != DefaultInvalidationBackoff || policy.MaxBackoff != DefaultInvalidationMaxBackoff {
+			t.Fatalf("expected default retry settings, got %+v", policy)
+		}
+	})
+}
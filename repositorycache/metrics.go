@@ -0,0 +1,134 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// SetMetrics reports the cached reads and invalidations of the repository to
+// metrics, under its namespace and the ReadMethod of each read. Nil stops
+// reporting.
+//
+// A read that fetched from the base repository counts as a miss, plus a fill
+// when the fetch succeeded; any other read answered from the cache counts as a
+// hit, including reads that waited on a fetch started by another caller and
+// cached not found errors. Failed reads count as errors and reads served a stale
+// value as stale. Reads record their latency in cache.HistogramRead and fetches
+// in cache.HistogramFetch. Writes count their invalidations without a method:
+// one per updated, deleted or upserted record, one per create call and one per
+// criteria-based write. Reads passed through to the base repository are not
+// reported.
+func (c *CachedRepository[T]) SetMetrics(metrics cache.Metrics) {
+	if metrics == nil {
+		c.metrics.Store(nil)
+		return
+	}
+	c.metrics.Store(&metrics)
+}
+
+// Metrics returns the metrics the repository reports to, or nil.
+func (c *CachedRepository[T]) Metrics() cache.Metrics {
+	if metrics := c.metrics.Load(); metrics != nil {
+		return *metrics
+	}
+	return nil
+}
+
+// measureRead wraps fetchFn to time it and returns a function recording the
+// outcome of the read once it returns err.
+func measureRead[T any, R any](c *CachedRepository[T], method ReadMethod, fetchFn cache.FetchFn[R]) (cache.FetchFn[R], func(err error)) {
+	metrics := c.Metrics()
+	if metrics == nil {
+		return fetchFn, func(error) {}
+	}
+	start := time.Now()
+	var fetched atomic.Bool
+	measured := func(ctx context.Context) (R, error) {
+		fetched.Store(true)
+		begin := time.Now()
+		value, err := fetchFn(ctx)
+		metrics.Observe(c.namespace, string(method), cache.HistogramFetch, time.Since(begin))
+		return value, err
+	}
+	return measured, func(err error) {
+		var stale *cache.StaleError
+		switch {
+		case errors.As(err, &stale):
+			c.count(metrics, method, cache.CounterMiss, 1)
+			c.count(metrics, method, cache.CounterStale, 1)
+		case fetched.Load():
+			c.count(metrics, method, cache.CounterMiss, 1)
+			if err == nil {
+				c.count(metrics, method, cache.CounterFill, 1)
+			} else {
+				c.count(metrics, method, cache.CounterError, 1)
+			}
+		case err == nil || c.cachedNotFound(err):
+			c.count(metrics, method, cache.CounterHit, 1)
+		default:
+			c.count(metrics, method, cache.CounterError, 1)
+		}
+		metrics.Observe(c.namespace, string(method), cache.HistogramRead, time.Since(start))
+	}
+}
+
+// measureBatch wraps fetchFn, which loads the missing IDs of a batch of size
+// reads, and returns a function recording the outcome of the batch once it
+// returns err. Every ID passed to fetchFn counts as a miss and the others as
+// hits.
+func measureBatch[T any](c *CachedRepository[T], method ReadMethod, size int, fetchFn cache.BatchFetchFn[T]) (cache.BatchFetchFn[T], func(err error)) {
+	metrics := c.Metrics()
+	if metrics == nil {
+		return fetchFn, func(error) {}
+	}
+	start := time.Now()
+	var missed, filled atomic.Uint64
+	measured := func(ctx context.Context, ids []string) (map[string]T, error) {
+		missed.Add(uint64(len(ids)))
+		begin := time.Now()
+		records, err := fetchFn(ctx, ids)
+		metrics.Observe(c.namespace, string(method), cache.HistogramFetch, time.Since(begin))
+		if err == nil {
+			filled.Add(uint64(len(records)))
+		}
+		return records, err
+	}
+	return measured, func(err error) {
+		misses := missed.Load()
+		if misses > uint64(size) {
+			// IDs fetched again after a discarded fill.
+			misses = uint64(size)
+		}
+		c.count(metrics, method, cache.CounterHit, uint64(size)-misses)
+		c.count(metrics, method, cache.CounterMiss, misses)
+		c.count(metrics, method, cache.CounterFill, filled.Load())
+		if err != nil {
+			c.count(metrics, method, cache.CounterError, 1)
+		}
+		metrics.Observe(c.namespace, string(method), cache.HistogramRead, time.Since(start))
+	}
+}
+
+// countInvalidation records a write invalidating entries of the namespace.
+func (c *CachedRepository[T]) countInvalidation() {
+	if metrics := c.Metrics(); metrics != nil {
+		metrics.Add(c.namespace, "", cache.CounterInvalidation, 1)
+	}
+}
+
+func (c *CachedRepository[T]) count(metrics cache.Metrics, method ReadMethod, counter cache.Counter, delta uint64) {
+	if delta > 0 {
+		metrics.Add(c.namespace, string(method), counter, delta)
+	}
+}
+
+// cachedNotFound reports whether err is a not found error negative caching
+// stores.
+func (c *CachedRepository[T]) cachedNotFound(err error) bool {
+	negative := c.negativeCaching.Load()
+	return negative != nil && negative.IsNotFound(err)
+}
@@ -0,0 +1,109 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+func TestCachedRepository_Metrics(t *testing.T) {
+	newRepo := func(t *testing.T) (*mockRepository[TestUser], *CachedRepository[TestUser], *cache.MemoryMetrics) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			getByIDResult: TestUser{ID: "user-1", Name: "User 1"},
+			listRecords:   []TestUser{{ID: "user-2", Name: "User 2"}},
+			updateResult:  TestUser{ID: "user-1", Name: "Updated"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		metrics := cache.NewMemoryMetrics()
+		cached := NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), WithMetrics(metrics))
+		return baseRepo, cached, metrics
+	}
+	ctx := context.Background()
+
+	t.Run("Reads", func(t *testing.T) {
+		baseRepo, cached, metrics := newRepo(t)
+		for i := 0; i < 3; i++ {
+			if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+				t.Fatalf("GetByID failed: %v", err)
+			}
+		}
+		baseRepo.countError = errors.New("connection refused")
+		cached.Count(ctx)
+
+		snapshot := metrics.Snapshot()
+		namespace := cached.Namespace()
+		getByID := string(MethodGetByID)
+		if hits := snapshot.Counter(namespace, getByID, cache.CounterHit); hits != 2 {
+			t.Fatalf("expected 2 hits, got %d", hits)
+		}
+		if misses := snapshot.Counter(namespace, getByID, cache.CounterMiss); misses != 1 {
+			t.Fatalf("expected 1 miss, got %d", misses)
+		}
+		if fills := snapshot.Counter(namespace, getByID, cache.CounterFill); fills != 1 {
+			t.Fatalf("expected 1 fill, got %d", fills)
+		}
+		series, _ := snapshot.Find(namespace, getByID)
+		if reads := series.Histograms[cache.HistogramRead].Count; reads != 3 {
+			t.Fatalf("expected 3 read latencies, got %d", reads)
+		}
+		if fetches := series.Histograms[cache.HistogramFetch].Count; fetches != 1 {
+			t.Fatalf("expected 1 fetch latency, got %d", fetches)
+		}
+
+		count := string(MethodCount)
+		if snapshot.Counter(namespace, count, cache.CounterMiss) != 1 || snapshot.Counter(namespace, count, cache.CounterError) != 1 {
+			t.Fatalf("expected the failed Count to record a miss and an error, got %+v", snapshot)
+		}
+	})
+
+	t.Run("BatchReads", func(t *testing.T) {
+		_, cached, metrics := newRepo(t)
+		if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		metrics.Reset()
+
+		if _, err := cached.GetByIDs(ctx, []string{"user-1", "user-2", "user-3"}); err != nil {
+			t.Fatalf("GetByIDs failed: %v", err)
+		}
+		snapshot := metrics.Snapshot()
+		namespace, getByID := cached.Namespace(), string(MethodGetByID)
+		if hits := snapshot.Counter(namespace, getByID, cache.CounterHit); hits != 1 {
+			t.Fatalf("expected 1 hit, got %d", hits)
+		}
+		if misses := snapshot.Counter(namespace, getByID, cache.CounterMiss); misses != 2 {
+			t.Fatalf("expected 2 misses, got %d", misses)
+		}
+		if fills := snapshot.Counter(namespace, getByID, cache.CounterFill); fills != 1 {
+			t.Fatalf("expected 1 fill, got %d", fills)
+		}
+	})
+
+	t.Run("Invalidations", func(t *testing.T) {
+		_, cached, metrics := newRepo(t)
+		if _, err := cached.Update(ctx, TestUser{ID: "user-1", Name: "Updated"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got := metrics.Snapshot().Counter(cached.Namespace(), "", cache.CounterInvalidation); got != 1 {
+			t.Fatalf("expected 1 invalidation, got %d", got)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		_, cached, metrics := newRepo(t)
+		cached.SetMetrics(nil)
+		if cached.Metrics() != nil {
+			t.Fatal("expected no metrics")
+		}
+		cached.GetByID(ctx, "user-1")
+		if series := metrics.Snapshot().Series; len(series) != 0 {
+			t.Fatalf("expected nothing recorded, got %+v", series)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package repositorycache
+
+import (
+	"time"
+
+	repository "github.com/goliatone/go-repository-bun"
+)
+
+// NegativeCaching configures caching of not found errors.
+type NegativeCaching struct {
+	// TTL is how long a not found error is cached. Keep it short: an entry only
+	// disappears early when a write in the namespace invalidates it. A zero or
+	// negative TTL disables negative caching.
+	TTL time.Duration
+	// IsNotFound reports whether an error returned by the base repository means
+	// the record does not exist. Nil uses repository.IsRecordNotFound.
+	IsNotFound func(err error) bool
+}
+
+// SetNegativeCaching enables or disables caching of not found errors.
+//
+// When enabled, Get, GetByID and GetByIdentifier reads, including their Query
+// variants, whose base read fails with a not found error cache the error for
+// cfg.TTL under the key and tags of the read. Hits return the original error, so
+// callers can keep checking it with repository.IsRecordNotFound or errors.Is.
+// Creating or upserting the record invalidates its entries as usual.
+//
+// Negative caching requires a cache service implementing cache.OptionsFetcher
+// with missing record storage enabled; the default service honors
+// cache.Config.MissingRecordStorage. Other errors are never cached.
+func (c *CachedRepository[T]) SetNegativeCaching(cfg NegativeCaching) {
+	if cfg.TTL <= 0 {
+		c.negativeCaching.Store(nil)
+		return
+	}
+	if cfg.IsNotFound == nil {
+		cfg.IsNotFound = repository.IsRecordNotFound
+	}
+	c.negativeCaching.Store(&cfg)
+}
+
+// NegativeCaching reports the active negative caching configuration.
+func (c *CachedRepository[T]) NegativeCaching() NegativeCaching {
+	cfg := c.negativeCaching.Load()
+	if cfg == nil {
+		return NegativeCaching{}
+	}
+	return *cfg
+}
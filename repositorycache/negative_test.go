@@ -0,0 +1,79 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+func TestCachedRepository_NegativeCaching(t *testing.T) {
+	errNotFound := errors.New("user not found")
+	isNotFound := func(err error) bool { return errors.Is(err, errNotFound) }
+
+	newRepo := func(t *testing.T, cfg NegativeCaching) (*mockRepository[TestUser], *CachedRepository[TestUser]) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			getByIDError: fmt.Errorf("user-1: %w", errNotFound),
+			createResult: TestUser{ID: "user-1", Name: "Created"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		return baseRepo, NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), WithNegativeCaching(cfg))
+	}
+	ctx := context.Background()
+
+	t.Run("CachesNotFound", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, NegativeCaching{TTL: time.Minute, IsNotFound: isNotFound})
+		for i := 0; i < 3; i++ {
+			if _, err := cached.GetByID(ctx, "user-1"); !errors.Is(err, errNotFound) {
+				t.Fatalf("expected the base repository's error, got %v", err)
+			}
+		}
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 1 {
+			t.Fatalf("expected not found to be cached, got %d GetByID calls", got)
+		}
+
+		baseRepo.getByIDError = nil
+		baseRepo.getByIDResult = TestUser{ID: "user-1", Name: "Created"}
+		if _, err := cached.Create(ctx, TestUser{ID: "user-1", Name: "Created"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		user, err := cached.GetByID(ctx, "user-1")
+		if err != nil || user.Name != "Created" {
+			t.Fatalf("expected Create to evict the cached not found, got %+v (%v)", user, err)
+		}
+	})
+
+	t.Run("OtherErrorsPassThrough", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, NegativeCaching{TTL: time.Minute, IsNotFound: isNotFound})
+		baseRepo.getByIDError = errors.New("connection refused")
+		cached.GetByID(ctx, "user-1")
+		cached.GetByID(ctx, "user-1")
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 2 {
+			t.Fatalf("expected other errors not to be cached, got %d GetByID calls", got)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, NegativeCaching{IsNotFound: isNotFound})
+		if cached.NegativeCaching().TTL != 0 {
+			t.Fatal("expected zero TTL to disable negative caching")
+		}
+		cached.GetByID(ctx, "user-1")
+		cached.GetByID(ctx, "user-1")
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 2 {
+			t.Fatalf("expected not found to pass through, got %d GetByID calls", got)
+		}
+
+		cached.SetNegativeCaching(NegativeCaching{TTL: time.Minute})
+		if cached.NegativeCaching().IsNotFound == nil {
+			t.Fatal("expected the default not found classifier")
+		}
+	})
+}
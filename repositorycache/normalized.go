@@ -0,0 +1,170 @@
+package repositorycache
+
+import (
+	"context"
+	"sync/atomic"
+
+	repository "github.com/goliatone/go-repository-bun"
+)
+
+// normalizedListArg is the leading key argument of normalized List entries, which
+// keeps them apart from entries holding full records.
+const normalizedListArg = "ids"
+
+// listIDsResult is the cached form of a normalized List: the ordered IDs of the
+// page and the total count.
+type listIDsResult struct {
+	IDs   []string `json:"ids"`
+	Total int      `json:"total"`
+}
+
+// SetNormalizedLists enables or disables normalized List caching.
+//
+// When enabled, List and ListQuery entries hold only the ordered IDs of the page
+// and the total. Records are resolved from their GetByID entries like GetByIDs,
+// loading any gaps with a single List filtered with IN, so every record is cached
+// once however many pages contain it. The List that fills a page also stores its
+// records under their GetByID keys, unless the cache service does not implement
+// cache.TagWatcher or the namespace was invalidated while the page was read.
+//
+// Normalized entries are evicted when records are created, deleted or upserted,
+// or by criteria-based writes, but not by Update: an update refreshes the
+// record's own entry and every cached page picks up the new version. Pages whose
+// filter or ordering depends on updated columns keep their previous membership
+// until their TTL expires, so leave this disabled for such reads. Records must
+// expose an ID field.
+func (c *CachedRepository[T]) SetNormalizedLists(enabled bool) {
+	c.normalizedLists.Store(enabled)
+}
+
+// NormalizedLists reports whether normalized List caching is enabled.
+func (c *CachedRepository[T]) NormalizedLists() bool {
+	return c.normalizedLists.Load()
+}
+
+// normalizedFill holds the records read by a normalized List fill and reports
+// whether the namespace was invalidated since they were read.
+type normalizedFill[T any] struct {
+	records     []T
+	total       int
+	invalidated func() bool
+}
+
+// normalizedList caches the ordered IDs and total returned by list under key and
+// resolves the records through their GetByID entries.
+func (c *CachedRepository[T]) normalizedList(ctx context.Context, signature repository.ScopeState, key string, tags []string, list func(ctx context.Context) ([]T, int, error)) ([]T, int, error) {
+	// A fill made by this call already holds the records.
+	var filled atomic.Pointer[normalizedFill[T]]
+	res, err := fetchThrough(ctx, c, MethodList, key, tags, func(ctx context.Context) (listIDsResult, error) {
+		invalidated := c.watchNamespace()
+		records, total, err := list(ctx)
+		if err != nil {
+			return listIDsResult{}, err
+		}
+		filled.Store(&normalizedFill[T]{records: records, total: total, invalidated: invalidated})
+		if derived := c.derivedTags(ctx, records...); len(derived) > 0 {
+			c.registerTags(ctx, key, derived)
+		}
+		ids := make([]string, len(records))
+		for i, record := range records {
+			id, idErr := c.extractID(record)
+			if idErr != nil {
+				// Returned without caching; the caller still gets the records.
+				return listIDsResult{}, idErr
+			}
+			ids[i] = id
+		}
+		return listIDsResult{IDs: ids, Total: total}, nil
+	})
+	if fill := filled.Load(); fill != nil {
+		if err == nil {
+			c.storeListedRecords(ctx, signature, fill.records, fill.invalidated)
+		}
+		return fill.records, fill.total, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	byID, err := c.getByIDs(ctx, signature, dedupeStrings(res.IDs))
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]T, 0, len(res.IDs))
+	for _, id := range res.IDs {
+		record, ok := byID[id]
+		if !ok {
+			// The record is gone; the cached page no longer matches the
+			// database, so drop it and read the page directly.
+			_ = c.cache.Delete(ctx, key)
+			return list(ctx)
+		}
+		records = append(records, record)
+	}
+	return records, res.Total, nil
+}
+
+// storeListedRecords caches records read by a normalized List fill under their
+// GetByID keys, so resolving the page later fetches nothing. They are dropped
+// when invalidated reports that the namespace changed since they were read, as
+// a write may have made them outdated.
+func (c *CachedRepository[T]) storeListedRecords(ctx context.Context, signature repository.ScopeState, records []T, invalidated func() bool) {
+	if invalidated == nil || invalidated() {
+		return
+	}
+	byID := make(map[string]T, len(records))
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		id, err := c.extractID(record)
+		if err != nil || id == "" {
+			continue
+		}
+		if _, ok := byID[id]; !ok {
+			ids = append(ids, id)
+		}
+		byID[id] = record
+	}
+	if len(ids) == 0 {
+		return
+	}
+	_, _ = c.fetchByIDs(ctx, signature, ids, func(_ context.Context, missing []string) (map[string]T, error) {
+		listed := make(map[string]T, len(missing))
+		for _, id := range missing {
+			if record, ok := byID[id]; ok {
+				listed[id] = record
+			}
+		}
+		return listed, nil
+	})
+	if invalidated() {
+		// A write landed before the batch snapshotted its tags.
+		for _, id := range ids {
+			_ = c.cache.Delete(ctx, c.recordKey("GetByID", id, signature))
+		}
+	}
+}
+
+// membershipTag is registered by every normalized List entry in the namespace.
+func (c *CachedRepository[T]) membershipTag() string {
+	return c.tagLabel("membership")
+}
+
+// membershipScopeTag is registered by normalized List entries cached under
+// signature.
+func (c *CachedRepository[T]) membershipScopeTag(signature repository.ScopeState) string {
+	return c.tagValue("membership_scope", signature)
+}
+
+func (c *CachedRepository[T]) membershipTags(signature repository.ScopeState) []string {
+	return []string{c.membershipTag(), c.membershipScopeTag(signature)}
+}
+
+// membershipInvalidationTags returns the tags a write uses to evict normalized
+// List entries, following the scope invalidation mode like queryInvalidationTags.
+func (c *CachedRepository[T]) membershipInvalidationTags(ctx context.Context) []string {
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	if c.ScopeInvalidationMode() == ScopeInvalidationCurrent {
+		return []string{c.membershipScopeTag(signature)}
+	}
+	return c.membershipTags(signature)
+}
@@ -0,0 +1,178 @@
+package repositorycache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+func TestNormalizedLists(t *testing.T) {
+	newRepo := func(t *testing.T) (*criteriaRecordingRepository, *CachedRepository[TestUser]) {
+		t.Helper()
+		baseRepo := &criteriaRecordingRepository{mockRepository: &mockRepository[TestUser]{
+			listRecords:  []TestUser{{ID: "user-1", Name: "User 1"}, {ID: "user-2", Name: "User 2"}},
+			listTotal:    2,
+			updateResult: TestUser{ID: "user-1", Name: "Renamed"},
+			createResult: TestUser{ID: "user-3", Name: "User 3"},
+		}}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+		cached.SetNormalizedLists(true)
+		return baseRepo, cached
+	}
+	list := func(t *testing.T, cached *CachedRepository[TestUser]) []TestUser {
+		t.Helper()
+		records, total, err := cached.List(context.Background())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if total != 2 {
+			t.Fatalf("expected total 2, got %d", total)
+		}
+		return records
+	}
+
+	t.Run("ResolvesRecordsFromEntityEntries", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+
+		first := list(t, cached)
+		second := list(t, cached)
+		third := list(t, cached)
+		if !reflect.DeepEqual(first, second) || !reflect.DeepEqual(second, third) {
+			t.Fatalf("expected identical pages, got %v, %v and %v", first, second, third)
+		}
+		// The page fetch also fills the entity entries.
+		if !reflect.DeepEqual(baseRepo.listCriteria, []int{0}) {
+			t.Fatalf("expected a single page fetch, got %v", baseRepo.listCriteria)
+		}
+
+		baseRepo.clearCalls()
+		if _, err := cached.GetByID(context.Background(), "user-2"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if calls := baseRepo.getCalls(); len(calls) != 0 {
+			t.Fatalf("expected GetByID to share the entity entry, got %v", calls)
+		}
+	})
+
+	t.Run("UpdateKeepsPage", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+		list(t, cached)
+		list(t, cached)
+		baseRepo.listCriteria = nil
+
+		if _, err := cached.Update(context.Background(), TestUser{ID: "user-1", Name: "Renamed"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		baseRepo.listRecords = []TestUser{{ID: "user-1", Name: "Renamed"}, {ID: "user-2", Name: "User 2"}}
+
+		records := list(t, cached)
+		if records[0].Name != "Renamed" || records[1].ID != "user-2" {
+			t.Fatalf("expected the updated record in its cached position, got %v", records)
+		}
+		if !reflect.DeepEqual(baseRepo.listCriteria, []int{1}) {
+			t.Fatalf("expected only entity entries to be refetched, got %v", baseRepo.listCriteria)
+		}
+	})
+
+	t.Run("CreateEvictsPage", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+		list(t, cached)
+		baseRepo.listCriteria = nil
+
+		if _, err := cached.Create(context.Background(), TestUser{ID: "user-3", Name: "User 3"}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		list(t, cached)
+		if len(baseRepo.listCriteria) == 0 || baseRepo.listCriteria[0] != 0 {
+			t.Fatalf("expected the page to be refetched after create, got %v", baseRepo.listCriteria)
+		}
+	})
+
+	t.Run("MissingRecordDropsPage", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+		list(t, cached)
+		// The record leaves without a membership invalidation, for example
+		// deleted under another scope.
+		tag, _ := cached.idTag("user-1")
+		if err := cached.cache.(cache.TagRegistry).InvalidateTags(context.Background(), []string{tag}); err != nil {
+			t.Fatalf("InvalidateTags failed: %v", err)
+		}
+		baseRepo.listRecords = []TestUser{{ID: "user-2", Name: "User 2"}}
+		baseRepo.listCriteria = nil
+
+		records, _, err := cached.List(context.Background())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(records) != 1 || records[0].ID != "user-2" {
+			t.Fatalf("expected the page to be read again, got %v", records)
+		}
+		if !reflect.DeepEqual(baseRepo.listCriteria, []int{1, 0}) {
+			t.Fatalf("expected an entity fetch followed by a page fetch, got %v", baseRepo.listCriteria)
+		}
+	})
+
+	t.Run("InvalidationDuringFillSkipsEntityEntries", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+		tag, _ := cached.idTag("user-1")
+		baseRepo.onList = func() {
+			// An update commits after the page was read.
+			_ = cached.cache.(cache.TagRegistry).InvalidateTags(context.Background(), []string{tag})
+		}
+		list(t, cached)
+		baseRepo.onList = nil
+		baseRepo.listCriteria = nil
+
+		list(t, cached)
+		if !reflect.DeepEqual(baseRepo.listCriteria, []int{1}) {
+			t.Fatalf("expected the entity entries to be fetched, got %v", baseRepo.listCriteria)
+		}
+	})
+}
+
+func TestNormalizedListsPastDefaultPagination(t *testing.T) {
+	baseRepo, ids := newPagedListRepository(40, 25)
+	cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+	cached.SetNormalizedLists(true)
+
+	for i := 0; i < 3; i++ {
+		records, _, err := cached.ListQuery(context.Background(), Query{Limit: len(ids)})
+		if err != nil {
+			t.Fatalf("ListQuery failed: %v", err)
+		}
+		if len(records) != len(ids) {
+			t.Fatalf("expected %d records, got %d", len(ids), len(records))
+		}
+	}
+	if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, []string{"List"}) {
+		t.Fatalf("expected the fill to cache every record, got %v", calls)
+	}
+
+	// Evicted entity entries are reloaded in one batch past the default limit.
+	baseRepo.clearCalls()
+	if err := cacheService.DeleteByPrefix(context.Background(), cached.methodPrefixWithSeparator("GetByID")); err != nil {
+		t.Fatalf("DeleteByPrefix failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		records, _, err := cached.ListQuery(context.Background(), Query{Limit: len(ids)})
+		if err != nil {
+			t.Fatalf("ListQuery failed: %v", err)
+		}
+		if len(records) != len(ids) {
+			t.Fatalf("expected %d records, got %d", len(ids), len(records))
+		}
+	}
+	if calls := baseRepo.getCalls(); !reflect.DeepEqual(calls, []string{"List"}) {
+		t.Fatalf("expected a single batch fetch without relisting the page, got %v", calls)
+	}
+}
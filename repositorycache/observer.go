@@ -0,0 +1,310 @@
+package repositorycache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// Observer receives the lifecycle events of a CachedRepository, for logging,
+// auditing or custom side effects. Callbacks run synchronously on the goroutine
+// of the read or write, so they must be fast and safe for concurrent use; fetch
+// events may run on a goroutine detached from the caller. Embed NopObserver to
+// implement only some of them.
+type Observer interface {
+	// OnHit is called for a read answered from the cache.
+	OnHit(ctx context.Context, event ReadEvent)
+	// OnMiss is called when a read fetches from the base repository.
+	OnMiss(ctx context.Context, event ReadEvent)
+	// OnFill is called when a fetched value is handed to the cache.
+	OnFill(ctx context.Context, event ReadEvent)
+	// OnBypass is called for a read passed through to the base repository.
+	OnBypass(ctx context.Context, event BypassEvent)
+	// OnInvalidate is called after a write invalidated cached entries.
+	OnInvalidate(ctx context.Context, event InvalidationEvent)
+	// OnInvalidationError is called when the cache service fails to invalidate
+	// the tags or keys of event.
+	OnInvalidationError(ctx context.Context, event InvalidationEvent, err error)
+}
+
+// ReadEvent describes a cached read of one key.
+type ReadEvent struct {
+	Namespace string
+	Method    ReadMethod
+	Key       string
+}
+
+// BypassReason says why a read was passed through to the base repository.
+type BypassReason string
+
+const (
+	// BypassCriteria marks reads with criteria that cannot be part of a cache
+	// key and no WithCacheKey name.
+	BypassCriteria BypassReason = "criteria"
+	// BypassTx marks reads running inside a transaction.
+	BypassTx BypassReason = "tx"
+	// BypassRaw marks raw SQL reads.
+	BypassRaw BypassReason = "raw"
+	// BypassDisabled marks reads of methods the repository does not cache.
+	BypassDisabled BypassReason = "disabled"
+)
+
+// BypassEvent describes a read passed through to the base repository.
+// Operation is the name of the repository method called, such as "GetByIDTx".
+type BypassEvent struct {
+	Namespace string
+	Operation string
+	Reason    BypassReason
+}
+
+// InvalidationEvent describes cache entries invalidated by a write. Operation is
+// the name of the repository method that wrote, such as "Update". Tags are
+// invalidated through the cache service's tag registry; cache services without
+// one fall back to deleting Keys and every key starting with one of Prefixes.
+type InvalidationEvent struct {
+	Namespace string
+	Operation string
+	Tags      []string
+	Keys      []string
+	Prefixes  []string
+}
+
+// NopObserver implements Observer with callbacks that do nothing.
+type NopObserver struct{}
+
+// OnHit implements Observer.OnHit.
+func (NopObserver) OnHit(context.Context, ReadEvent) {}
+
+// OnMiss implements Observer.OnMiss.
+func (NopObserver) OnMiss(context.Context, ReadEvent) {}
+
+// OnFill implements Observer.OnFill.
+func (NopObserver) OnFill(context.Context, ReadEvent) {}
+
+// OnBypass implements Observer.OnBypass.
+func (NopObserver) OnBypass(context.Context, BypassEvent) {}
+
+// OnInvalidate implements Observer.OnInvalidate.
+func (NopObserver) OnInvalidate(context.Context, InvalidationEvent) {}
+
+// OnInvalidationError implements Observer.OnInvalidationError.
+func (NopObserver) OnInvalidationError(context.Context, InvalidationEvent, error) {}
+
+// AddObserver registers observer for the events of the repository. Observers are
+// called in the order they were added.
+func (c *CachedRepository[T]) AddObserver(observer Observer) {
+	if observer == nil {
+		return
+	}
+	for {
+		current := c.observers.Load()
+		var observers []Observer
+		if current != nil {
+			observers = append(observers, *current...)
+		}
+		observers = append(observers, observer)
+		if c.observers.CompareAndSwap(current, &observers) {
+			return
+		}
+	}
+}
+
+// Observers returns the observers registered on the repository.
+func (c *CachedRepository[T]) Observers() []Observer {
+	if observers := c.observers.Load(); observers != nil {
+		return append([]Observer(nil), *observers...)
+	}
+	return nil
+}
+
+func (c *CachedRepository[T]) observerList() []Observer {
+	if observers := c.observers.Load(); observers != nil {
+		return *observers
+	}
+	return nil
+}
+
+// observeRead wraps fetchFn to report the miss and fill of key and returns a
+// function reporting a hit once the read returns err without fetching.
+func observeRead[T any, R any](ctx context.Context, c *CachedRepository[T], method ReadMethod, key string, fetchFn cache.FetchFn[R]) (cache.FetchFn[R], func(err error)) {
+	observers := c.observerList()
+	if len(observers) == 0 {
+		return fetchFn, func(error) {}
+	}
+	event := ReadEvent{Namespace: c.namespace, Method: method, Key: key}
+	var fetched atomic.Bool
+	observed := func(ctx context.Context) (R, error) {
+		fetched.Store(true)
+		for _, observer := range observers {
+			observer.OnMiss(ctx, event)
+		}
+		value, err := fetchFn(ctx)
+		if err == nil {
+			for _, observer := range observers {
+				observer.OnFill(ctx, event)
+			}
+		}
+		return value, err
+	}
+	return observed, func(err error) {
+		if fetched.Load() || (err != nil && !c.cachedNotFound(err)) {
+			return
+		}
+		for _, observer := range observers {
+			observer.OnHit(ctx, event)
+		}
+	}
+}
+
+// observeBatch wraps fetchFn, which loads the missing IDs of a batch read, to
+// report their misses and fills, and returns a function reporting a hit for every
+// other ID once the batch returns err.
+func observeBatch[T any](ctx context.Context, c *CachedRepository[T], method ReadMethod, ids []string, keyFn func(id string) string, fetchFn cache.BatchFetchFn[T]) (cache.BatchFetchFn[T], func(err error)) {
+	observers := c.observerList()
+	if len(observers) == 0 {
+		return fetchFn, func(error) {}
+	}
+	event := func(id string) ReadEvent {
+		return ReadEvent{Namespace: c.namespace, Method: method, Key: keyFn(id)}
+	}
+	var missed sync.Map
+	observed := func(ctx context.Context, missing []string) (map[string]T, error) {
+		for _, id := range missing {
+			missed.Store(id, struct{}{})
+			for _, observer := range observers {
+				observer.OnMiss(ctx, event(id))
+			}
+		}
+		records, err := fetchFn(ctx, missing)
+		if err == nil {
+			for id := range records {
+				for _, observer := range observers {
+					observer.OnFill(ctx, event(id))
+				}
+			}
+		}
+		return records, err
+	}
+	return observed, func(err error) {
+		if err != nil {
+			return
+		}
+		for _, id := range ids {
+			if _, ok := missed.Load(id); ok {
+				continue
+			}
+			for _, observer := range observers {
+				observer.OnHit(ctx, event(id))
+			}
+		}
+	}
+}
+
+// bypassed reports whether a read of method skips the cache, either because the
+// method is not cached or because opaque reports criteria the key cannot
+// capture, and notifies observers when it does.
+func (c *CachedRepository[T]) bypassed(ctx context.Context, operation string, method ReadMethod, opaque bool) bool {
+	switch {
+	case opaque:
+		c.bypass(ctx, operation, BypassCriteria)
+	case !c.caches(method):
+		c.bypass(ctx, operation, BypassDisabled)
+	default:
+		return false
+	}
+	return true
+}
+
+// bypass notifies observers that a read skipped the cache for reason.
+func (c *CachedRepository[T]) bypass(ctx context.Context, operation string, reason BypassReason) {
+	observers := c.observerList()
+	if len(observers) == 0 {
+		return
+	}
+	event := BypassEvent{Namespace: c.namespace, Operation: operation, Reason: reason}
+	for _, observer := range observers {
+		observer.OnBypass(ctx, event)
+	}
+}
+
+// invalidation collects what one write invalidates, to report it to observers
+// once the write's invalidation is complete.
+type invalidation struct {
+	event    InvalidationEvent
+	failures []invalidationFailure
+	// tagFailure is a failed tag invalidation. Observers always see it, the
+	// invalidation failure policy only when the fallback deleting the same
+	// entries by key and prefix fails too.
+	tagFailure *invalidationFailure
+}
+
+type invalidationFailure struct {
+	event InvalidationEvent
+	err   error
+	// publish marks a failure to publish the invalidation on the bus.
+	publish bool
+}
+
+// startInvalidation begins collecting the invalidation triggered by operation.
+func (c *CachedRepository[T]) startInvalidation(operation string) *invalidation {
+	c.countInvalidation()
+	return &invalidation{event: InvalidationEvent{Namespace: c.namespace, Operation: operation}}
+}
+
+func (inv *invalidation) tags(tags []string) {
+	inv.event.Tags = appendTags(inv.event.Tags, tags)
+}
+
+func (inv *invalidation) key(key string) {
+	inv.event.Keys = appendTag(inv.event.Keys, key)
+}
+
+func (inv *invalidation) prefix(prefix string) {
+	inv.event.Prefixes = appendTag(inv.event.Prefixes, prefix)
+}
+
+// fail records that invalidating the tags, keys or prefixes of failed returned
+// err.
+func (inv *invalidation) fail(failed InvalidationEvent, err error) {
+	failed.Namespace = inv.event.Namespace
+	failed.Operation = inv.event.Operation
+	inv.failures = append(inv.failures, invalidationFailure{event: failed, err: err})
+}
+
+// failTags records that invalidating tags returned err, pending the fallback.
+func (inv *invalidation) failTags(tags []string, err error) {
+	inv.tagFailure = &invalidationFailure{
+		event: InvalidationEvent{Namespace: inv.event.Namespace, Operation: inv.event.Operation, Tags: tags},
+		err:   err,
+	}
+}
+
+// finishInvalidation publishes inv on the invalidation bus, reports it to
+// observers, every failure and then what was invalidated, and returns the error
+// the invalidation failure policy gives the write.
+func (c *CachedRepository[T]) finishInvalidation(ctx context.Context, inv *invalidation) error {
+	failures := inv.failures
+	if inv.tagFailure != nil {
+		failures = append([]invalidationFailure{*inv.tagFailure}, failures...)
+		if len(inv.failures) > 0 {
+			// The fallback did not evict everything the tags cover.
+			inv.failures = failures
+		}
+		inv.tagFailure = nil
+	}
+	c.publishInvalidation(ctx, inv)
+	observers := c.observerList()
+	for _, failure := range failures {
+		for _, observer := range observers {
+			observer.OnInvalidationError(ctx, failure.event, failure.err)
+		}
+	}
+	if event := inv.event; len(event.Tags) > 0 || len(event.Keys) > 0 || len(event.Prefixes) > 0 {
+		for _, observer := range observers {
+			observer.OnInvalidate(ctx, event)
+		}
+	}
+	return c.invalidationFailed(ctx, inv)
+}
@@ -0,0 +1,169 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/uptrace/bun"
+)
+
+// recordingObserver records the events it receives as strings.
+type recordingObserver struct {
+	NopObserver
+	mu            sync.Mutex
+	events        []string
+	invalidations []InvalidationEvent
+	failures      []error
+}
+
+func (o *recordingObserver) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *recordingObserver) recorded() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+func (o *recordingObserver) OnHit(ctx context.Context, event ReadEvent) {
+	o.record("hit:" + string(event.Method))
+}
+
+func (o *recordingObserver) OnMiss(ctx context.Context, event ReadEvent) {
+	o.record("miss:" + string(event.Method))
+}
+
+func (o *recordingObserver) OnFill(ctx context.Context, event ReadEvent) {
+	o.record("fill:" + string(event.Method))
+}
+
+func (o *recordingObserver) OnBypass(ctx context.Context, event BypassEvent) {
+	o.record("bypass:" + event.Operation + ":" + string(event.Reason))
+}
+
+func (o *recordingObserver) OnInvalidate(ctx context.Context, event InvalidationEvent) {
+	o.record("invalidate:" + event.Operation)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.invalidations = append(o.invalidations, event)
+}
+
+func (o *recordingObserver) OnInvalidationError(ctx context.Context, event InvalidationEvent, err error) {
+	o.record("invalidation_error:" + event.Operation)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failures = append(o.failures, err)
+}
+
+func TestCachedRepository_Observer(t *testing.T) {
+	newRepo := func(t *testing.T, cacheService cache.CacheService, opts ...Option) (*CachedRepository[TestUser], *recordingObserver) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			getByIDResult: TestUser{ID: "user-1", Name: "User 1"},
+			listRecords:   []TestUser{{ID: "user-2", Name: "User 2"}},
+			updateResult:  TestUser{ID: "user-1", Name: "Updated"},
+		}
+		observer := &recordingObserver{}
+		opts = append(opts, WithObserver(observer))
+		return NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), opts...), observer
+	}
+	newCache := func(t *testing.T) cache.CacheService {
+		t.Helper()
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		return cacheService
+	}
+	ctx := context.Background()
+
+	t.Run("Reads", func(t *testing.T) {
+		cached, observer := newRepo(t, newCache(t))
+		cached.GetByID(ctx, "user-1")
+		cached.GetByID(ctx, "user-1")
+		cached.GetByIDs(ctx, []string{"user-1", "user-2"})
+
+		want := []string{
+			"miss:GetByID", "fill:GetByID", "hit:GetByID",
+			"miss:GetByID", "fill:GetByID", "hit:GetByID",
+		}
+		if got := observer.recorded(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Bypass", func(t *testing.T) {
+		cached, observer := newRepo(t, newCache(t), WithoutCachedMethods(MethodCount))
+		criteria := repository.SelectRawProcessor(func(q *bun.SelectQuery) *bun.SelectQuery { return q })
+		cached.List(ctx, criteria)
+		cached.Count(ctx)
+		cached.GetByIDTx(ctx, nil, "user-1")
+		func() {
+			// The mock repository does not implement Raw.
+			defer func() { _ = recover() }()
+			cached.Raw(ctx, "SELECT 1")
+		}()
+
+		want := []string{
+			"bypass:List:criteria",
+			"bypass:Count:disabled",
+			"bypass:GetByIDTx:tx",
+			"bypass:Raw:raw",
+		}
+		if got := observer.recorded(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Invalidation", func(t *testing.T) {
+		cached, observer := newRepo(t, newCache(t))
+		if _, err := cached.Update(ctx, TestUser{ID: "user-1", Name: "Updated"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got := observer.recorded(); !reflect.DeepEqual(got, []string{"invalidate:Update"}) {
+			t.Fatalf("expected one invalidation, got %v", got)
+		}
+		event := observer.invalidations[0]
+		idTag, _ := cached.idTag("user-1")
+		if event.Namespace != cached.Namespace() || !containsString(event.Tags, idTag) || len(event.Keys) != 0 {
+			t.Fatalf("expected the record's tags to be invalidated, got %+v", event)
+		}
+	})
+
+	t.Run("InvalidationError", func(t *testing.T) {
+		cacheService := newMockCacheService()
+		cacheService.invalidateTagsErr = errors.New("tag index unavailable")
+		cached, observer := newRepo(t, cacheService)
+		if err := cached.DeleteMany(ctx); err != nil {
+			t.Fatalf("DeleteMany failed: %v", err)
+		}
+
+		want := []string{"invalidation_error:DeleteMany", "invalidate:DeleteMany"}
+		if got := observer.recorded(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		if !errors.Is(observer.failures[0], cacheService.invalidateTagsErr) {
+			t.Fatalf("expected the tag registry error, got %v", observer.failures[0])
+		}
+		if fallback := observer.invalidations[0]; len(fallback.Tags) != 0 || len(fallback.Prefixes) == 0 {
+			t.Fatalf("expected the fallback prefix deletes to be reported, got %+v", fallback)
+		}
+	})
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
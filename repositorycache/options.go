@@ -0,0 +1,271 @@
+package repositorycache
+
+import (
+	"context"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// Option configures a CachedRepository built by NewWithOptions.
+type Option func(*options)
+
+type options struct {
+	identifierFields  []string
+	namespace         string
+	enabledMethods    map[ReadMethod]bool
+	disabledMethods   map[ReadMethod]bool
+	tagDeriver        any
+	clonePolicy       any
+	scopeInvalidation ScopeInvalidationMode
+	coalescing        CoalescingConfig
+	writeThrough      bool
+	normalizedLists   bool
+	ttlPolicy         TTLPolicy
+	negativeCaching   NegativeCaching
+	staleIfError      StaleIfError
+	metrics           cache.Metrics
+	observers         []Observer
+	failurePolicy     *InvalidationFailurePolicy
+	bus               cache.InvalidationBus
+	node              string
+}
+
+// ReadMethod names a cached read for per-method enablement.
+type ReadMethod string
+
+const (
+	// MethodGet covers Get and GetQuery.
+	MethodGet ReadMethod = "Get"
+	// MethodGetByID covers GetByID, GetByIDQuery and GetByIDs.
+	MethodGetByID ReadMethod = "GetByID"
+	// MethodGetByIdentifier covers GetByIdentifier and GetByIdentifierQuery.
+	MethodGetByIdentifier ReadMethod = "GetByIdentifier"
+	// MethodList covers List and ListQuery.
+	MethodList ReadMethod = "List"
+	// MethodCount covers Count and CountQuery.
+	MethodCount ReadMethod = "Count"
+)
+
+// TagDeriver returns extra invalidation tags for a record. Reads register the
+// tags of the records they return and writes invalidate the tags of the records
+// they write, so entries can be evicted through relations the decorator does not
+// know about, such as a shared owner or tenant.
+type TagDeriver[T any] func(ctx context.Context, record T) []string
+
+// NewWithOptions creates a CachedRepository configured by opts. Later options
+// override earlier ones.
+func NewWithOptions[T any](base repository.Repository[T], cacheService cache.CacheService, keySerializer cache.KeySerializer, opts ...Option) *CachedRepository[T] {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+
+	repo := newCachedRepository(base, cacheService, keySerializer, o.identifierFields)
+	if o.namespace != "" {
+		repo.namespace = o.namespace
+	}
+	repo.enabledMethods = o.enabledMethods
+	repo.disabledMethods = o.disabledMethods
+	if deriver, ok := o.tagDeriver.(TagDeriver[T]); ok {
+		repo.tagDeriver = deriver
+	}
+	if policy, ok := o.clonePolicy.(ClonePolicy[T]); ok {
+		repo.SetClonePolicy(policy)
+	}
+	repo.SetScopeInvalidationMode(o.scopeInvalidation)
+	repo.SetGetByIDCoalescing(o.coalescing)
+	repo.SetWriteThrough(o.writeThrough)
+	repo.SetNormalizedLists(o.normalizedLists)
+	// The policy is applied even when the cache service caps some of its
+	// TTLs; SetTTLPolicy reports those to callers that check.
+	_ = repo.SetTTLPolicy(o.ttlPolicy)
+	repo.SetNegativeCaching(o.negativeCaching)
+	repo.SetStaleIfError(o.staleIfError)
+	repo.SetMetrics(o.metrics)
+	for _, observer := range o.observers {
+		repo.AddObserver(observer)
+	}
+	if o.failurePolicy != nil {
+		repo.SetInvalidationFailurePolicy(*o.failurePolicy)
+	}
+	repo.SetInvalidationBus(o.bus, o.node)
+	return repo
+}
+
+// WithIdentifierFields sets the struct fields GetByIdentifier entries are keyed
+// and invalidated by, as NewWithIdentifierFields does.
+func WithIdentifierFields(fields ...string) Option {
+	return func(o *options) {
+		o.identifierFields = append([]string(nil), fields...)
+	}
+}
+
+// WithNamespace overrides the namespace derived from the record type and its
+// package path. Keys and tags of the repository are prefixed with it, so it must
+// be unique among the repositories sharing a cache service.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithCachedMethods caches only the listed reads. Other reads pass through to the
+// base repository.
+func WithCachedMethods(methods ...ReadMethod) Option {
+	return func(o *options) {
+		o.enabledMethods = make(map[ReadMethod]bool, len(methods))
+		for _, method := range methods {
+			o.enabledMethods[method] = true
+		}
+	}
+}
+
+// WithoutCachedMethods passes the listed reads through to the base repository.
+func WithoutCachedMethods(methods ...ReadMethod) Option {
+	return func(o *options) {
+		if o.disabledMethods == nil {
+			o.disabledMethods = make(map[ReadMethod]bool, len(methods))
+		}
+		for _, method := range methods {
+			o.disabledMethods[method] = true
+		}
+	}
+}
+
+// WithTagDeriver registers deriver for repositories of T. Repositories of other
+// types ignore it, so it can be passed as a container default.
+func WithTagDeriver[T any](deriver TagDeriver[T]) Option {
+	return func(o *options) {
+		o.tagDeriver = deriver
+	}
+}
+
+// WithClonePolicy applies policy to repositories of T, as SetClonePolicy does.
+// Repositories of other types ignore it.
+func WithClonePolicy[T any](policy ClonePolicy[T]) Option {
+	return func(o *options) {
+		o.clonePolicy = policy
+	}
+}
+
+// WithScopeInvalidationMode sets the scope invalidation mode, as
+// SetScopeInvalidationMode does.
+func WithScopeInvalidationMode(mode ScopeInvalidationMode) Option {
+	return func(o *options) {
+		o.scopeInvalidation = mode
+	}
+}
+
+// WithGetByIDCoalescing enables GetByID coalescing, as SetGetByIDCoalescing does.
+func WithGetByIDCoalescing(cfg CoalescingConfig) Option {
+	return func(o *options) {
+		o.coalescing = cfg
+	}
+}
+
+// WithWriteThrough enables write-through population, as SetWriteThrough does.
+func WithWriteThrough(enabled bool) Option {
+	return func(o *options) {
+		o.writeThrough = enabled
+	}
+}
+
+// WithNormalizedLists enables normalized List caching, as SetNormalizedLists does.
+func WithNormalizedLists(enabled bool) Option {
+	return func(o *options) {
+		o.normalizedLists = enabled
+	}
+}
+
+// WithTTLPolicy sets entry TTLs, as SetTTLPolicy does, without reporting the TTLs
+// the cache service caps. Call SetTTLPolicy instead to check for ErrTTLExceedsMax.
+func WithTTLPolicy(policy TTLPolicy) Option {
+	return func(o *options) {
+		o.ttlPolicy = policy
+	}
+}
+
+// WithNegativeCaching enables caching of not found errors, as SetNegativeCaching
+// does.
+func WithNegativeCaching(cfg NegativeCaching) Option {
+	return func(o *options) {
+		o.negativeCaching = cfg
+	}
+}
+
+// WithStaleIfError enables serving stale values on refresh errors, as
+// SetStaleIfError does.
+func WithStaleIfError(cfg StaleIfError) Option {
+	return func(o *options) {
+		o.staleIfError = cfg
+	}
+}
+
+// WithMetrics reports reads and invalidations to metrics, as SetMetrics does.
+func WithMetrics(metrics cache.Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// WithObserver registers observer, as AddObserver does. It can be passed more
+// than once.
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		if observer != nil {
+			o.observers = append(o.observers, observer)
+		}
+	}
+}
+
+// WithInvalidationFailurePolicy selects how writes handle invalidation
+// failures, as SetInvalidationFailurePolicy does.
+func WithInvalidationFailurePolicy(policy InvalidationFailurePolicy) Option {
+	return func(o *options) {
+		o.failurePolicy = &policy
+	}
+}
+
+// WithInvalidationBus publishes the invalidations of writes on bus as node, as
+// SetInvalidationBus does.
+func WithInvalidationBus(bus cache.InvalidationBus, node string) Option {
+	return func(o *options) {
+		o.bus = bus
+		o.node = node
+	}
+}
+
+// caches reports whether method is cached.
+func (c *CachedRepository[T]) caches(method ReadMethod) bool {
+	if c.disabledMethods[method] {
+		return false
+	}
+	return c.enabledMethods == nil || c.enabledMethods[method]
+}
+
+// derivedTags returns the tags the tag deriver assigns to records.
+func (c *CachedRepository[T]) derivedTags(ctx context.Context, records ...T) []string {
+	if c.tagDeriver == nil {
+		return nil
+	}
+	var tags []string
+	for _, record := range records {
+		tags = appendTags(tags, c.tagDeriver(ctx, record))
+	}
+	return tags
+}
+
+// derivedResultTags returns the derived tags of a cached read result.
+func (c *CachedRepository[T]) derivedResultTags(ctx context.Context, value any) []string {
+	switch v := value.(type) {
+	case T:
+		return c.derivedTags(ctx, v)
+	case listResult[T]:
+		return c.derivedTags(ctx, v.Records...)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package repositorycache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	newRepo := func(t *testing.T, opts ...Option) (*mockRepository[TestUser], *CachedRepository[TestUser]) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			getByIDResult:  TestUser{ID: "user-1", Name: "owner-1"},
+			getByIDResult2: TestUser{ID: "user-1", Name: "owner-1"},
+			listRecords:    []TestUser{{ID: "user-1", Name: "owner-1"}},
+			listTotal:      1,
+			countResult:    1,
+			updateResult:   TestUser{ID: "user-2", Name: "owner-1"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		return baseRepo, NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), opts...)
+	}
+	ctx := context.Background()
+
+	t.Run("AppliesSettings", func(t *testing.T) {
+		_, cached := newRepo(t,
+			WithIdentifierFields("Name"),
+			WithNamespace("accounts"),
+			WithScopeInvalidationMode(ScopeInvalidationCurrent),
+			WithGetByIDCoalescing(CoalescingConfig{Window: time.Millisecond}),
+			WithWriteThrough(true),
+			WithNormalizedLists(true),
+			WithClonePolicy(ClonePolicy[TestUser]{Mode: CloneOnRead}),
+			WithClonePolicy(ClonePolicy[*TestUser]{Mode: CloneOnWrite}),
+		)
+		if cached.namespace != "accounts" {
+			t.Fatalf("expected namespace override, got %q", cached.namespace)
+		}
+		if len(cached.identifiers) != 1 || cached.identifiers[0] != "Name" {
+			t.Fatalf("expected identifier fields, got %v", cached.identifiers)
+		}
+		if cached.ScopeInvalidationMode() != ScopeInvalidationCurrent || !cached.WriteThrough() || !cached.NormalizedLists() {
+			t.Fatal("expected mode options to be applied")
+		}
+		if cached.GetByIDCoalescing().Window != time.Millisecond {
+			t.Fatal("expected coalescing to be enabled")
+		}
+		if policy := cached.clonePolicy.Load(); policy != nil {
+			t.Fatal("expected a clone policy for another type to be ignored")
+		}
+		if !strings.HasPrefix(cached.key("List"), "accounts") {
+			t.Fatalf("expected keys under the namespace, got %q", cached.key("List"))
+		}
+	})
+
+	t.Run("PerMethodEnablement", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, WithoutCachedMethods(MethodList))
+		for i := 0; i < 2; i++ {
+			cached.List(ctx)
+			cached.GetByID(ctx, "user-1")
+		}
+		if got := countCalls(baseRepo.getCalls(), "List"); got != 2 {
+			t.Fatalf("expected disabled List to pass through, got %d calls", got)
+		}
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 1 {
+			t.Fatalf("expected GetByID to stay cached, got %d calls", got)
+		}
+
+		baseRepo, cached = newRepo(t, WithCachedMethods(MethodCount))
+		for i := 0; i < 2; i++ {
+			cached.Count(ctx)
+			cached.CountQuery(ctx, Query{})
+			cached.GetByIDQuery(ctx, "user-1", Query{})
+		}
+		calls := baseRepo.getCalls()
+		if countCalls(calls, "Count") != 2 || countCalls(calls, "GetByID") != 2 {
+			t.Fatalf("expected only Count reads to be cached, got %v", calls)
+		}
+	})
+
+	t.Run("TagDeriver", func(t *testing.T) {
+		ownerTag := func(ctx context.Context, user TestUser) []string {
+			return []string{"owner:" + user.Name}
+		}
+		baseRepo, cached := newRepo(t, WithTagDeriver(ownerTag), WithScopeInvalidationMode(ScopeInvalidationCurrent))
+		tenantCtx := WithCacheTags(ctx)
+		if _, err := cached.GetByID(tenantCtx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+
+		// user-2 shares the owner of user-1, so writing it evicts user-1.
+		if _, err := cached.Update(ctx, TestUser{ID: "user-2", Name: "owner-1"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		baseRepo.clearCalls()
+		if _, err := cached.GetByID(tenantCtx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if calls := baseRepo.getCalls(); len(calls) != 1 {
+			t.Fatalf("expected derived tag to evict the related record, got %v", calls)
+		}
+	})
+}
@@ -0,0 +1,298 @@
+package repositorycache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/uptrace/bun"
+)
+
+// ErrInvalidQuery is returned when a Query cannot be used as a cache identity.
+var ErrInvalidQuery = errors.New("repositorycache: invalid query")
+
+// Query declaratively describes a filtered read. Unlike SelectCriteria, which are
+// opaque functions, every input of a Query is visible, so the decorator derives a
+// deterministic cache key from it and translates it into criteria for the base
+// repository.
+//
+// Conditions are combined with AND. Their order does not affect the cache key;
+// the order of OrderBy entries does.
+type Query struct {
+	Where   []Condition
+	OrderBy []Order
+	Limit   int
+	Offset  int
+	Deleted DeletedMode
+}
+
+// ConditionOp identifies the comparison a Condition performs.
+type ConditionOp string
+
+const (
+	// OpEq matches rows whose column equals Values[0], or IS NULL when it is nil.
+	OpEq ConditionOp = "eq"
+	// OpIn matches rows whose column is one of Values. An empty list matches nothing.
+	OpIn ConditionOp = "in"
+)
+
+// Condition filters rows on a single column of the repository's table.
+type Condition struct {
+	Column string
+	Op     ConditionOp
+	Values []any
+}
+
+// Eq builds a condition matching column = value.
+func Eq(column string, value any) Condition {
+	return Condition{Column: column, Op: OpEq, Values: []any{value}}
+}
+
+// In builds a condition matching column IN (values...).
+func In(column string, values ...any) Condition {
+	return Condition{Column: column, Op: OpIn, Values: values}
+}
+
+// Order sorts results by a single column.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// Asc sorts by column in ascending order.
+func Asc(column string) Order {
+	return Order{Column: column}
+}
+
+// Desc sorts by column in descending order.
+func Desc(column string) Order {
+	return Order{Column: column, Desc: true}
+}
+
+// DeletedMode controls whether soft-deleted rows are part of the result.
+type DeletedMode int
+
+const (
+	// DeletedExclude applies the model's default soft-delete filter.
+	DeletedExclude DeletedMode = iota
+	// DeletedInclude returns live and soft-deleted rows.
+	DeletedInclude
+	// DeletedOnly returns soft-deleted rows only.
+	DeletedOnly
+)
+
+var queryColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate reports whether the query only uses plain column names and scalar
+// values that serialize unambiguously.
+func (q Query) Validate() error {
+	for _, condition := range q.Where {
+		if !queryColumnPattern.MatchString(condition.Column) {
+			return fmt.Errorf("%w: column %q", ErrInvalidQuery, condition.Column)
+		}
+		switch condition.Op {
+		case OpEq:
+			if len(condition.Values) != 1 {
+				return fmt.Errorf("%w: %s on %q takes exactly one value", ErrInvalidQuery, condition.Op, condition.Column)
+			}
+		case OpIn:
+		default:
+			return fmt.Errorf("%w: unsupported operator %q", ErrInvalidQuery, condition.Op)
+		}
+		for _, value := range condition.Values {
+			if isNilQueryValue(value) {
+				if condition.Op == OpEq {
+					continue
+				}
+				return fmt.Errorf("%w: nil value in %s on %q", ErrInvalidQuery, condition.Op, condition.Column)
+			}
+			if _, ok := queryValueToken(value); !ok {
+				return fmt.Errorf("%w: unsupported value %T for %q", ErrInvalidQuery, value, condition.Column)
+			}
+		}
+	}
+	for _, order := range q.OrderBy {
+		if !queryColumnPattern.MatchString(order.Column) {
+			return fmt.Errorf("%w: order column %q", ErrInvalidQuery, order.Column)
+		}
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("%w: negative limit or offset", ErrInvalidQuery)
+	}
+	switch q.Deleted {
+	case DeletedExclude, DeletedInclude, DeletedOnly:
+	default:
+		return fmt.Errorf("%w: unsupported deleted mode %d", ErrInvalidQuery, q.Deleted)
+	}
+	return nil
+}
+
+// Criteria translates the query into select criteria for the base repository.
+// Columns are qualified with the model's table alias.
+func (q Query) Criteria() []repository.SelectCriteria {
+	query := q.clone()
+	return []repository.SelectCriteria{
+		repository.SelectRawProcessor(func(sq *bun.SelectQuery) *bun.SelectQuery {
+			for _, condition := range query.Where {
+				sq = applyCondition(sq, condition)
+			}
+			for _, order := range query.OrderBy {
+				direction := "ASC"
+				if order.Desc {
+					direction = "DESC"
+				}
+				sq = sq.OrderExpr("?TableAlias.? "+direction, bun.Ident(order.Column))
+			}
+			if query.Limit > 0 {
+				sq = sq.Limit(query.Limit)
+			}
+			if query.Offset > 0 {
+				sq = sq.Offset(query.Offset)
+			}
+			switch query.Deleted {
+			case DeletedInclude:
+				sq = sq.WhereAllWithDeleted()
+			case DeletedOnly:
+				sq = sq.WhereDeleted()
+			}
+			return sq
+		}),
+	}
+}
+
+func applyCondition(sq *bun.SelectQuery, condition Condition) *bun.SelectQuery {
+	column := bun.Ident(condition.Column)
+	switch condition.Op {
+	case OpEq:
+		if isNilQueryValue(condition.Values[0]) {
+			return sq.Where("?TableAlias.? IS NULL", column)
+		}
+		return sq.Where("?TableAlias.? = ?", column, condition.Values[0])
+	case OpIn:
+		if len(condition.Values) == 0 {
+			return sq.Where("1 = 0")
+		}
+		return sq.Where("?TableAlias.? IN (?)", column, bun.In(condition.Values))
+	default:
+		return sq
+	}
+}
+
+// queryKey is the normalized, serializer-friendly form of a Query.
+type queryKey struct {
+	Where   []string
+	OrderBy []string
+	Limit   int
+	Offset  int
+	Deleted DeletedMode
+}
+
+// key returns the normalized cache identity of a validated query. Conditions and
+// IN values are sorted, and every value carries its type so 1 and "1" differ.
+// Values are prefixed with their length, so a string holding the separator cannot
+// pass for several values.
+func (q Query) key() queryKey {
+	where := make([]string, 0, len(q.Where))
+	for _, condition := range q.Where {
+		tokens := make([]string, 0, len(condition.Values))
+		for _, value := range condition.Values {
+			token, _ := queryValueToken(value)
+			tokens = append(tokens, token)
+		}
+		if condition.Op == OpIn {
+			sort.Strings(tokens)
+			tokens = dedupeSorted(tokens)
+		}
+		for i, token := range tokens {
+			tokens[i] = strconv.Itoa(len(token)) + ":" + token
+		}
+		where = append(where, condition.Column+" "+string(condition.Op)+" ("+strings.Join(tokens, ",")+")")
+	}
+	sort.Strings(where)
+
+	orders := make([]string, 0, len(q.OrderBy))
+	for _, order := range q.OrderBy {
+		direction := "asc"
+		if order.Desc {
+			direction = "desc"
+		}
+		orders = append(orders, order.Column+" "+direction)
+	}
+
+	return queryKey{
+		Where:   where,
+		OrderBy: orders,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+		Deleted: q.Deleted,
+	}
+}
+
+func (q Query) clone() Query {
+	cloned := q
+	cloned.Where = make([]Condition, len(q.Where))
+	for i, condition := range q.Where {
+		condition.Values = append([]any(nil), condition.Values...)
+		cloned.Where[i] = condition
+	}
+	cloned.OrderBy = append([]Order(nil), q.OrderBy...)
+	return cloned
+}
+
+// queryValueToken renders a scalar query value with its type. It reports false
+// for values whose identity cannot be captured by a string.
+func queryValueToken(value any) (string, bool) {
+	if isNilQueryValue(value) {
+		return "nil", true
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if t, ok := rv.Interface().(time.Time); ok {
+		return "time.Time:" + t.UTC().Format(time.RFC3339Nano), true
+	}
+	switch rv.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return rv.Type().String() + ":" + fmt.Sprintf("%v", rv.Interface()), true
+	default:
+		return "", false
+	}
+}
+
+func isNilQueryValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return true
+		}
+		rv = rv.Elem()
+	}
+	return false
+}
+
+func dedupeSorted(values []string) []string {
+	if len(values) < 2 {
+		return values
+	}
+	result := values[:1]
+	for _, value := range values[1:] {
+		if value != result[len(result)-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// criteriaRecordingRepository records how many criteria each List call received
+// and runs onList, when set, after reading.
+type criteriaRecordingRepository struct {
+	*mockRepository[TestUser]
+	mu           sync.Mutex
+	listCriteria []int
+	onList       func()
+}
+
+func (r *criteriaRecordingRepository) List(ctx context.Context, criteria ...repository.SelectCriteria) ([]TestUser, int, error) {
+	r.mu.Lock()
+	r.listCriteria = append(r.listCriteria, len(criteria))
+	onList := r.onList
+	r.mu.Unlock()
+	records, total, err := r.mockRepository.List(ctx, criteria...)
+	if onList != nil {
+		onList()
+	}
+	return records, total, err
+}
+
+func TestQuery_Validate(t *testing.T) {
+	now := time.Now()
+	valid := []Query{
+		{},
+		{Where: []Condition{Eq("status", "active"), In("role", "admin", "owner")}},
+		{Where: []Condition{Eq("deleted_at", nil), Eq("created_at", &now), Eq("age", 3)}},
+		{OrderBy: []Order{Desc("created_at"), Asc("id")}, Limit: 10, Offset: 20, Deleted: DeletedInclude},
+		{Where: []Condition{In("id")}},
+	}
+	for i, query := range valid {
+		if err := query.Validate(); err != nil {
+			t.Fatalf("query %d: expected valid, got %v", i, err)
+		}
+	}
+
+	invalid := map[string]Query{
+		"column injection": {Where: []Condition{Eq("status = 1 OR 1", "x")}},
+		"order injection":  {OrderBy: []Order{Asc("id; DROP TABLE users")}},
+		"eq arity":         {Where: []Condition{{Column: "status", Op: OpEq}}},
+		"unknown op":       {Where: []Condition{{Column: "status", Op: "like", Values: []any{"a%"}}}},
+		"struct value":     {Where: []Condition{Eq("status", struct{ A int }{1})}},
+		"func value":       {Where: []Condition{Eq("status", func() {})}},
+		"nil in list":      {Where: []Condition{In("role", "admin", nil)}},
+		"negative limit":   {Limit: -1},
+		"deleted mode":     {Deleted: DeletedMode(9)},
+	}
+	for name, query := range invalid {
+		if err := query.Validate(); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("%s: expected ErrInvalidQuery, got %v", name, err)
+		}
+	}
+}
+
+func TestQuery_KeyIsNormalized(t *testing.T) {
+	a := Query{Where: []Condition{Eq("status", "active"), In("role", "owner", "admin", "owner")}}
+	b := Query{Where: []Condition{In("role", "admin", "owner"), Eq("status", "active")}}
+	if !reflect.DeepEqual(a.key(), b.key()) {
+		t.Fatalf("expected condition and IN order not to matter:\n%v\n%v", a.key(), b.key())
+	}
+
+	typed := Query{Where: []Condition{Eq("age", 1)}}
+	untyped := Query{Where: []Condition{Eq("age", "1")}}
+	if reflect.DeepEqual(typed.key(), untyped.key()) {
+		t.Fatal("expected values of different types to produce different keys")
+	}
+
+	ordered := Query{OrderBy: []Order{Asc("name"), Desc("id")}}
+	reordered := Query{OrderBy: []Order{Desc("id"), Asc("name")}}
+	if reflect.DeepEqual(ordered.key(), reordered.key()) {
+		t.Fatal("expected order-by sequence to be part of the key")
+	}
+
+	serializer := cache.NewDefaultKeySerializer()
+	if serializer.SerializeKey("list", a.key()) != serializer.SerializeKey("list", b.key()) {
+		t.Fatal("expected serialized keys of equivalent queries to match")
+	}
+
+	joined := Query{Where: []Condition{In("name", "a,string:b")}}
+	split := Query{Where: []Condition{In("name", "a", "b")}}
+	if serializer.SerializeKey("list", joined.key()) == serializer.SerializeKey("list", split.key()) {
+		t.Fatal("expected a value holding the separator not to match several values")
+	}
+}
+
+func TestCachedRepository_QueryReads(t *testing.T) {
+	active := Query{Where: []Condition{Eq("status", "active")}, OrderBy: []Order{Desc("created_at")}, Limit: 10}
+	admins := Query{Where: []Condition{In("role", "admin")}}
+
+	newRepo := func(t *testing.T) (*criteriaRecordingRepository, *CachedRepository[TestUser]) {
+		t.Helper()
+		baseRepo := &criteriaRecordingRepository{mockRepository: &mockRepository[TestUser]{
+			listRecords:   []TestUser{{ID: "user-1", Name: "User 1"}},
+			listTotal:     1,
+			countResult:   1,
+			getByIDResult: TestUser{ID: "user-1", Name: "User 1"},
+			updateResult:  TestUser{ID: "user-1", Name: "User 1"},
+		}}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		return baseRepo, New[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer())
+	}
+
+	t.Run("CachesPerQuery", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+		ctx := context.Background()
+
+		for i := 0; i < 2; i++ {
+			if _, _, err := cached.ListQuery(ctx, active); err != nil {
+				t.Fatalf("ListQuery failed: %v", err)
+			}
+		}
+		if _, _, err := cached.ListQuery(ctx, admins); err != nil {
+			t.Fatalf("ListQuery failed: %v", err)
+		}
+
+		if !reflect.DeepEqual(baseRepo.listCriteria, []int{1, 1}) {
+			t.Fatalf("expected one base List per distinct query with translated criteria, got %v", baseRepo.listCriteria)
+		}
+	})
+
+	t.Run("WritesInvalidate", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+		ctx := context.Background()
+
+		if _, _, err := cached.ListQuery(ctx, active); err != nil {
+			t.Fatalf("ListQuery failed: %v", err)
+		}
+		if _, err := cached.CountQuery(ctx, active); err != nil {
+			t.Fatalf("CountQuery failed: %v", err)
+		}
+		if _, err := cached.GetByIDQuery(ctx, "user-1", Query{Deleted: DeletedInclude}); err != nil {
+			t.Fatalf("GetByIDQuery failed: %v", err)
+		}
+		baseRepo.clearCalls()
+
+		if _, err := cached.Update(ctx, TestUser{ID: "user-1", Name: "User 1"}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		if _, _, err := cached.ListQuery(ctx, active); err != nil {
+			t.Fatalf("ListQuery failed: %v", err)
+		}
+		if _, err := cached.CountQuery(ctx, active); err != nil {
+			t.Fatalf("CountQuery failed: %v", err)
+		}
+		if _, err := cached.GetByIDQuery(ctx, "user-1", Query{Deleted: DeletedInclude}); err != nil {
+			t.Fatalf("GetByIDQuery failed: %v", err)
+		}
+
+		calls := baseRepo.getCalls()
+		expected := []string{"Update", "List", "Count", "GetByID"}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Fatalf("expected query reads to refetch after update, got %v", calls)
+		}
+		if len(baseRepo.listCriteria) != 2 {
+			t.Fatalf("expected ListQuery to refetch after update, got %d List calls", len(baseRepo.listCriteria))
+		}
+	})
+
+	t.Run("InvalidQueryDoesNotReachBase", func(t *testing.T) {
+		baseRepo, cached := newRepo(t)
+
+		_, _, err := cached.ListQuery(context.Background(), Query{Where: []Condition{Eq("bad column", 1)}})
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("expected ErrInvalidQuery, got %v", err)
+		}
+		if len(baseRepo.listCriteria) != 0 {
+			t.Fatalf("expected no base call, got %v", baseRepo.listCriteria)
+		}
+	})
+}
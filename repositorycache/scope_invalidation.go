@@ -0,0 +1,64 @@
+package repositorycache
+
+import (
+	"context"
+
+	repository "github.com/goliatone/go-repository-bun"
+)
+
+// ScopeInvalidationMode controls which scoped query caches a write evicts.
+type ScopeInvalidationMode int32
+
+const (
+	// ScopeInvalidationAll evicts Get, List and Count entries cached under every
+	// scope signature in the namespace, including contexts using
+	// WithoutDefaultScopes or a different scope set. It is the default because a
+	// record written under one scope can appear in reads made under any other.
+	// List and Count entries have always been evicted across scopes; Get entries
+	// carry the namespace list tag too so they follow the same rule.
+	ScopeInvalidationAll ScopeInvalidationMode = iota
+
+	// ScopeInvalidationCurrent narrows writes to the writer's scope signature:
+	// they evict every entry cached under it (Get, List, Count, GetByID and
+	// GetByIdentifier alike) plus the ID and identifier entries of the written
+	// records under any scope. Get, List and Count entries of other scopes are
+	// kept, which is narrower than the default ever was. Use it only when scopes
+	// partition the data (for example per tenant) and reads under one scope
+	// never observe writes made under another.
+	ScopeInvalidationCurrent
+)
+
+// String returns the mode name.
+func (m ScopeInvalidationMode) String() string {
+	switch m {
+	case ScopeInvalidationAll:
+		return "all"
+	case ScopeInvalidationCurrent:
+		return "current"
+	default:
+		return "unknown"
+	}
+}
+
+// SetScopeInvalidationMode selects how writes evict scoped query caches.
+// It only affects tag-based invalidation; prefix fallback always clears every scope.
+func (c *CachedRepository[T]) SetScopeInvalidationMode(mode ScopeInvalidationMode) {
+	c.scopeInvalidation.Store(int32(mode))
+}
+
+// ScopeInvalidationMode reports the active scope invalidation mode.
+func (c *CachedRepository[T]) ScopeInvalidationMode() ScopeInvalidationMode {
+	return ScopeInvalidationMode(c.scopeInvalidation.Load())
+}
+
+// queryInvalidationTags returns the tags a write uses to evict Get, List and
+// Count entries. Those reads register both the namespace list tag and their scope
+// tag, so the list tag reaches every scope while the scope tag reaches only the
+// writer's.
+func (c *CachedRepository[T]) queryInvalidationTags(ctx context.Context) []string {
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	if c.ScopeInvalidationMode() == ScopeInvalidationCurrent {
+		return []string{c.scopeTag(signature)}
+	}
+	return []string{c.listTag(), c.scopeTag(signature)}
+}
@@ -0,0 +1,138 @@
+package repositorycache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// StaleIfError configures serving expired entries when their refresh fails.
+type StaleIfError struct {
+	// Grace is how long after expiring an entry can still be served. It is
+	// capped at cache.Config.MaxStale. A zero or negative grace disables
+	// serving stale values.
+	Grace time.Duration
+	// IsTransient reports whether a refresh error allows serving a stale
+	// value. Nil uses IsTransientError.
+	IsTransient func(err error) bool
+}
+
+// SetStaleIfError enables or disables serving stale values on refresh errors.
+//
+// When enabled, a read whose entry expired less than cfg.Grace ago and whose
+// refresh fails with a transient error returns the expired value and a nil error
+// instead of the error. Contexts from WithStaleSignal report when that happens,
+// and StaleServed counts it. The entry stays expired, so every later read retries
+// the refresh until it succeeds or the grace window ends. Entries evicted by a
+// write are never served.
+//
+// Serving stale values requires a cache service implementing
+// cache.OptionsFetcher that keeps expired entries; the default service keeps them
+// for cache.Config.MaxStale. GetByIDs and normalized List records do not serve
+// stale values.
+func (c *CachedRepository[T]) SetStaleIfError(cfg StaleIfError) {
+	if cfg.Grace <= 0 {
+		c.staleIfError.Store(nil)
+		return
+	}
+	if cfg.IsTransient == nil {
+		cfg.IsTransient = IsTransientError
+	}
+	c.staleIfError.Store(&cfg)
+}
+
+// StaleIfError reports the active stale-if-error configuration.
+func (c *CachedRepository[T]) StaleIfError() StaleIfError {
+	cfg := c.staleIfError.Load()
+	if cfg == nil {
+		return StaleIfError{}
+	}
+	return *cfg
+}
+
+// StaleServed returns how many reads were served a stale value.
+func (c *CachedRepository[T]) StaleServed() uint64 {
+	return c.staleServed.Load()
+}
+
+// IsTransientError reports whether err looks like a temporary failure to reach
+// the database: a broken or closed connection, a network error or an expired
+// deadline.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET):
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// StaleSignal reports whether reads made with a context from WithStaleSignal
+// were served stale values. It is safe for concurrent use.
+type StaleSignal struct {
+	mu     sync.Mutex
+	served int
+	err    error
+}
+
+// Served reports whether any read was served a stale value.
+func (s *StaleSignal) Served() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.served > 0
+}
+
+// Count returns how many reads were served a stale value.
+func (s *StaleSignal) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.served
+}
+
+// Err returns the refresh error of the last stale read, or nil.
+func (s *StaleSignal) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *StaleSignal) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.served++
+	s.err = err
+}
+
+type staleSignalContextKey struct{}
+
+// WithStaleSignal returns a context whose reads report stale values to the
+// returned StaleSignal.
+func WithStaleSignal(ctx context.Context) (context.Context, *StaleSignal) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	signal := &StaleSignal{}
+	return context.WithValue(ctx, staleSignalContextKey{}, signal), signal
+}
+
+// servedStale records a read served a stale value after err.
+func (c *CachedRepository[T]) servedStale(ctx context.Context, err error) {
+	c.staleServed.Add(1)
+	if signal, ok := ctx.Value(staleSignalContextKey{}).(*StaleSignal); ok {
+		signal.record(err)
+	}
+}
@@ -0,0 +1,97 @@
+package repositorycache
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+func TestCachedRepository_StaleIfError(t *testing.T) {
+	newRepo := func(t *testing.T, cfg StaleIfError) (*mockRepository[TestUser], *CachedRepository[TestUser]) {
+		t.Helper()
+		config := cache.DefaultConfig()
+		config.EarlyRefresh = nil
+		config.TTL = 20 * time.Millisecond
+		config.MaxStale = time.Minute
+		cacheService, err := cache.NewCacheService(config)
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		baseRepo := &mockRepository[TestUser]{getByIDResult: TestUser{ID: "user-1", Name: "Cached"}}
+		return baseRepo, NewWithOptions[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), WithStaleIfError(cfg))
+	}
+	ctx := context.Background()
+
+	t.Run("ServesStaleOnTransientError", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, StaleIfError{Grace: time.Minute})
+		if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		time.Sleep(40 * time.Millisecond)
+
+		baseRepo.getByIDError = fmt.Errorf("query: %w", driver.ErrBadConn)
+		staleCtx, signal := WithStaleSignal(ctx)
+		user, err := cached.GetByID(staleCtx, "user-1")
+		if err != nil || user.Name != "Cached" {
+			t.Fatalf("expected the stale record, got %+v (%v)", user, err)
+		}
+		if !signal.Served() || signal.Count() != 1 || !errors.Is(signal.Err(), driver.ErrBadConn) {
+			t.Fatalf("expected the signal to report the stale read, got %d (%v)", signal.Count(), signal.Err())
+		}
+		if cached.StaleServed() != 1 {
+			t.Fatalf("expected 1 stale read, got %d", cached.StaleServed())
+		}
+
+		baseRepo.getByIDError = nil
+		baseRepo.getByIDResult = TestUser{ID: "user-1", Name: "Refreshed"}
+		if user, _ := cached.GetByID(ctx, "user-1"); user.Name != "Refreshed" {
+			t.Fatalf("expected the refresh to be retried, got %+v", user)
+		}
+	})
+
+	t.Run("ReturnsOtherErrors", func(t *testing.T) {
+		isTransient := func(err error) bool { return false }
+		baseRepo, cached := newRepo(t, StaleIfError{Grace: time.Minute, IsTransient: isTransient})
+		cached.GetByID(ctx, "user-1")
+		time.Sleep(40 * time.Millisecond)
+
+		baseRepo.getByIDError = driver.ErrBadConn
+		if _, err := cached.GetByID(ctx, "user-1"); !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("expected errors the classifier rejects to be returned, got %v", err)
+		}
+		if cached.StaleServed() != 0 {
+			t.Fatalf("expected no stale reads, got %d", cached.StaleServed())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, StaleIfError{})
+		cached.GetByID(ctx, "user-1")
+		time.Sleep(40 * time.Millisecond)
+
+		baseRepo.getByIDError = driver.ErrBadConn
+		if _, err := cached.GetByID(ctx, "user-1"); !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("expected the refresh error, got %v", err)
+		}
+	})
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := map[error]bool{
+		nil:                        false,
+		errors.New("syntax error"): false,
+		driver.ErrBadConn:          true,
+		context.DeadlineExceeded:   true,
+		fmt.Errorf("dial: %w", driver.ErrBadConn): true,
+	}
+	for err, want := range tests {
+		if got := IsTransientError(err); got != want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package repositorycache
 
 import (
 	"context"
+	"sync/atomic"
 )
 
 type cacheTagsContextKey struct{}
@@ -34,3 +35,49 @@ func cacheTagsFromContext(ctx context.Context) []string {
 	}
 	return nil
 }
+
+type cacheKeyContextKey struct{}
+
+// cacheKeyName is the caller-supplied identity attached by WithCacheKey. used
+// is set by the read that takes it, so the name names a single read.
+type cacheKeyName struct {
+	name string
+	tags []string
+	used atomic.Bool
+}
+
+// WithCacheKey names the next read performed with the returned context so the
+// decorator caches it even when SelectCriteria are present. The caller vouches
+// that name identifies the criteria: two reads using the same name share a cached
+// result. The entry is keyed under the repository namespace and read method, and
+// registered under the list and scope tags plus tags, so writes invalidate it like
+// any other List entry. An empty name leaves ctx unchanged.
+//
+// The name is consumed by the first read made with the returned context or
+// a context derived from it; later reads with that context are not named, so they
+// cannot reuse the entry by accident. Call WithCacheKey again for each read.
+func WithCacheKey(ctx context.Context, name string, tags ...string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheKeyContextKey{}, &cacheKeyName{
+		name: name,
+		tags: dedupeStrings(tags),
+	})
+}
+
+// takeCacheKey returns the name attached by WithCacheKey unless a read already
+// took it.
+func takeCacheKey(ctx context.Context) (*cacheKeyName, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	named, ok := ctx.Value(cacheKeyContextKey{}).(*cacheKeyName)
+	if !ok || !named.used.CompareAndSwap(false, true) {
+		return nil, false
+	}
+	return named, true
+}
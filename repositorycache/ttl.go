@@ -0,0 +1,93 @@
+package repositorycache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// ErrTTLExceedsMax is returned by SetTTLPolicy for TTLs longer than the cache
+// service's MaxTTL, which caps them.
+var ErrTTLExceedsMax = errors.New("repositorycache: TTL exceeds the cache service's MaxTTL")
+
+// TTLPolicy sets how long the entries of a repository live.
+type TTLPolicy struct {
+	// Default applies to every read without a method TTL. Zero uses the cache
+	// service's default TTL.
+	Default time.Duration
+	// Methods overrides Default per read method.
+	Methods map[ReadMethod]time.Duration
+}
+
+// SetTTLPolicy sets the TTLs of the entries the repository caches from now on.
+// Entries cached by GetByIDs, normalized lists and write-through follow the TTL
+// of the method whose keys they share: GetByID, List or GetByIdentifier.
+//
+// TTLs require a cache service implementing cache.OptionsFetcher, and
+// cache.EntryWriter for write-through; with other services, or services that do
+// not support per-entry TTLs, entries keep the default TTL. The default cache
+// service caps TTLs at cache.Config.MaxTTL, which is Config.TTL when unset.
+//
+// The policy is applied either way, but for a cache service implementing
+// cache.TTLReporter SetTTLPolicy returns an error wrapping ErrTTLExceedsMax
+// naming the TTLs the service caps; raise Config.MaxTTL to honor them.
+// WithTTLPolicy drops that error, so check it with SetTTLPolicy.
+func (c *CachedRepository[T]) SetTTLPolicy(policy TTLPolicy) error {
+	if policy.Default <= 0 && len(policy.Methods) == 0 {
+		c.ttlPolicy.Store(nil)
+		return nil
+	}
+	policy.Methods = maps.Clone(policy.Methods)
+	c.ttlPolicy.Store(&policy)
+	return c.cappedTTLs(policy)
+}
+
+// cappedTTLs returns an error naming the TTLs of policy the cache service caps,
+// or nil.
+func (c *CachedRepository[T]) cappedTTLs(policy TTLPolicy) error {
+	reporter, ok := c.cache.(cache.TTLReporter)
+	if !ok {
+		return nil
+	}
+	limit := reporter.MaxTTL()
+	var capped []string
+	if policy.Default > limit {
+		capped = append(capped, fmt.Sprintf("default %v", policy.Default))
+	}
+	for _, method := range slices.Sorted(maps.Keys(policy.Methods)) {
+		if ttl := policy.Methods[method]; ttl > limit {
+			capped = append(capped, fmt.Sprintf("%s %v", method, ttl))
+		}
+	}
+	if len(capped) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s capped at %v", ErrTTLExceedsMax, strings.Join(capped, ", "), limit)
+}
+
+// TTLPolicy reports the active TTL policy.
+func (c *CachedRepository[T]) TTLPolicy() TTLPolicy {
+	policy := c.ttlPolicy.Load()
+	if policy == nil {
+		return TTLPolicy{}
+	}
+	return TTLPolicy{Default: policy.Default, Methods: maps.Clone(policy.Methods)}
+}
+
+// methodTTL returns the TTL of entries cached by method. Zero means the cache
+// service's default.
+func (c *CachedRepository[T]) methodTTL(method ReadMethod) time.Duration {
+	policy := c.ttlPolicy.Load()
+	if policy == nil {
+		return 0
+	}
+	if ttl, ok := policy.Methods[method]; ok && ttl > 0 {
+		return ttl
+	}
+	return policy.Default
+}
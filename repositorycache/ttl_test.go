@@ -0,0 +1,144 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// ttlRecordingCache records the TTL each key is cached with.
+type ttlRecordingCache struct {
+	cache.CacheService
+
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (c *ttlRecordingCache) record(key string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttls[key] = ttl
+}
+
+func (c *ttlRecordingCache) ttlOf(prefix string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, ttl := range c.ttls {
+		if strings.HasPrefix(key, prefix) {
+			return ttl, true
+		}
+	}
+	return 0, false
+}
+
+func (c *ttlRecordingCache) GetOrFetchWithOptions(ctx context.Context, key string, opts cache.EntryOptions, fetchFn any) (any, error) {
+	c.record(key, opts.TTL)
+	return c.CacheService.(cache.OptionsFetcher).GetOrFetchWithOptions(ctx, key, opts, fetchFn)
+}
+
+func (c *ttlRecordingCache) Set(ctx context.Context, key string, value any, opts cache.EntryOptions) error {
+	c.record(key, opts.TTL)
+	return c.CacheService.(cache.EntryWriter).Set(ctx, key, value, opts)
+}
+
+func (c *ttlRecordingCache) WatchTags(tags []string) func() bool {
+	return c.CacheService.(cache.TagWatcher).WatchTags(tags)
+}
+
+func (c *ttlRecordingCache) WatchNamespace(namespace string) func() bool {
+	return c.CacheService.(cache.TagWatcher).WatchNamespace(namespace)
+}
+
+func TestCachedRepository_TTLPolicy(t *testing.T) {
+	service, err := cache.NewCacheService(cache.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	recorder := &ttlRecordingCache{CacheService: service, ttls: make(map[string]time.Duration)}
+	baseRepo := &mockRepository[TestUser]{
+		getByIDResult:  TestUser{ID: "user-1"},
+		getByIDResult2: TestUser{ID: "user-1"},
+		listRecords:    []TestUser{{ID: "user-2"}},
+		listTotal:      1,
+		countResult:    1,
+		createResult:   TestUser{ID: "user-3"},
+	}
+	cached := NewWithOptions[TestUser](baseRepo, recorder, cache.NewDefaultKeySerializer(),
+		WithWriteThrough(true),
+		WithTTLPolicy(TTLPolicy{
+			Default: time.Hour,
+			Methods: map[ReadMethod]time.Duration{MethodList: 5 * time.Second},
+		}),
+	)
+	ctx := context.Background()
+
+	cached.GetByID(ctx, "user-1")
+	cached.List(ctx)
+	cached.GetByIDs(ctx, []string{"user-2"})
+	cached.Create(ctx, TestUser{ID: "user-3"})
+
+	expected := map[string]time.Duration{
+		cached.key("GetByID"): time.Hour,
+		cached.key("List"):    5 * time.Second,
+	}
+	for prefix, want := range expected {
+		if got, ok := recorder.ttlOf(prefix); !ok || got != want {
+			t.Errorf("expected %q to be cached for %v, got %v", prefix, want, got)
+		}
+	}
+	for _, id := range []string{"user-2", "user-3"} {
+		key := cached.recordKey("GetByID", id, cached.scopeSignature(ctx, repository.ScopeOperationSelect))
+		if got, ok := recorder.ttlOf(key); !ok || got != time.Hour {
+			t.Errorf("expected %q to use the GetByID TTL, got %v", key, got)
+		}
+	}
+
+	cached.SetTTLPolicy(TTLPolicy{})
+	if policy := cached.TTLPolicy(); policy.Default != 0 || policy.Methods != nil {
+		t.Fatalf("expected zero policy to reset TTLs, got %+v", policy)
+	}
+	cached.Count(ctx)
+	if got, ok := recorder.ttlOf(cached.key("Count")); !ok || got != 0 {
+		t.Errorf("expected Count to use the default TTL, got %v", got)
+	}
+}
+
+func TestCachedRepository_TTLPolicyExceedingMaxTTL(t *testing.T) {
+	config := cache.DefaultConfig()
+	service, err := cache.NewCacheService(config)
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached := New[TestUser](&mockRepository[TestUser]{}, service, cache.NewDefaultKeySerializer())
+
+	policy := TTLPolicy{
+		Default: config.TTL / 2,
+		Methods: map[ReadMethod]time.Duration{MethodGetByID: 2 * config.TTL, MethodList: config.TTL},
+	}
+	err = cached.SetTTLPolicy(policy)
+	if !errors.Is(err, ErrTTLExceedsMax) {
+		t.Fatalf("expected ErrTTLExceedsMax, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, string(MethodGetByID)) || strings.Contains(msg, string(MethodList)) {
+		t.Fatalf("expected only the GetByID TTL to be named, got %q", msg)
+	}
+	if got := cached.TTLPolicy().Methods[MethodGetByID]; got != 2*config.TTL {
+		t.Fatalf("expected the policy to be applied anyway, got %v", got)
+	}
+
+	config.MaxTTL = 2 * config.TTL
+	service, err = cache.NewCacheService(config)
+	if err != nil {
+		t.Fatalf("failed to create cache service: %v", err)
+	}
+	cached = New[TestUser](&mockRepository[TestUser]{}, service, cache.NewDefaultKeySerializer())
+	if err := cached.SetTTLPolicy(policy); err != nil {
+		t.Fatalf("expected TTLs within MaxTTL to be accepted, got %v", err)
+	}
+}
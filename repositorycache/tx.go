@@ -0,0 +1,135 @@
+package repositorycache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/uptrace/bun"
+)
+
+// TxRunner is implemented by *bun.DB, bun.Tx and bun.Conn.
+type TxRunner interface {
+	RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error) error
+}
+
+type txInvalidationsContextKey struct{}
+
+// TxInvalidations collects cache invalidations produced by *Tx writes so they
+// can run once the surrounding transaction commits.
+type TxInvalidations struct {
+	mu      sync.Mutex
+	pending []func() error
+	closed  bool
+}
+
+// DeferInvalidations attaches a TxInvalidations collector to the context.
+// *Tx writes issued with the returned context record their invalidations on the
+// collector instead of running them right away. Call Flush after the
+// transaction commits and Discard after it rolls back.
+//
+// Use RunInTx when the transaction is managed through bun's RunInTx.
+func DeferInvalidations(ctx context.Context) (context.Context, *TxInvalidations) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pending := &TxInvalidations{}
+	return context.WithValue(ctx, txInvalidationsContextKey{}, pending), pending
+}
+
+// RunInTx runs fn inside a transaction started on db and defers the cache
+// invalidations of every *Tx write made with the callback context until the
+// transaction commits. Invalidations are dropped when fn returns an error or
+// the commit fails.
+//
+// Nested calls hand their invalidations to the enclosing RunInTx, so nothing is
+// evicted before the outermost transaction commits. After a commit RunInTx
+// returns the invalidation errors the repositories' failure policies surface.
+func RunInTx(ctx context.Context, db TxRunner, fn func(ctx context.Context, tx bun.Tx) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := txInvalidationsFromContext(ctx)
+	txCtx, pending := DeferInvalidations(ctx)
+
+	if err := db.RunInTx(txCtx, nil, fn); err != nil {
+		pending.Discard()
+		return err
+	}
+
+	if parent != nil {
+		parent.adopt(pending)
+		return nil
+	}
+
+	return pending.Flush()
+}
+
+// Flush runs the recorded invalidations in the order they were recorded.
+// Invalidations recorded after Flush run immediately.
+func (p *TxInvalidations) Flush() error {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	var errs []error
+	for _, invalidate := range pending {
+		if err := invalidate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Discard drops the recorded invalidations without running them.
+// Invalidations recorded after Discard run immediately.
+func (p *TxInvalidations) Discard() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = nil
+	p.closed = true
+}
+
+// Len reports how many invalidations are waiting for the transaction outcome.
+func (p *TxInvalidations) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.pending)
+}
+
+// add records an invalidation. It reports false when the collector has already
+// been flushed or discarded and the caller should invalidate immediately.
+func (p *TxInvalidations) add(invalidate func() error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.pending = append(p.pending, invalidate)
+	return true
+}
+
+func (p *TxInvalidations) adopt(child *TxInvalidations) {
+	child.mu.Lock()
+	pending := child.pending
+	child.pending = nil
+	child.closed = true
+	child.mu.Unlock()
+
+	for _, invalidate := range pending {
+		if !p.add(invalidate) {
+			_ = invalidate()
+		}
+	}
+}
+
+func txInvalidationsFromContext(ctx context.Context) *TxInvalidations {
+	if ctx == nil {
+		return nil
+	}
+	pending, _ := ctx.Value(txInvalidationsContextKey{}).(*TxInvalidations)
+	return pending
+}
@@ -0,0 +1,208 @@
+package repositorycache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/goliatone/go-repository-cache/cache"
+	"github.com/uptrace/bun"
+)
+
+// fakeTxRunner runs the callback with a zero bun.Tx and reports commitErr as the
+// outcome of the commit.
+type fakeTxRunner struct {
+	commitErr error
+	calls     int
+}
+
+func (f *fakeTxRunner) RunInTx(ctx context.Context, _ *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error) error {
+	f.calls++
+	if err := fn(ctx, bun.Tx{}); err != nil {
+		return err
+	}
+	return f.commitErr
+}
+
+func countInvalidateTagCalls(calls []string) int {
+	count := 0
+	for _, call := range calls {
+		if strings.HasPrefix(call, "InvalidateTags:") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRunInTx_DefersInvalidationUntilCommit(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{}
+	baseRepo.getByIDResult = TestUser{ID: "user-1", Name: "Original"}
+	baseRepo.updateResult = TestUser{ID: "user-1", Name: "Updated"}
+	cacheService := newMockCacheService()
+	cached := New(baseRepo, cacheService, cache.NewDefaultKeySerializer())
+
+	ctx := context.Background()
+	if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	key := cached.key("GetByID", "user-1")
+
+	runner := &fakeTxRunner{}
+	err := RunInTx(ctx, runner, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := cached.UpdateTx(ctx, tx, baseRepo.updateResult); err != nil {
+			return err
+		}
+		if n := countInvalidateTagCalls(cacheService.getCalls()); n != 0 {
+			t.Fatalf("expected no invalidation before commit, got %d", n)
+		}
+		if got := txInvalidationsFromContext(ctx).Len(); got != 1 {
+			t.Fatalf("expected 1 pending invalidation, got %d", got)
+		}
+		cacheService.mu.Lock()
+		_, cachedBeforeCommit := cacheService.storage[key]
+		cacheService.mu.Unlock()
+		if !cachedBeforeCommit {
+			t.Fatalf("expected GetByID entry to survive until commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTx failed: %v", err)
+	}
+
+	if n := countInvalidateTagCalls(cacheService.getCalls()); n != 1 {
+		t.Fatalf("expected 1 invalidation after commit, got %d", n)
+	}
+	cacheService.mu.Lock()
+	_, exists := cacheService.storage[key]
+	cacheService.mu.Unlock()
+	if exists {
+		t.Fatalf("expected GetByID entry to be invalidated after commit")
+	}
+}
+
+func TestRunInTx_DropsInvalidationOnRollback(t *testing.T) {
+	rollback := errors.New("rollback")
+
+	tests := []struct {
+		name     string
+		runner   *fakeTxRunner
+		callback error
+		wantErr  error
+	}{
+		{name: "CallbackError", runner: &fakeTxRunner{}, callback: rollback, wantErr: rollback},
+		{name: "CommitError", runner: &fakeTxRunner{commitErr: sql.ErrTxDone}, wantErr: sql.ErrTxDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseRepo := &mockRepository[TestUser]{}
+			baseRepo.getByIDResult = TestUser{ID: "user-1", Name: "Original"}
+			cacheService := newMockCacheService()
+			cached := New(baseRepo, cacheService, cache.NewDefaultKeySerializer())
+
+			ctx := context.Background()
+			if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+				t.Fatalf("GetByID failed: %v", err)
+			}
+
+			err := RunInTx(ctx, tt.runner, func(ctx context.Context, tx bun.Tx) error {
+				if err := cached.DeleteTx(ctx, tx, TestUser{ID: "user-1"}); err != nil {
+					return err
+				}
+				return tt.callback
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RunInTx error = %v, want %v", err, tt.wantErr)
+			}
+
+			if n := countInvalidateTagCalls(cacheService.getCalls()); n != 0 {
+				t.Fatalf("expected rolled back transaction to skip invalidation, got %d calls", n)
+			}
+
+			cacheService.mu.Lock()
+			_, exists := cacheService.storage[cached.key("GetByID", "user-1")]
+			cacheService.mu.Unlock()
+			if !exists {
+				t.Fatalf("expected GetByID entry to survive the rollback")
+			}
+		})
+	}
+}
+
+func TestRunInTx_NestedDefersToOutermostCommit(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{}
+	baseRepo.updateResult = TestUser{ID: "user-1", Name: "Updated"}
+	cacheService := newMockCacheService()
+	cached := New(baseRepo, cacheService, cache.NewDefaultKeySerializer())
+
+	outer := &fakeTxRunner{}
+	inner := &fakeTxRunner{}
+	err := RunInTx(context.Background(), outer, func(ctx context.Context, tx bun.Tx) error {
+		if err := RunInTx(ctx, inner, func(ctx context.Context, tx bun.Tx) error {
+			_, err := cached.UpdateTx(ctx, tx, baseRepo.updateResult)
+			return err
+		}); err != nil {
+			return err
+		}
+		if n := countInvalidateTagCalls(cacheService.getCalls()); n != 0 {
+			t.Fatalf("expected nested commit to wait for the outer transaction, got %d calls", n)
+		}
+		if got := txInvalidationsFromContext(ctx).Len(); got != 1 {
+			t.Fatalf("expected outer collector to adopt 1 invalidation, got %d", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTx failed: %v", err)
+	}
+	if n := countInvalidateTagCalls(cacheService.getCalls()); n != 1 {
+		t.Fatalf("expected 1 invalidation after outer commit, got %d", n)
+	}
+}
+
+func TestDeferInvalidations_ManualFlush(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{}
+	baseRepo.updateResult = TestUser{ID: "user-1", Name: "Updated"}
+	cacheService := newMockCacheService()
+	cached := New(baseRepo, cacheService, cache.NewDefaultKeySerializer())
+
+	ctx, pending := DeferInvalidations(context.Background())
+	if _, err := cached.UpdateTx(ctx, nil, baseRepo.updateResult); err != nil {
+		t.Fatalf("UpdateTx failed: %v", err)
+	}
+	if pending.Len() != 1 {
+		t.Fatalf("expected 1 pending invalidation, got %d", pending.Len())
+	}
+
+	if err := pending.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n := countInvalidateTagCalls(cacheService.getCalls()); n != 1 {
+		t.Fatalf("expected 1 invalidation after Flush, got %d", n)
+	}
+
+	// Writes after the collector is closed invalidate right away.
+	if _, err := cached.UpdateTx(ctx, nil, baseRepo.updateResult); err != nil {
+		t.Fatalf("UpdateTx failed: %v", err)
+	}
+	if n := countInvalidateTagCalls(cacheService.getCalls()); n != 2 {
+		t.Fatalf("expected immediate invalidation after Flush, got %d calls", n)
+	}
+}
+
+func TestTxWritesWithoutCollectorInvalidateImmediately(t *testing.T) {
+	baseRepo := &mockRepository[TestUser]{}
+	baseRepo.updateResult = TestUser{ID: "user-1", Name: "Updated"}
+	cacheService := newMockCacheService()
+	cached := New(baseRepo, cacheService, cache.NewDefaultKeySerializer())
+
+	if _, err := cached.UpdateTx(context.Background(), nil, baseRepo.updateResult); err != nil {
+		t.Fatalf("UpdateTx failed: %v", err)
+	}
+	if n := countInvalidateTagCalls(cacheService.getCalls()); n != 1 {
+		t.Fatalf("expected immediate invalidation without collector, got %d calls", n)
+	}
+}
@@ -0,0 +1,128 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// SetWriteThrough enables or disables write-through population.
+//
+// When enabled, Create, Update, Upsert, GetOrCreate and their Many variants store
+// every returned record under its GetByID key and the GetByIdentifier key of each
+// configured identifier, right after the write's invalidation. Entries use the
+// writer's scope signature and are registered under the same tags as the reads
+// that would have cached them, so later writes evict them as usual. List and Count
+// entries are still invalidated.
+//
+// *Tx writes populate only after the transaction commits, through RunInTx or
+// DeferInvalidations; without a collector they only invalidate. A record is not
+// populated when another write invalidates it after this write's invalidation,
+// so a slower writer cannot replace a newer version. Entries that fail to be
+// stored are deleted and handled by the invalidation failure policy. Population
+// requires a cache service implementing cache.EntryWriter and cache.TagWatcher
+// and is skipped otherwise.
+//
+// Enable it only when the records returned by writes match what GetByID returns
+// under the writer's scopes, for example when GetByID loads no extra relations.
+func (c *CachedRepository[T]) SetWriteThrough(enabled bool) {
+	c.writeThrough.Store(enabled)
+}
+
+// WriteThrough reports whether write-through population is enabled.
+func (c *CachedRepository[T]) WriteThrough() bool {
+	return c.writeThrough.Load()
+}
+
+// populateRecords stores written records under their GetByID and GetByIdentifier
+// keys when write-through is enabled. A record whose ID tag is invalidated after
+// operation's own invalidation is left out, since a later write may have stored
+// a newer version. Entries that fail to be stored are deleted and reported like
+// invalidation failures, and the failure policy gives the error returned.
+func (c *CachedRepository[T]) populateRecords(ctx context.Context, operation string, records ...T) error {
+	if len(records) == 0 || !c.WriteThrough() {
+		return nil
+	}
+	writer, ok := c.cache.(cache.EntryWriter)
+	if !ok {
+		return nil
+	}
+	watcher, ok := c.cache.(cache.TagWatcher)
+	if !ok {
+		return nil
+	}
+
+	inv := &invalidation{event: InvalidationEvent{Namespace: c.namespace, Operation: operation}}
+	signature := c.scopeSignature(ctx, repository.ScopeOperationSelect)
+	scopeTag := c.scopeTag(signature)
+	policy := c.clonePolicy.Load()
+	set := func(method ReadMethod, key string, record T, tags []string, invalidated func() bool) bool {
+		if invalidated() {
+			return false
+		}
+		value, err := policy.stored(key, record, tags)
+		if err == nil {
+			err = writer.Set(ctx, key, value, cache.EntryOptions{Tags: tags, TTL: c.methodTTL(method)})
+		}
+		if err == nil && !invalidated() {
+			return true
+		}
+		// The entry failed to be stored or raced with a later write.
+		deleteErr := c.cache.Delete(ctx, key)
+		if err != nil || deleteErr != nil {
+			inv.fail(InvalidationEvent{Keys: []string{key}}, errors.Join(err, deleteErr))
+		}
+		return false
+	}
+	for _, record := range records {
+		id, err := c.extractID(record)
+		if err != nil {
+			continue
+		}
+		idTag, ok := c.idTag(id)
+		if !ok {
+			continue
+		}
+		invalidated := watcher.WatchTags([]string{idTag})
+		derived := c.derivedTags(ctx, record)
+		tags := c.readTags(ctx, appendTags([]string{scopeTag, idTag}, derived))
+		if !set(MethodGetByID, c.recordKey("GetByID", id, signature), record, tags, invalidated) {
+			continue
+		}
+
+		identifiers, err := c.extractIdentifierValues(record)
+		if err != nil {
+			continue
+		}
+		for _, identifier := range identifiers {
+			tag, ok := c.identifierTag(identifier)
+			if !ok {
+				continue
+			}
+			tags := c.readTags(ctx, appendTags([]string{scopeTag, tag, idTag}, derived))
+			if set(MethodGetByIdentifier, c.recordKey("GetByIdentifier", identifier, signature), record, tags, invalidated) {
+				c.identifierIndex.remember(id, identifier, c.identifierRetention())
+			}
+		}
+	}
+	if len(inv.failures) == 0 {
+		return nil
+	}
+	for _, failure := range inv.failures {
+		for _, observer := range c.observerList() {
+			observer.OnInvalidationError(ctx, failure.event, failure.err)
+		}
+	}
+	return c.invalidationFailed(ctx, inv)
+}
+
+// joinPopulateError returns the error of a write whose invalidation returned err
+// and whose population returned populateErr.
+func joinPopulateError(err, populateErr error) error {
+	if populateErr == nil {
+		return err
+	}
+	return errors.Join(err, populateErr)
+}
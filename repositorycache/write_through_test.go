@@ -0,0 +1,218 @@
+package repositorycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	repository "github.com/goliatone/go-repository-bun"
+	"github.com/goliatone/go-repository-cache/cache"
+)
+
+// writeThroughCacheService is the set of capabilities write-through uses.
+type writeThroughCacheService interface {
+	cache.CacheService
+	cache.TagRegistry
+	cache.OptionsFetcher
+	cache.EntryWriter
+	cache.TagWatcher
+}
+
+// hookedWriterCache runs beforeSet ahead of every Set and fails it with setErr
+// when set.
+type hookedWriterCache struct {
+	writeThroughCacheService
+	beforeSet func(key string)
+	setErr    error
+}
+
+func (h *hookedWriterCache) Set(ctx context.Context, key string, value any, opts cache.EntryOptions) error {
+	if h.beforeSet != nil {
+		h.beforeSet(key)
+	}
+	if h.setErr != nil {
+		return h.setErr
+	}
+	return h.writeThroughCacheService.Set(ctx, key, value, opts)
+}
+
+func TestWriteThrough(t *testing.T) {
+	written := TestUser{ID: "user-1", Name: "written"}
+
+	newHookedRepo := func(t *testing.T) (*mockRepository[TestUser], *CachedRepository[TestUser], *hookedWriterCache) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			updateResult:  written,
+			getByIDResult: TestUser{ID: "user-1", Name: "from base"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		hooked := &hookedWriterCache{writeThroughCacheService: cacheService.(writeThroughCacheService)}
+		cached := New[TestUser](baseRepo, hooked, cache.NewDefaultKeySerializer())
+		cached.SetWriteThrough(true)
+		return baseRepo, cached, hooked
+	}
+
+	newRepo := func(t *testing.T, enabled bool) (*mockRepository[TestUser], *CachedRepository[TestUser]) {
+		t.Helper()
+		baseRepo := &mockRepository[TestUser]{
+			createResult:  written,
+			updateResult:  written,
+			listRecords:   []TestUser{written},
+			listTotal:     1,
+			getByIDResult: TestUser{ID: "user-1", Name: "from base"},
+		}
+		cacheService, err := cache.NewCacheService(cache.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create cache service: %v", err)
+		}
+		cached := NewWithIdentifierFields[TestUser](baseRepo, cacheService, cache.NewDefaultKeySerializer(), "Name")
+		cached.SetWriteThrough(enabled)
+		return baseRepo, cached
+	}
+
+	t.Run("PopulatesRecordKeys", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, true)
+		ctx := context.Background()
+
+		if _, _, err := cached.List(ctx); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if _, err := cached.Update(ctx, written); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		baseRepo.clearCalls()
+
+		byID, err := cached.GetByID(ctx, "user-1")
+		if err != nil || byID.Name != "written" {
+			t.Fatalf("expected GetByID to return the written record, got %+v (%v)", byID, err)
+		}
+		byIdentifier, err := cached.GetByIdentifier(ctx, "written")
+		if err != nil || byIdentifier.Name != "written" {
+			t.Fatalf("expected GetByIdentifier to return the written record, got %+v (%v)", byIdentifier, err)
+		}
+		if _, _, err := cached.List(ctx); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if calls := baseRepo.getCalls(); len(calls) != 1 || calls[0] != "List" {
+			t.Fatalf("expected only the invalidated List to reach the base, got %v", calls)
+		}
+	})
+
+	t.Run("RespectsScopeSignature", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, true)
+		ctx := context.Background()
+		tenantCtx := repository.WithScopeData(repository.WithSelectScopes(ctx, "tenant"), "tenant", "tenant-a")
+
+		if _, err := cached.Create(tenantCtx, written); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		baseRepo.clearCalls()
+
+		if _, err := cached.GetByID(tenantCtx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if calls := baseRepo.getCalls(); len(calls) != 0 {
+			t.Fatalf("expected the writer's scope to be populated, got %v", calls)
+		}
+		if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if calls := baseRepo.getCalls(); len(calls) != 1 {
+			t.Fatalf("expected other scopes to read through, got %v", calls)
+		}
+	})
+
+	t.Run("TxWritesPopulateAfterCommit", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, true)
+
+		if _, err := cached.UpdateTx(context.Background(), nil, written); err != nil {
+			t.Fatalf("UpdateTx failed: %v", err)
+		}
+		if _, err := cached.GetByID(context.Background(), "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 1 {
+			t.Fatalf("expected a Tx write without a collector not to populate, got %d GetByID calls", got)
+		}
+
+		baseRepo.clearCalls()
+		ctx, pending := DeferInvalidations(context.Background())
+		if _, err := cached.UpdateTx(ctx, nil, written); err != nil {
+			t.Fatalf("UpdateTx failed: %v", err)
+		}
+		if err := pending.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		user, err := cached.GetByID(context.Background(), "user-1")
+		if err != nil || user.Name != "written" {
+			t.Fatalf("expected committed write to be populated, got %+v (%v)", user, err)
+		}
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 0 {
+			t.Fatalf("expected GetByID to hit the populated entry, got %d calls", got)
+		}
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		baseRepo, cached := newRepo(t, false)
+		ctx := context.Background()
+
+		if _, err := cached.Update(ctx, written); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if _, err := cached.GetByID(ctx, "user-1"); err != nil {
+			t.Fatalf("GetByID failed: %v", err)
+		}
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 1 {
+			t.Fatalf("expected GetByID to read through, got %d calls", got)
+		}
+	})
+
+	t.Run("LaterInvalidationSkipsRecord", func(t *testing.T) {
+		baseRepo, cached, hooked := newHookedRepo(t)
+		ctx := context.Background()
+		tag, _ := cached.idTag("user-1")
+		hooked.beforeSet = func(string) {
+			// A newer write invalidates the record before this one populates.
+			_ = hooked.InvalidateTags(ctx, []string{tag})
+		}
+
+		if _, err := cached.Update(ctx, written); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		user, err := cached.GetByID(ctx, "user-1")
+		if err != nil || user.Name != "from base" {
+			t.Fatalf("expected the outdated write not to be cached, got %+v (%v)", user, err)
+		}
+		if got := countCalls(baseRepo.getCalls(), "GetByID"); got != 1 {
+			t.Fatalf("expected GetByID to read through, got %d calls", got)
+		}
+	})
+
+	t.Run("SetFailureFollowsPolicy", func(t *testing.T) {
+		_, cached, hooked := newHookedRepo(t)
+		ctx := context.Background()
+		setErr := errors.New("cache unavailable")
+		hooked.setErr = setErr
+		cached.SetInvalidationFailurePolicy(InvalidationFailurePolicy{Mode: InvalidationFailureReturn})
+
+		_, err := cached.Update(ctx, written)
+		var failed *InvalidationError
+		if !errors.As(err, &failed) || !errors.Is(err, setErr) {
+			t.Fatalf("expected the Set error as an *InvalidationError, got %v", err)
+		}
+		if key := cached.recordKey("GetByID", "user-1", cached.scopeSignature(ctx, repository.ScopeOperationSelect)); len(failed.Keys) != 1 || failed.Keys[0] != key {
+			t.Fatalf("expected the failed key %q, got %v", key, failed.Keys)
+		}
+		if failed.Operation != "Update" {
+			t.Fatalf("expected the Update operation, got %q", failed.Operation)
+		}
+
+		cached.SetInvalidationFailurePolicy(InvalidationFailurePolicy{})
+		if _, err := cached.Update(ctx, written); err != nil {
+			t.Fatalf("expected the ignore mode to drop the Set error, got %v", err)
+		}
+	})
+}